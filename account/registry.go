@@ -0,0 +1,231 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway"
+	"hudori-desktop/history"
+	"hudori-desktop/outbox"
+)
+
+// Account is one user signed in to one backend profile.
+type Account struct {
+	Profile string
+	Client  *client.Client
+
+	// Gateway is the realtime connection of a signed-in account.
+	Gateway *gateway.Gateway
+	// Outbox holds the messages of a signed-in account until they are sent.
+	Outbox *outbox.Outbox
+	// History is the local copy of the conversations of the account.
+	History *history.Store
+
+	mu   sync.RWMutex
+	user client.User
+}
+
+// ID identifies the account across restarts. It is empty until the
+// account has signed in.
+func (a *Account) ID() string {
+	_, userID := a.Client.Session()
+	if userID == "" {
+		return ""
+	}
+	return a.Profile + "/" + userID
+}
+
+// Key is the ID made safe to use as a file name.
+func (a *Account) Key() string {
+	return Key(a.ID())
+}
+
+// Key returns the key of the account of that id, which stays known once
+// the account signed out and its ID is empty.
+func Key(id string) string {
+	return strings.NewReplacer("/", "_", ":", "_", "\\", "_").Replace(id)
+}
+
+func (a *Account) User() client.User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.user
+}
+
+func (a *Account) SetUser(user client.User) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.user = user
+}
+
+// Close ends the realtime connection of the account, stops sending its
+// messages and closes its history.
+func (a *Account) Close() {
+	if a.Gateway != nil {
+		a.Gateway.Close()
+	}
+	if a.Outbox != nil {
+		a.Outbox.Close()
+	}
+	if a.History != nil {
+		a.History.Close()
+	}
+}
+
+// Info describes an account to the frontend.
+type Info struct {
+	ID      string      `json:"id"`
+	Profile string      `json:"profile"`
+	User    client.User `json:"user"`
+	Active  bool        `json:"active"`
+}
+
+// Registry holds every signed-in account and the one currently in use.
+// The current account may be a guest that has not signed in yet, which is
+// how a new account gets added.
+type Registry struct {
+	newClient func(profile string) *client.Client
+
+	mu       sync.RWMutex
+	accounts map[string]*Account
+	order    []string
+	current  *Account
+}
+
+// NewRegistry returns an empty registry. newClient creates the API client
+// of every account, bound to the account's backend profile.
+func NewRegistry(newClient func(profile string) *client.Client) *Registry {
+	return &Registry{
+		newClient: newClient,
+		accounts:  make(map[string]*Account),
+	}
+}
+
+// Current returns the account in use.
+func (r *Registry) Current() *Account {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// New returns a signed-out account on profile. It is not part of the
+// registry until it is registered.
+func (r *Registry) New(profile string) *Account {
+	return &Account{
+		Profile: profile,
+		Client:  r.newClient(profile),
+	}
+}
+
+// Guest makes a fresh, signed-out account on profile the current one.
+// The other accounts stay signed in.
+func (r *Registry) Guest(profile string) *Account {
+	acc := r.New(profile)
+
+	r.mu.Lock()
+	r.current = acc
+	r.mu.Unlock()
+
+	return acc
+}
+
+// Register adds a signed-in account, replacing any previous sign-in of
+// the same user on the same profile.
+func (r *Registry) Register(acc *Account) error {
+	id := acc.ID()
+	if id == "" {
+		return fmt.Errorf("account on %s is not signed in", acc.Profile)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[id]; !ok {
+		r.order = append(r.order, id)
+	}
+	r.accounts[id] = acc
+
+	return nil
+}
+
+func (r *Registry) Get(id string) (*Account, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	acc, ok := r.accounts[id]
+	return acc, ok
+}
+
+// Switch makes the account id the current one.
+func (r *Registry) Switch(id string) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	acc, ok := r.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %q", id)
+	}
+	r.current = acc
+
+	return acc, nil
+}
+
+// Remove forgets the account id. When it was the current account, the
+// first remaining account takes its place, or nil if there is none.
+func (r *Registry) Remove(id string) (*Account, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	acc, ok := r.accounts[id]
+	if !ok {
+		return nil, false
+	}
+	delete(r.accounts, id)
+
+	for i, other := range r.order {
+		if other == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+
+	if r.current == acc {
+		r.current = nil
+		if len(r.order) > 0 {
+			r.current = r.accounts[r.order[0]]
+		}
+	}
+
+	return acc, true
+}
+
+// All returns the signed-in accounts in the order they were added.
+func (r *Registry) All() []*Account {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	accounts := make([]*Account, 0, len(r.order))
+	for _, id := range r.order {
+		accounts = append(accounts, r.accounts[id])
+	}
+
+	return accounts
+}
+
+// List describes the signed-in accounts for the frontend.
+func (r *Registry) List() []Info {
+	current := r.Current()
+
+	var infos []Info
+	for _, acc := range r.All() {
+		infos = append(infos, Info{
+			ID:      acc.ID(),
+			Profile: acc.Profile,
+			User:    acc.User(),
+			Active:  acc == current,
+		})
+	}
+
+	return infos
+}
@@ -1,1290 +1,2064 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
-	"mime/multipart"
+	"html"
+	"image"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
-
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hudori-desktop/account"
+	"hudori-desktop/attachment"
+	"hudori-desktop/client"
+	"hudori-desktop/config"
+	"hudori-desktop/deeplink"
+	"hudori-desktop/gateway"
+	"hudori-desktop/history"
+	"hudori-desktop/imaging"
+	"hudori-desktop/media"
+	"hudori-desktop/notify"
+	"hudori-desktop/outbox"
+	"hudori-desktop/session"
+	"hudori-desktop/tray"
+	"hudori-desktop/trust"
+
+	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-var (
-	SessionId = ""
-	UserId    = ""
-)
-
-type MultipartData struct {
-	Fields map[string]string
-	Files  map[string]File
-}
-
-func authFetch(method, url string, body interface{}, headers map[string]string) (*http.Response, error) {
-	client := &http.Client{}
-
-	var req *http.Request
-	var err error
+// messagesPage is how many messages are shown when a conversation is
+// opened, and loaded each time the user scrolls up to the top.
+const messagesPage = 50
 
-	if multipartBody, ok := body.(MultipartData); ok {
-		bodyBuf := &bytes.Buffer{}
-		writer := multipart.NewWriter(bodyBuf)
+// App struct
+type App struct {
+	ctx context.Context
+	// emit sends an event to the frontend and warnf logs a problem the app
+	// carries on after. They go through the Wails runtime, which tests run
+	// without.
+	emit  func(name string, data ...interface{})
+	warnf func(format string, args ...interface{})
+
+	config   *config.Config
+	accounts *account.Registry
+	// sessions is nil when the sessions are not kept, as in tests.
+	sessions *session.Store
+	dataDir  string
+	staging  *attachment.Staging
+
+	// media is nil when the cache could not be opened.
+	media     *media.Cache
+	downloads *media.Manager
+	// mediaServer serves the cache to the webview, see media.Prefix.
+	mediaServer *media.Handler
+
+	// notifier is nil when the session has no notification server.
+	notifier *notify.Notifier
+	// tray is nil when the icon could not be set up.
+	tray *tray.Tray
+	// quitting is set once the user asked to quit, so closing the window
+	// is not turned into hiding it.
+	quitting atomic.Bool
+
+	// focusMu guards focused and channels.
+	focusMu sync.Mutex
+	// focused is the conversation on screen while the window has focus.
+	// Its messages are not notified.
+	focused string
+	// channels maps the ids of the server channels loaded so far to their
+	// server and name, which notifications need.
+	channels map[string]serverChannel
+
+	// unchecked holds saved sessions that could not be verified at startup,
+	// typically because the backend was unreachable. They are kept on disk
+	// so they can be tried again on the next start.
+	unchecked []session.Session
+
+	// trust holds the certificates of the backends trusted on first use.
+	trust *trust.Store
+	// transportsMu guards transports, the transport of the clients of each
+	// profile, which carries its TLS configuration and proxy.
+	transportsMu sync.Mutex
+	transports   map[string]*http.Transport
+	// mismatches holds the changed certificates the user was warned about,
+	// so that they are warned once.
+	mismatches sync.Map
+
+	// restored is closed once the saved sessions, if any, have been checked.
+	restored chan struct{}
+	// linkMu guards link and linksReady. link is the hudori:// link waiting
+	// for an account to be signed in and the frontend to be ready for it,
+	// which linksReady tells once it asked for the link.
+	linkMu     sync.Mutex
+	link       *deeplink.Link
+	linksReady bool
+	// cookiesChanged is set while the sessions wait to be saved after a
+	// backend changed the cookies of an account.
+	cookiesChanged atomic.Bool
+
+	// saveMu guards pending and writing. The sessions are written in the
+	// background, as the keyring may keep the user waiting to unlock it:
+	// pending is the latest state left to write and writing tells the
+	// writer runs, which saving counts until it is done.
+	saveMu  sync.Mutex
+	pending *session.State
+	writing bool
+	saving  sync.WaitGroup
+	// locked is set while the saved sessions wait for the passphrase they
+	// are protected with, and needPassphrase while one is needed at all.
+	locked         atomic.Bool
+	needPassphrase atomic.Bool
+}
+
+// cookiesSaveDelay is how long the sessions wait to be saved after the
+// cookies of an account changed, so that a backend rotating a cookie on
+// every response does not have them written as often.
+const cookiesSaveDelay = 5 * time.Second
 
-		for key, value := range multipartBody.Fields {
-			err = writer.WriteField(key, value)
-			if err != nil {
-				return nil, fmt.Errorf("error writing field: %w", err)
-			}
-		}
+// NewApp creates a new App application struct
+func NewApp(cfg *config.Config, sessions *session.Store, dataDir string) *App {
+	a := &App{
+		config:     cfg,
+		sessions:   sessions,
+		dataDir:    dataDir,
+		staging:    attachment.NewStaging(),
+		channels:   map[string]serverChannel{},
+		transports: map[string]*http.Transport{},
+		restored:   make(chan struct{}),
+	}
 
-		for key, file := range multipartBody.Files {
-			part, err := writer.CreateFormFile(key, file.Name)
-			if err != nil {
-				return nil, fmt.Errorf("error creating form file: %w", err)
-			}
-			_, err = part.Write(file.Data)
-			if err != nil {
-				return nil, fmt.Errorf("error writing file data: %w", err)
-			}
+	a.emit = func(name string, data ...interface{}) {
+		runtime.EventsEmit(a.ctx, name, data...)
+	}
+	a.warnf = func(format string, args ...interface{}) {
+		if a.ctx == nil {
+			// The runtime can only log once the app started.
+			println("Warning:", fmt.Sprintf(format, args...))
+			return
 		}
+		runtime.LogWarningf(a.ctx, format, args...)
+	}
 
-		err = writer.Close()
-		if err != nil {
-			return nil, fmt.Errorf("error closing multipart writer: %w", err)
-		}
+	store, err := trust.Open(filepath.Join(dataDir, "certificates.json"))
+	if err != nil {
+		a.warnf("certificates trusted on first use are lost: %v", err)
+	}
+	a.trust = store
 
-		req, err = http.NewRequest(method, url, bodyBuf)
-		if err != nil {
-			return nil, fmt.Errorf("error creating request: %w", err)
-		}
+	a.accounts = account.NewRegistry(func(profile string) *client.Client {
+		return client.New(func() string {
+			p, _ := cfg.Profile(profile)
+			return p.APIURL
+		}, a.transport(profile))
+	})
+	a.accounts.Guest(cfg.Active().Name)
 
-		req.Header.Set("Content-Type", writer.FormDataContentType())
+	// The cache is opened before startup as the asset server, which
+	// serves it, is set up before the app starts.
+	cache, err := media.OpenCache(filepath.Join(dataDir, "media"), media.DefaultCacheSize)
+	if err != nil {
+		a.warnf("media cache disabled: %v", err)
 	} else {
-		var bodyReader io.Reader
-		if body != nil {
-			jsonBody, err := json.Marshal(body)
-			if err != nil {
-				return nil, fmt.Errorf("error marshaling body: %w", err)
-			}
-			bodyReader = bytes.NewBuffer(jsonBody)
-		}
-
-		req, err = http.NewRequest(method, url, bodyReader)
-		if err != nil {
-			return nil, fmt.Errorf("error creating request: %w", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
+		a.media = cache
 	}
+	a.downloads = media.NewManager(a.mediaSource, a.media, func(d media.Download) {
+		a.emit("download:update", d)
+	})
+	a.mediaServer = media.NewHandler(a.media, a.mediaSource)
+
+	return a
+}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", SessionId))
-	req.Header.Set("X-User-ID", UserId)
+// transport returns the transport of the clients of profile, with its TLS
+// settings and proxy, made once so that they share its connections.
+func (a *App) transport(profile string) *http.Transport {
+	a.transportsMu.Lock()
+	defer a.transportsMu.Unlock()
 
-	for key, value := range headers {
-		req.Header.Set(key, value)
+	if t, ok := a.transports[profile]; ok {
+		return t
 	}
 
-	resp, err := client.Do(req)
+	p, _ := a.config.Profile(profile)
+	tlsConfig, err := a.trust.Config(p.Name, p.TLS)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+		// Only the authorities of the system are trusted then.
+		a.warnf("TLS settings of profile %s ignored: %v", profile, err)
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
 	}
+	t := client.NewTransport(tlsConfig, client.ProxyFunc(p.Proxy.URL, p.Proxy.Bypass))
+	a.transports[profile] = t
 
-	return resp, nil
+	return t
 }
 
-// App struct
-type App struct {
-	ctx context.Context
+// certificateChanged warns the user that a backend trusted on first use
+// shows another certificate, which may be someone intercepting the
+// connection, and offers to trust the new one. The connections stay
+// refused unless they do.
+func (a *App) certificateChanged(mismatch *trust.MismatchError) {
+	key := mismatch.Profile + "/" + mismatch.Host + "/" + mismatch.Presented
+	if _, warned := a.mismatches.LoadOrStore(key, true); warned {
+		return
+	}
+	a.warnf("refusing connection to %s: %v", mismatch.Profile, mismatch)
+
+	// The handshake that found it is not held up by the dialog.
+	go func() {
+		answer, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:  runtime.QuestionDialog,
+			Title: "The certificate of " + mismatch.Server() + " changed",
+			Message: fmt.Sprintf("%s does not show the certificate it had before, which may mean that "+
+				"someone is intercepting the connection. Hudori refuses to connect to it until its new "+
+				"certificate is trusted.\n\nTrusted: %s\nShown: %s\n\nOnly trust it if you know "+
+				"that the certificate of the server was changed. Trust the new certificate?",
+				mismatch.Server(), mismatch.Known, mismatch.Presented),
+			DefaultButton: "No",
+		})
+		if err != nil || answer != "Yes" {
+			return
+		}
+
+		err = a.trust.Trust(mismatch.Profile, mismatch.Host, mismatch.Presented)
+		if err != nil {
+			a.warnf("could not save trusted certificate: %v", err)
+		}
+		a.mismatches.Delete(key)
+	}()
 }
 
-// NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+// api returns the client of the account in use.
+func (a *App) api() *client.Client {
+	return a.accounts.Current().Client
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	runtime.BrowserOpenURL(ctx, "/signin")
-}
+	runtime.OnFileDrop(ctx, a.filesDropped)
+	a.trust.OnMismatch(a.certificateChanged)
 
-// Greet returns a greeting for the given name
-func (a *App) Greet(name string) string {
-	return fmt.Sprintf("Hello %s, It's show time!", name)
-}
-
-type SigninRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-func (a *App) SignIn(request string) map[string]interface{} {
-	var req SigninRequest
-	err := json.Unmarshal([]byte(request), &req)
+	notifier, err := notify.Open(a.notificationAction)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		a.warnf("desktop notifications disabled: %v", err)
+	} else {
+		a.notifier = notifier
 	}
 
-	response, err := http.Post(
-		fmt.Sprintf("%s/auth/signin", "https://localhost:8080"),
-		"application/json",
-		strings.NewReader(request),
-	)
+	icon, err := tray.Open("Hudori", a.showWindow)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"name":    "unexpected",
-			"message": "Please check your login information and try again.",
-		}
+		a.warnf("tray icon disabled: %v", err)
+	} else {
+		a.tray = icon
+		a.updateTray()
 	}
-	defer response.Body.Close()
 
-	cookies := response.Cookies()
-	if len(cookies) > 0 {
-		SessionId = cookies[0].Value
-	}
+	go a.restoreSessions()
+}
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+// shutdown is called when the app quits.
+func (a *App) shutdown(ctx context.Context) {
+	if a.notifier != nil {
+		a.notifier.Close()
+	}
+	if a.tray != nil {
+		a.tray.Close()
+	}
+	if a.media != nil {
+		a.media.Close()
+	}
+	if a.cookiesChanged.Load() {
+		a.saveSessions()
+	}
+	a.saving.Wait()
+}
 
-	if response.Status != "200 OK" {
-		return result
+// beforeClose hides the window rather than quit when the app is set to
+// close to the tray, as long as the tray icon is there to bring it back.
+// The realtime connections stay up meanwhile.
+func (a *App) beforeClose(ctx context.Context) (prevent bool) {
+	if a.quitting.Load() || a.tray == nil || !a.tray.Visible() || !a.config.Preferences().CloseToTray {
+		return false
 	}
+	runtime.WindowHide(ctx)
+	return true
+}
 
-	userInfos := result["user"].(map[string]interface{})
-	UserId = userInfos["id"].(string)
+// showWindow brings the window back, hidden or minimised.
+func (a *App) showWindow() {
+	runtime.WindowShow(a.ctx)
+	runtime.WindowUnminimise(a.ctx)
+}
 
-	return result
+// open shows the window at a route of the frontend, which it is sent with
+// a navigate event.
+func (a *App) open(path string) {
+	a.showWindow()
+	a.emit("navigate", path)
 }
 
-func (a *App) AuthVerify() map[string]interface{} {
-	response, err := authFetch("GET", fmt.Sprintf("%s/auth/verify", "https://localhost:8080"), nil, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to signin",
-		}
+func (a *App) quit() {
+	a.quitting.Store(true)
+	runtime.Quit(a.ctx)
+}
+
+// updateTray rebuilds the menu of the tray icon, for the account in use and
+// the preferences.
+func (a *App) updateTray() {
+	if a.tray == nil {
+		return
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	acc := a.accounts.Current()
+	signedIn := acc.ID() != ""
+	status := acc.User().Status
+	prefs := a.config.Preferences()
 
-	if result["message"] == "success" {
-		userInfos := result["user"].(map[string]interface{})
-		UserId = userInfos["id"].(string)
+	var statuses []tray.Item
+	for _, choice := range []struct{ value, label string }{
+		{"online", "Online"},
+		{"absent", "Away"},
+		{"dontdisturb", "Do not disturb"},
+		{"offline", "Invisible"},
+	} {
+		value := choice.value
+		statuses = append(statuses, tray.Item{
+			Label:   choice.label,
+			Radio:   true,
+			Checked: status == value,
+			Action: func() {
+				_, userID := acc.Client.Session()
+				resp := a.ChangeStatus(client.StatusRequest{UserID: userID, Status: value})
+				if resp.Message != "success" {
+					a.warnf("could not change status: %s", resp.Message)
+				}
+			},
+		})
 	}
 
-	return result
+	a.tray.SetMenu([]tray.Item{
+		{Label: "Open Hudori", Action: a.showWindow},
+		{Label: "Direct messages", Disabled: !signedIn, Action: func() { a.open("/hudori/chat/friends") }},
+		{},
+		{Label: "Status", Disabled: !signedIn, Children: statuses},
+		{Label: "Mute notifications", Check: true, Checked: prefs.Muted, Action: func() {
+			prefs.Muted = !prefs.Muted
+			a.SetPreferences(prefs)
+		}},
+		{Label: "Close to tray", Check: true, Checked: prefs.CloseToTray, Action: func() {
+			prefs.CloseToTray = !prefs.CloseToTray
+			a.SetPreferences(prefs)
+		}},
+		{},
+		{Label: "Quit", Action: a.quit},
+	})
 }
 
-type generalRequest struct {
-	UserID string `json:"user_id"`
+// serveMedia is the asset server middleware serving the media cache.
+func (a *App) serveMedia(next http.Handler) http.Handler {
+	return a.mediaServer.Middleware(next)
 }
 
-func (a *App) GetFriends(request string) map[string]interface{} {
-	var req generalRequest
-	err := json.Unmarshal([]byte(request), &req)
+// mediaSource fetches media with the client of the account in use, on its
+// behalf when the client sends credentials with them. Only the API and
+// media hosts of the active profile are fetched from.
+func (a *App) mediaSource(url string) (string, media.Fetcher) {
+	if !a.mediaHost(url) {
+		return "", nil
+	}
+	acc := a.accounts.Current()
+	if !acc.Client.Authenticates(url) {
+		return "", acc.Client.Fetch
+	}
+	return acc.ID(), acc.Client.Fetch
+}
+
+// mediaHost reports whether rawURL is on the API or media host of the
+// active profile.
+func (a *App) mediaHost(rawURL string) bool {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
+		return false
+	}
+	profile := a.config.Active()
+	for _, base := range []string{profile.APIURL, profile.MediaURL} {
+		b, err := url.Parse(base)
+		if err == nil && b.Scheme == u.Scheme && b.Host == u.Host {
+			return true
 		}
 	}
+	return false
+}
+
+// restoreSessions resumes the accounts saved by a previous run that the
+// backend still accepts, so the user does not have to sign in again.
+func (a *App) restoreSessions() {
+	defer close(a.restored)
 
-	response, err := authFetch("GET", fmt.Sprintf("%s/api/v1/friends/%s", "https://localhost:8080", req.UserID), nil, nil)
+	state, err := a.sessions.Load()
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to fetch friends",
+		if errors.Is(err, session.ErrPassphrase) {
+			// The frontend asks for it, see PassphraseNeeded.
+			a.locked.Store(true)
+			a.needPassphrase.Store(true)
+		} else if !errors.Is(err, session.ErrNoSession) {
+			a.warnf("could not restore sessions: %v", err)
 		}
+		runtime.BrowserOpenURL(a.ctx, "/signin")
+		return
 	}
-	defer response.Body.Close()
-
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
 
-	return result
+	if !a.resumeSessions(state) {
+		runtime.BrowserOpenURL(a.ctx, "/signin")
+	}
 }
 
-func (a *App) GetServers(request string) map[string]interface{} {
-	var req generalRequest
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
+// resumeSessions resumes the accounts of state and switches to the one
+// that was active. It reports whether any account was resumed.
+func (a *App) resumeSessions(state session.State) bool {
+	changed := false
+	for _, sess := range state.Sessions {
+		if !a.resumeSession(sess) {
+			changed = true
 		}
 	}
 
-	response, err := authFetch("GET", fmt.Sprintf("%s/api/v1/servers/%s", "https://localhost:8080", req.UserID), nil, nil)
+	current := a.accounts.Current()
+	_, err := a.accounts.Switch(state.Active)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to fetch servers",
+		accounts := a.accounts.All()
+		if len(accounts) == 0 {
+			if changed {
+				a.saveSessions()
+			}
+			return false
 		}
+		a.accounts.Switch(accounts[0].ID())
+		changed = true
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	if profile := a.accounts.Current().Profile; profile != current.Profile {
+		_, err = a.config.Switch(profile)
+		if err != nil {
+			a.warnf("could not switch profile: %v", err)
+		}
+	}
 
-	return result
+	if changed {
+		a.saveSessions()
+	}
+	return true
 }
 
-type MessagesRequest struct {
-	ChannelId string `json:"channel_id"`
-	UserID    string `json:"user_id"`
+// resumeSession registers the account of a saved session. It reports
+// whether the session was registered as it was saved, and not dropped or
+// renewed.
+func (a *App) resumeSession(sess session.Session) bool {
+	if _, ok := a.config.Profile(sess.Profile); !ok {
+		a.warnf("dropping session for unknown profile %q", sess.Profile)
+		return false
+	}
+
+	acc := a.accounts.New(sess.Profile)
+	if len(sess.Cookies) > 0 {
+		acc.Client.SetCookies(sess.Cookies)
+		acc.Client.SetSession("", sess.UserID)
+	} else {
+		acc.Client.SetSession(sess.SessionID, sess.UserID)
+	}
+	acc.Client.SetRefreshToken(sess.RefreshToken)
+	resp, err := acc.Client.Verify(a.ctx)
+	if err != nil {
+		a.warnf("could not verify saved session: %v", err)
+		a.unchecked = append(a.unchecked, sess)
+		return true
+	}
+
+	if resp.Message != "success" || resp.User == nil {
+		return false
+	}
+
+	acc.SetUser(*resp.User)
+	err = a.register(acc)
+	if err != nil {
+		a.warnf("could not restore account: %v", err)
+		return false
+	}
+
+	sessionID, _ := acc.Client.Session()
+	return sessionID == sess.SessionID
 }
 
-func (a *App) GetMessages(request string) map[string]interface{} {
-	var req MessagesRequest
-	err := json.Unmarshal([]byte(request), &req)
+// register adds a signed-in account, opens its realtime connection and
+// starts sending the messages it left in its outbox. Only the events of
+// the account in use reach the frontend.
+func (a *App) register(acc *account.Account) error {
+	if previous, ok := a.accounts.Get(acc.ID()); ok {
+		previous.Close()
+	}
+
+	store, err := history.Open(filepath.Join(a.dataDir, "history", acc.Key()+".db"))
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		return err
 	}
+	acc.History = store
 
-	channelUrl := fmt.Sprintf("%s/api/v1/messages/%s", "https://localhost:8080", req.ChannelId)
-	friendUrl := fmt.Sprintf("%s/api/v1/messages/%s/private/%s", "https://localhost:8080", req.ChannelId, req.UserID)
+	box, err := outbox.New(filepath.Join(a.dataDir, "outbox", acc.Key()), acc.Client.CreateMessage, func(e outbox.Entry) {
+		if a.accounts.Current() == acc {
+			a.emit("outbox:update", e)
+		}
+	}, func(p client.UploadProgress) {
+		if a.accounts.Current() == acc {
+			a.emit("upload:progress", p)
+		}
+	})
+	if err != nil {
+		store.Close()
+		return err
+	}
+	acc.Outbox = box
 
-	var response *http.Response
-	if req.UserID == "" {
-		response, err = authFetch("GET", channelUrl, nil, nil)
-		if err != nil {
-			return map[string]interface{}{
-				"status":  500,
-				"message": "Failed to fetch messages",
+	profile, _ := a.config.Profile(acc.Profile)
+	_, userID := acc.Client.Session()
+	acc.Gateway = gateway.New(gateway.URL(profile.WSURL, userID), acc.Client.Jar(), a.transport(acc.Profile), func(e gateway.Event) {
+		switch payload := e.Payload.(type) {
+		case gateway.StateChange:
+			if payload.State == gateway.StateOnline {
+				box.Wake()
 			}
-		}
-	} else {
-		response, err = authFetch("GET", friendUrl, nil, nil)
-		if err != nil {
-			return map[string]interface{}{
-				"status":  500,
-				"message": "Failed to fetch messages",
+		case client.Message:
+			a.recordMessage(acc, e.Name, payload)
+			if e.Name == "text_message" && a.accounts.Current() == acc {
+				a.notifyMessage(acc, payload)
 			}
+		case client.Notification:
+			if e.Name == "friend_request" && a.accounts.Current() == acc {
+				a.notifyFriendRequest(acc, payload)
+			}
+		}
+		if a.accounts.Current() == acc {
+			a.emit("gateway:"+e.Name, e.Payload)
 		}
+	}, a.warnf)
+
+	err = a.accounts.Register(acc)
+	if err != nil {
+		acc.Close()
+		return err
 	}
-	defer response.Body.Close()
+	acc.Client.OnExpired(func(renewed bool) {
+		a.sessionExpired(acc, renewed)
+	})
+	acc.Client.OnCookies(a.saveCookies)
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	go acc.Gateway.Run(a.ctx)
+	go acc.Outbox.Run(a.ctx)
+	a.updateTray()
 
-	return result
+	return nil
 }
 
-type ServerRequest struct {
-	UserId   string `json:"user_id"`
-	ServerId string `json:"server_id"`
+// sessionExpiry tells the frontend that the session of an account expired.
+// Signin is set when the account in use could not get a new one, and the
+// user has to sign in again.
+type sessionExpiry struct {
+	Account string `json:"account"`
+	Renewed bool   `json:"renewed"`
+	Signin  bool   `json:"signin"`
 }
 
-func (a *App) GetServer(request string) map[string]interface{} {
-	var req ServerRequest
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
+// sessionExpired keeps the new session of acc once its session expired,
+// or drops the account when it could not be renewed. Its outbox and
+// history are kept for when the user signs in again.
+func (a *App) sessionExpired(acc *account.Account, renewed bool) {
+	expiry := sessionExpiry{Account: acc.ID(), Renewed: renewed}
+
+	if !renewed {
+		current := a.accounts.Current() == acc
+		if _, ok := a.accounts.Remove(acc.ID()); ok {
+			acc.Close()
 		}
+		if current {
+			a.accounts.Guest(acc.Profile)
+			expiry.Signin = true
+		}
+		a.updateTray()
 	}
 
-	url := fmt.Sprintf("%s/api/v1/server/%s/%s", "https://localhost:8080", req.UserId, req.ServerId)
+	a.saveSessions()
+	a.emit("session-expired", expiry)
+}
 
-	response, err := authFetch("GET", url, nil, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to fetch messages",
-		}
+// saveCookies saves the sessions a little after the cookies of an account
+// changed, along with any other change made meanwhile.
+func (a *App) saveCookies() {
+	if a.cookiesChanged.CompareAndSwap(false, true) {
+		time.AfterFunc(cookiesSaveDelay, func() {
+			a.cookiesChanged.Store(false)
+			a.saveSessions()
+		})
 	}
-	defer response.Body.Close()
+}
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+// saveSessions keeps the signed-in accounts for the next start. They are
+// written in the background, and only the latest state when they change
+// again meanwhile.
+func (a *App) saveSessions() {
+	if a.sessions == nil {
+		return
+	}
 
-	return result
-}
+	var state session.State
+	if current := a.accounts.Current(); current != nil {
+		state.Active = current.ID()
+	}
+	for _, acc := range a.accounts.All() {
+		sessionID, userID := acc.Client.Session()
+		state.Sessions = append(state.Sessions, session.Session{
+			Profile:      acc.Profile,
+			SessionID:    sessionID,
+			UserID:       userID,
+			RefreshToken: acc.Client.RefreshToken(),
+			Cookies:      acc.Client.Cookies(),
+		})
+	}
+	for _, sess := range a.unchecked {
+		if _, ok := a.accounts.Get(sess.Profile + "/" + sess.UserID); !ok {
+			state.Sessions = append(state.Sessions, sess)
+		}
+	}
 
-type TypingRequest struct {
-	UserId      string `json:"user_id"`
-	ChannelId   string `json:"channel_id"`
-	DisplayName string `json:"display_name"`
-	Status      string `json:"status"`
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+	a.pending = &state
+	if !a.writing {
+		a.writing = true
+		a.saving.Add(1)
+		go a.writeSessions()
+	}
 }
 
-func (a *App) IndicateTyping(request string) map[string]interface{} {
-	var req TypingRequest
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
+// writeSessions writes the pending sessions until there are none left.
+func (a *App) writeSessions() {
+	defer a.saving.Done()
+	for {
+		a.saveMu.Lock()
+		state := a.pending
+		a.pending = nil
+		if state == nil {
+			a.writing = false
+			a.saveMu.Unlock()
+			return
 		}
+		a.saveMu.Unlock()
+
+		a.writeState(*state)
+	}
+}
+
+// writeState writes state, or removes the saved sessions if it has none.
+func (a *App) writeState(state session.State) {
+	// The saved sessions must not be replaced before they are unlocked.
+	if a.locked.Load() {
+		return
 	}
 
-	url := fmt.Sprintf("%s/api/v1/channels/typing", "https://localhost:8080")
+	if len(state.Sessions) == 0 {
+		err := a.sessions.Clear()
+		if err != nil {
+			a.warnf("could not clear sessions: %v", err)
+		}
+		return
+	}
 
-	response, err := authFetch("POST", url, nil, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to indicate typing",
+	err := a.sessions.Save(state)
+	if errors.Is(err, session.ErrPassphrase) {
+		if !a.needPassphrase.Swap(true) {
+			a.emit("session:passphrase", PassphraseResponse{Result: client.Success(), Needed: true})
 		}
+		return
+	}
+	if err != nil {
+		a.warnf("could not save sessions: %v", err)
 	}
-	defer response.Body.Close()
+}
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+// Greet returns a greeting for the given name
+func (a *App) Greet(name string) string {
+	return fmt.Sprintf("Hello %s, It's show time!", name)
+}
 
-	return result
+// GetBackendProfiles returns the configured backend profiles and the active one
+func (a *App) GetBackendProfiles() map[string]interface{} {
+	return map[string]interface{}{
+		"active":   a.config.Active(),
+		"profiles": a.config.Profiles(),
+	}
 }
 
-type SyncNotifRequest struct {
-	UserId   string `json:"user_id"`
-	Channels any    `json:"channels"`
+// ProfileResponse is the backend profile the app was pointed at.
+type ProfileResponse struct {
+	client.Result
+	Profile *config.Profile `json:"profile,omitempty"`
 }
 
-func (a *App) SyncNotifications(request string) map[string]interface{} {
-	var req SyncNotifRequest
-	err := json.Unmarshal([]byte(request), &req)
+// SwitchBackendProfile points the app at another backend. The accounts
+// already signed in stay available; a new one is started on the backend.
+func (a *App) SwitchBackendProfile(name string) ProfileResponse {
+	profile, err := a.config.Switch(name)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		return ProfileResponse{Result: client.FieldError(400, "profile", err.Error())}
 	}
 
-	url := fmt.Sprintf("%s/api/v1/notifications/message_update", "https://localhost:8080")
+	a.accounts.Guest(profile.Name)
 
-	response, err := authFetch("POST", url, req, nil)
+	err = a.config.Save()
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to sync notifications",
-		}
+		return ProfileResponse{Result: client.Failure("Failed to save profile", err)}
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
-
-	return result
+	return ProfileResponse{Result: client.Success(), Profile: &profile}
 }
 
-func (a *App) GetNotifications(request string) map[string]interface{} {
-	var req generalRequest
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+// TestConnection checks that the backend of profile, or of the profile in
+// use when it is empty, can be reached with its TLS settings and through
+// its proxy, both its API and its websocket endpoint, and how long they
+// take to answer.
+func (a *App) TestConnection(profile string) client.ProbeResponse {
+	if profile == "" {
+		profile = a.config.Active().Name
+	}
+	p, ok := a.config.Profile(profile)
+	if !ok {
+		return client.ProbeResponse{Result: client.Fail(404, fmt.Sprintf("unknown profile %q", profile))}
 	}
 
-	url := fmt.Sprintf("%s/api/v1/notifications/%s", "https://localhost:8080", req.UserID)
+	probe, err := client.Probe(a.ctx, a.transport(profile), p.APIURL)
+	if err != nil {
+		return client.ProbeResponse{Result: client.Failure("Failed to reach "+p.APIURL, err), Probe: &probe}
+	}
 
-	response, err := authFetch("GET", url, nil, nil)
+	latency, err := gateway.Probe(a.ctx, gateway.URL(p.WSURL, ""), a.transport(profile))
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to fetch notifications",
-		}
+		return client.ProbeResponse{Result: client.Failure("Failed to reach "+p.WSURL, err), Probe: &probe}
 	}
-	defer response.Body.Close()
+	probe.WebsocketLatencyMS = latency.Milliseconds()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	return client.ProbeResponse{Result: client.Success(), Probe: &probe}
+}
 
-	return result
+// ListAccounts returns every signed-in account.
+func (a *App) ListAccounts() []account.Info {
+	return a.accounts.List()
 }
 
-type CreateInviteReq struct {
-	UserId   string `json:"user_id"`
-	ServerId string `json:"server_id"`
+// AddAccount starts a new account on the active backend, leaving the
+// others signed in. The frontend then shows the sign-in page.
+func (a *App) AddAccount() client.Result {
+	a.accounts.Guest(a.config.Active().Name)
+	return client.Success()
 }
 
-func (a *App) CreateInvitation(request string) map[string]interface{} {
-	var req CreateInviteReq
-	err := json.Unmarshal([]byte(request), &req)
+// SwitchAccount makes another signed-in account the one in use. The
+// frontend reloads afterwards so every view is rebuilt for that account.
+func (a *App) SwitchAccount(id string) client.Result {
+	acc, err := a.accounts.Switch(id)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		return client.Fail(404, err.Error())
 	}
 
-	url := fmt.Sprintf("%s/api/v1/invites/create", "https://localhost:8080")
-
-	response, err := authFetch("POST", url, nil, nil)
+	_, err = a.config.Switch(acc.Profile)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to create invitation",
-		}
+		return client.Failure("Failed to switch profile", err)
+	}
+	err = a.config.Save()
+	if err != nil {
+		a.warnf("could not save profile: %v", err)
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	a.saveSessions()
+	a.updateTray()
 
-	return result
+	return client.Success()
 }
 
-func (a *App) GetProfile(request string) map[string]interface{} {
-	var req generalRequest
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
-	}
+// LogoutResponse is the outcome of signing an account out. The account is
+// forgotten on this device even when the server could not be told, e.g.
+// offline; Warning says so then.
+type LogoutResponse struct {
+	client.Result
+	Warning string `json:"warning,omitempty"`
+}
 
-	url := fmt.Sprintf("%s/api/v1/user/%s", "https://localhost:8080", req.UserID)
-	response, err := authFetch("GET", url, nil, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to get profile",
-		}
+// RemoveAccount signs an account out and forgets it. When it was the
+// account in use, the next signed-in account takes over, if any.
+func (a *App) RemoveAccount(id string) LogoutResponse {
+	acc, ok := a.accounts.Get(id)
+	if !ok {
+		return LogoutResponse{Result: client.Fail(404, fmt.Sprintf("unknown account %q", id))}
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	warning := a.logout(acc)
+	a.removeAccount(id)
 
-	return result
+	return LogoutResponse{Result: client.Success(), Warning: warning}
 }
 
-type ServerActionsReq struct {
-	UserId   string `json:"user_id"`
-	ServerId string `json:"server_id"`
+// logout signs acc out of the server, and returns a warning for the user
+// if that failed.
+func (a *App) logout(acc *account.Account) string {
+	resp, err := acc.Client.Logout(a.ctx)
+	if err == nil && resp.Status >= 300 {
+		err = errors.New(resp.Message)
+	}
+	if err == nil {
+		return ""
+	}
+
+	a.warnf("could not sign %s out of the server: %v", acc.ID(), err)
+	return "Signed out of this device only, as the server could not be reached. The session ends once it expires."
 }
 
-func (a *App) DeleteServer(request string) map[string]interface{} {
-	var req ServerActionsReq
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
+func (a *App) removeAccount(id string) {
+	if acc, ok := a.accounts.Remove(id); ok {
+		acc.Close()
+		err := acc.Outbox.Clear()
+		if err != nil {
+			a.warnf("could not clear outbox: %v", err)
+		}
+		// The account is signed out by now, so its own ID is empty.
+		err = os.Remove(filepath.Join(a.dataDir, "history", account.Key(id)+".db"))
+		if err != nil {
+			a.warnf("could not remove message history: %v", err)
 		}
 	}
 
-	url := fmt.Sprintf("%s/api/v1/server/delete", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to delete server",
+	if current := a.accounts.Current(); current != nil {
+		_, err := a.config.Switch(current.Profile)
+		if err != nil {
+			a.warnf("could not switch profile: %v", err)
 		}
+	} else {
+		a.accounts.Guest(a.config.Active().Name)
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
-
-	return result
+	a.saveSessions()
+	a.updateTray()
 }
 
-func (a *App) QuitServer(request string) map[string]interface{} {
-	var req ServerActionsReq
-	err := json.Unmarshal([]byte(request), &req)
+func (a *App) SignIn(req client.SigninRequest) client.UserResponse {
+	acc := a.accounts.Current()
+	resp, err := acc.Client.SignIn(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		// The error is kept for its code only, the user is shown a hint.
+		resp.Result = client.Failure("Failed to sign in", err)
+		resp.Name = "unexpected"
+		resp.Message = "Please check your login information and try again."
+		return resp
 	}
 
-	url := fmt.Sprintf("%s/api/v1/server/leave", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to quit server",
+	if resp.Message == "success" && resp.User != nil {
+		acc.SetUser(*resp.User)
+		err = a.register(acc)
+		if err != nil {
+			a.warnf("could not register account: %v", err)
 		}
+		a.saveSessions()
 	}
-	defer response.Body.Close()
-
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
 
-	return result
+	return resp
 }
 
-type JoinServerReq struct {
-	User     interface{} `json:"user"`
-	InviteId string      `json:"invite_id"`
+func (a *App) AuthVerify() client.UserResponse {
+	resp, err := a.api().Verify(a.ctx)
+	if err != nil {
+		resp.Result = client.Failure("Failed to signin", err)
+	}
+	return resp
 }
 
-func (a *App) JoinServer(request string) map[string]interface{} {
-	var req JoinServerReq
-	err := json.Unmarshal([]byte(request), &req)
+func (a *App) GetFriends(req client.UserRequest) client.FriendsResponse {
+	resp, err := a.api().Friends(a.ctx, req.UserID)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		resp.Result = client.Failure("Failed to fetch friends", err)
 	}
+	return resp
+}
 
-	url := fmt.Sprintf("%s/api/v1/server/join", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
+func (a *App) GetServers(req client.UserRequest) client.ServersResponse {
+	resp, err := a.api().Servers(a.ctx, req.UserID)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to join server",
+		resp.Result = client.Failure("Failed to fetch servers", err)
+	}
+	a.nameChannels(resp.Servers...)
+	return resp
+}
+
+// nameChannels records the names of the channels of servers in the
+// message history, so that searches can use them, and for notifications.
+func (a *App) nameChannels(servers ...client.Server) {
+	a.focusMu.Lock()
+	for _, server := range servers {
+		for _, category := range server.Categories {
+			for _, channel := range category.Channels {
+				a.channels[shortID(channel.ID)] = serverChannel{ServerID: server.ID, Name: channel.Name}
+			}
 		}
 	}
-	defer response.Body.Close()
+	a.focusMu.Unlock()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	store := a.accounts.Current().History
+	if store == nil {
+		return
+	}
 
-	return result
+	for _, server := range servers {
+		for _, category := range server.Categories {
+			for _, channel := range category.Channels {
+				err := store.SetName(history.Key(client.Message{ChannelID: channel.ID}, ""), channel.Name)
+				if err != nil {
+					a.warnf("could not name channel: %v", err)
+					return
+				}
+			}
+		}
+	}
 }
 
-type CreateServerReq struct {
-	UserId string `json:"user_id"`
-	Name   string `json:"name"`
-}
+// recordMessage keeps the message history up to date with the realtime
+// events of an account.
+func (a *App) recordMessage(acc *account.Account, event string, msg client.Message) {
+	_, userID := acc.Client.Session()
+	conversation := history.Key(msg, userID)
 
-func (a *App) CreateServer(request string) map[string]interface{} {
-	var req CreateServerReq
-	err := json.Unmarshal([]byte(request), &req)
+	var err error
+	switch event {
+	case "text_message", "edit_message":
+		_, err = acc.History.Put(conversation, msg)
+	case "delete_message":
+		err = acc.History.Delete(conversation, msg.ID)
+	}
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		a.warnf("could not record message: %v", err)
+	}
+}
+
+// CachedMessages returns the newest messages of a conversation from the
+// local history, without going to the server.
+func (a *App) CachedMessages(req client.MessagesRequest) client.MessagesResponse {
+	var resp client.MessagesResponse
+	store := a.accounts.Current().History
+	if store == nil {
+		resp.Result = client.Fail(401, "not signed in")
+		return resp
 	}
 
-	url := fmt.Sprintf("%s/api/v1/server/create", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
+	msgs, more, err := store.Latest(req.ChannelID, messagesPage)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to create server",
-		}
+		resp.Result = client.Failure("Failed to read messages", err)
+		return resp
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	resp.Result = client.Success()
+	resp.Messages = msgs
+	resp.HasMore = more || !store.Complete(req.ChannelID)
 
-	return result
+	return resp
 }
 
-type CatReq struct {
-	ServerId     string `json:"server_id"`
-	CategoryName string `json:"category_name"`
-}
-
-func (a *App) CreateCategory(request string) map[string]interface{} {
-	var req CatReq
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
+// GetMessages fetches the messages of a conversation that are newer than
+// those last fetched and returns the newest page. Messages received live
+// do not count, so the messages missed before them are fetched too. When
+// the server cannot be reached, the local history is returned as it is.
+func (a *App) GetMessages(req client.MessagesRequest) client.MessagesResponse {
+	acc := a.accounts.Current()
+	if acc.History == nil {
+		resp, err := acc.Client.Messages(a.ctx, req)
+		if err != nil {
+			resp.Result = client.Failure("Failed to fetch messages", err)
 		}
+		return resp
 	}
 
-	url := fmt.Sprintf("%s/api/v1/category/create", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
+	remote := req
+	remote.After, _ = acc.History.Synced(req.ChannelID)
+	if remote.After == "" {
+		remote.Limit = messagesPage
+	}
+
+	resp, err := acc.Client.Messages(a.ctx, remote)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to create category",
+		cached := a.CachedMessages(req)
+		if len(cached.Messages) == 0 {
+			resp.Result = client.Failure("Failed to fetch messages", err)
+			return resp
 		}
+		a.warnf("could not fetch messages, showing stored ones: %v", err)
+		return cached
+	}
+	if resp.Status >= 300 {
+		return resp
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	_, err = acc.History.Sync(req.ChannelID, resp.Messages...)
+	if err != nil {
+		a.warnf("could not store messages: %v", err)
+		return resp
+	}
+	if remote.After == "" && len(resp.Messages) != remote.Limit {
+		a.markComplete(acc, req.ChannelID)
+	}
 
-	return result
+	return a.CachedMessages(req)
 }
 
-func (a *App) DeleteCategory(request string) map[string]interface{} {
-	var req CatReq
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+// GetOlderMessages returns the page of messages before req.Before, a
+// message id, reading the local history first and completing it from the
+// server when it runs short.
+func (a *App) GetOlderMessages(req client.MessagesRequest) client.MessagesResponse {
+	var resp client.MessagesResponse
+	acc := a.accounts.Current()
+	if acc.History == nil {
+		resp.Result = client.Fail(401, "not signed in")
+		return resp
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = messagesPage
 	}
 
-	url := fmt.Sprintf("%s/api/v1/category/delete", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
+	msgs, more, err := acc.History.Before(req.ChannelID, req.Before, limit)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to delete category",
+		resp.Result = client.Fail(404, err.Error())
+		return resp
+	}
+
+	if len(msgs) < limit && !acc.History.Complete(req.ChannelID) {
+		remote := req
+		remote.After = ""
+		remote.Limit = messagesPage
+		if len(msgs) > 0 {
+			remote.Before = msgs[0].ID
+		}
+
+		fetched, err := acc.Client.Messages(a.ctx, remote)
+		if err == nil && fetched.Status < 300 {
+			_, err = acc.History.Put(req.ChannelID, fetched.Messages...)
+			if err == nil && len(fetched.Messages) != remote.Limit {
+				a.markComplete(acc, req.ChannelID)
+			}
+		}
+		if err != nil {
+			a.warnf("could not fetch older messages: %v", err)
+		}
+
+		msgs, more, err = acc.History.Before(req.ChannelID, req.Before, limit)
+		if err != nil {
+			resp.Result = client.Failure("Failed to read messages", err)
+			return resp
 		}
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	resp.Result = client.Success()
+	resp.Messages = msgs
+	resp.HasMore = more || !acc.History.Complete(req.ChannelID)
 
-	return result
+	return resp
 }
 
-type DelFriendReq struct {
-	UserId   string `json:"user_id"`
-	FriendId string `json:"friend_id"`
+func (a *App) markComplete(acc *account.Account, conversation string) {
+	err := acc.History.SetComplete(conversation)
+	if err != nil {
+		a.warnf("could not update message history: %v", err)
+	}
 }
 
-func (a *App) DeleteFriend(request string) map[string]interface{} {
-	var req DelFriendReq
-	err := json.Unmarshal([]byte(request), &req)
+func (a *App) GetServer(req client.ServerRequest) client.ServerResponse {
+	resp, err := a.api().Server(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		resp.Result = client.Failure("Failed to fetch server", err)
+	}
+	if resp.Server != nil {
+		a.nameChannels(*resp.Server)
+	}
+	return resp
+}
+
+// SearchMessages searches the messages stored on this device, so it works
+// offline but only finds messages of conversations that were opened.
+func (a *App) SearchMessages(req client.SearchRequest) client.SearchResponse {
+	var resp client.SearchResponse
+	store := a.accounts.Current().History
+	if store == nil {
+		resp.Result = client.Fail(401, "not signed in")
+		return resp
 	}
 
-	url := fmt.Sprintf("%s/api/v1/friends/delete", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
+	q, err := history.ParseQuery(req.Query)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to delete friend",
-		}
+		resp.Result = client.FieldError(400, "query", err.Error())
+		return resp
 	}
-	defer response.Body.Close()
+	q.Limit = req.Limit
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	resp.Hits, resp.Total, err = store.Search(q)
+	if err != nil {
+		resp.Result = client.Failure("Failed to search messages", err)
+		return resp
+	}
+	resp.Result = client.Success()
 
-	return result
+	return resp
 }
 
-type FriendReq struct {
-	ID        string `json:"id"`
-	RequestId string `json:"request_id"`
+func (a *App) IndicateTyping(req client.TypingRequest) client.Result {
+	resp, err := a.api().Typing(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to indicate typing", err)
+	}
+	return resp
 }
 
-func (a *App) AcceptFriend(request string) map[string]interface{} {
-	var req FriendReq
-	err := json.Unmarshal([]byte(request), &req)
+func (a *App) SyncNotifications(req client.SyncNotificationsRequest) client.Result {
+	resp, err := a.api().SyncNotifications(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		resp = client.Failure("Failed to sync notifications", err)
 	}
+	return resp
+}
 
-	url := fmt.Sprintf("%s/api/v1/friends/accept", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
+func (a *App) GetNotifications(req client.UserRequest) client.NotificationsResponse {
+	resp, err := a.api().Notifications(a.ctx, req.UserID)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to accept friend request",
-		}
+		resp.Result = client.Failure("Failed to fetch notifications", err)
 	}
-	defer response.Body.Close()
+	return resp
+}
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+// serverChannel is what notifications need to know of a server channel.
+type serverChannel struct {
+	ServerID string
+	Name     string
+}
 
-	return result
+// alert is what a desktop notification is about, so that the actions
+// taken on it can be carried out.
+type alert struct {
+	acc *account.Account
+	// conversation is named as by history.Key.
+	conversation string
+	// channelID is the channel of the notification to mark read.
+	channelID string
+	serverID  string
+	private   bool
+	// request is set for friend requests.
+	request *client.Notification
 }
 
-func (a *App) RefuseFriend(request string) map[string]interface{} {
-	var req FriendReq
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+// path is the route of the frontend showing what the alert is about.
+func (t alert) path() string {
+	switch {
+	case t.request != nil:
+		return "/hudori/chat/friends"
+	case t.private:
+		return "/hudori/chat/friends/" + t.conversation
+	case t.serverID == "":
+		return "/hudori/chat/community"
+	default:
+		return "/hudori/chat/community/" + shortID(t.serverID) + "/channels/" + t.conversation
 	}
+}
 
-	url := fmt.Sprintf("%s/api/v1/friends/refuse", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to refuse friend request",
-		}
+func shortID(id string) string {
+	if _, short, ok := strings.Cut(id, ":"); ok {
+		return short
 	}
-	defer response.Body.Close()
-
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
-
-	return result
+	return id
 }
 
-type AddFriendReq struct {
-	InitiatorId       string `json:"initiator_id"`
-	InitiatorUsername string `json:"initiator_username"`
-	ReceiverUsername  string `json:"receiver_username"`
+// GetPreferences returns the settings of the desktop app.
+func (a *App) GetPreferences() config.Preferences {
+	return a.config.Preferences()
 }
 
-func (a *App) AddFriend(request string) map[string]interface{} {
-	var req AddFriendReq
-	err := json.Unmarshal([]byte(request), &req)
+// SetPreferences saves the settings of the desktop app and sends them with
+// a preferences:update event, as they may come from the tray menu.
+func (a *App) SetPreferences(prefs config.Preferences) client.Result {
+	a.config.SetPreferences(prefs)
+	err := a.config.Save()
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		return client.Failure("Failed to save preferences", err)
 	}
 
-	url := fmt.Sprintf("%s/api/v1/friends/add", "https://localhost:8080")
-	response, err := authFetch("POST", url, req, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to refuse friend request",
-		}
+	a.updateTray()
+	a.emit("preferences:update", prefs)
+
+	return client.Success()
+}
+
+// SetUnread shows on the tray icon how many messages are unread, as the
+// notifications of the frontend count them, and whether some mention the
+// user.
+func (a *App) SetUnread(unread, mentions int) {
+	if a.tray == nil {
+		return
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	text := "No unread messages"
+	if unread == 1 {
+		text = "1 unread message"
+	} else if unread > 1 {
+		text = fmt.Sprintf("%d unread messages", unread)
+	}
+	if mentions == 1 {
+		text += ", 1 mention"
+	} else if mentions > 1 {
+		text += fmt.Sprintf(", %d mentions", mentions)
+	}
 
-	return result
+	a.tray.SetBadge(unread, mentions > 0, text)
 }
 
-type File struct {
-	Name string
-	Data []byte
+// FocusConversation tells which conversation is on screen, named as in
+// the routes of the frontend, or "" when the window lost focus. Messages
+// of the focused conversation are not notified, and the notification
+// shown for it, if any, is withdrawn.
+func (a *App) FocusConversation(conversation string) {
+	a.focusMu.Lock()
+	a.focused = conversation
+	a.focusMu.Unlock()
+
+	if conversation != "" && a.notifier != nil {
+		a.notifier.Withdraw(a.accounts.Current().ID() + "/" + conversation)
+	}
 }
 
-func (a *App) CreateMessage(author any, channelId string, content string, mentions []string, replyTo string, privateMessage bool, serverId string, files []File) map[string]interface{} {
-	url := fmt.Sprintf("%s/api/v1/messages/create", "https://localhost:8080")
+// notifyMessage shows a desktop notification for a private message or a
+// message mentioning the user, unless its conversation is on screen or
+// notifications are muted.
+func (a *App) notifyMessage(acc *account.Account, msg client.Message) {
+	if a.notifier == nil || a.config.Preferences().Muted {
+		return
+	}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	_, userID := acc.Client.Session()
+	if shortID(msg.Author.ID) == shortID(userID) {
+		return
+	}
+	private := shortID(msg.ChannelID) == shortID(userID)
+	mentioned := slices.ContainsFunc(msg.Mentions, func(id string) bool {
+		return shortID(id) == shortID(userID)
+	})
+	if !private && !mentioned {
+		return
+	}
 
-	jsonBody := map[string]interface{}{
-		"author":          author,
-		"channel_id":      channelId,
-		"content":         content,
-		"mentions":        mentions,
-		"reply":           replyTo,
-		"private_message": privateMessage,
+	conversation := history.Key(msg, userID)
+	a.focusMu.Lock()
+	focused := a.focused == conversation
+	channel, known := a.channels[conversation]
+	a.focusMu.Unlock()
+	if focused {
+		return
 	}
-	jsonData, err := json.Marshal(jsonBody)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Failed to marshal JSON body",
-		}
+
+	target := alert{acc: acc, conversation: conversation, private: private}
+	summary := msg.Author.DisplayName
+	if summary == "" {
+		summary = msg.Author.Username
 	}
-	err = writer.WriteField("body", string(jsonData))
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Failed to write JSON body to form",
+	if private {
+		target.channelID = msg.Author.ID
+	} else {
+		target.channelID = msg.ChannelID
+		target.serverID = channel.ServerID
+		if known {
+			summary += " in #" + channel.Name
 		}
 	}
 
-	// Add files to the form
-	for i, file := range files {
-		part, err := writer.CreateFormFile(fmt.Sprintf("file-%d", i), file.Name)
-		if err != nil {
-			return map[string]interface{}{
-				"status":  400,
-				"message": fmt.Sprintf("Failed to create form file: %v", err),
-			}
-		}
-		_, err = io.Copy(part, bytes.NewReader(file.Data))
-		if err != nil {
-			return map[string]interface{}{
-				"status":  400,
-				"message": fmt.Sprintf("Failed to copy file data: %v", err),
-			}
-		}
+	body := history.Text(msg.Content)
+	if body == "" && len(msg.Images) == 1 {
+		body = "Sent a picture"
+	} else if body == "" && len(msg.Images) > 1 {
+		body = fmt.Sprintf("Sent %d pictures", len(msg.Images))
 	}
 
-	err = writer.Close()
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Failed to close multipart writer",
+	var image string
+	if a.media != nil {
+		if blob, ok := a.media.Lookup(msg.Author.Avatar); ok {
+			image = blob.Path
 		}
 	}
 
-	req, err := http.NewRequest("POST", url, body)
+	// Replying needs the server of a channel.
+	reply := private || known
+	actions := []notify.Action{{Key: "read", Label: "Mark read"}}
+	if reply && !a.notifier.CanReply() {
+		actions = append([]notify.Action{{Key: "reply", Label: "Reply"}}, actions...)
+	}
+
+	err := a.notifier.Show(notify.Notification{
+		Tag:      acc.ID() + "/" + conversation,
+		Summary:  summary,
+		Body:     body,
+		Image:    image,
+		Category: "im.received",
+		Actions:  actions,
+		Reply:    reply,
+		Data:     target,
+	})
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Failed to create request",
-		}
+		a.warnf("could not notify message: %v", err)
 	}
+}
 
-	// Set headers
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", SessionId))
-	req.Header.Set("X-User-ID", UserId)
+// notifyFriendRequest shows a desktop notification for a friend request.
+func (a *App) notifyFriendRequest(acc *account.Account, request client.Notification) {
+	if a.notifier == nil || a.config.Preferences().Muted {
+		return
+	}
 
-	// Send the request
-	client := &http.Client{}
-	_, err = client.Do(req)
+	err := a.notifier.Show(notify.Notification{
+		Tag:      acc.ID() + "/friend_request/" + request.RequestID,
+		Summary:  "Friend request",
+		Body:     request.Message,
+		Category: "im",
+		Actions:  []notify.Action{{Key: "accept", Label: "Accept"}},
+		Data:     alert{acc: acc, request: &request},
+	})
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to send request",
+		a.warnf("could not notify friend request: %v", err)
+	}
+}
+
+// notificationAction carries out an action taken on a desktop
+// notification. Opening one shows the window at what it is about; the
+// frontend is told of what the other actions changed with
+// notification:read and notification:accepted.
+func (a *App) notificationAction(inv notify.Invocation) {
+	target, ok := inv.Data.(alert)
+	if !ok || target.acc != a.accounts.Current() {
+		// The account was switched since.
+		return
+	}
+
+	switch inv.Action {
+	case notify.DefaultAction, "reply":
+		a.open(target.path())
+		return
+	case notify.ReplyAction:
+		msg := client.NewMessage{
+			Author:         target.acc.User(),
+			ChannelID:      target.conversation,
+			Content:        "<p>" + html.EscapeString(inv.Text) + "</p>",
+			Mentions:       []string{},
+			PrivateMessage: target.private,
+			ServerID:       target.serverID,
+		}
+		_, err := target.acc.Outbox.Enqueue(msg, nil)
+		if err != nil {
+			a.warnf("could not send reply: %v", err)
+			return
 		}
+		a.markRead(target)
+	case "read":
+		a.markRead(target)
+	case "accept":
+		resp := a.AcceptFriend(client.FriendRequestReply{ID: target.request.ID, RequestID: target.request.RequestID})
+		if resp.Message != "success" {
+			a.warnf("could not accept friend request: %s", resp.Message)
+			return
+		}
+		a.emit("notification:accepted", target.request.RequestID, resp.Friend)
+	default:
+		return
 	}
 
-	return nil
+	a.notifier.Withdraw(inv.Tag)
 }
 
-type DelMessageReq struct {
-	ChannelId      string `json:"channel_id"`
-	MessageId      string `json:"message_id"`
-	PrivateMessage bool   `json:"private_message"`
-	AuthorId       string `json:"author_id"`
+// markRead marks the conversation of an alert read on the server and in
+// the frontend.
+func (a *App) markRead(target alert) {
+	_, userID := target.acc.Client.Session()
+	_, err := target.acc.Client.SyncNotifications(a.ctx, client.SyncNotificationsRequest{
+		UserID:   userID,
+		Channels: []string{target.channelID},
+	})
+	if err != nil {
+		a.warnf("could not mark conversation read: %v", err)
+	}
+	a.emit("notification:read", target.conversation)
 }
 
-func (a *App) DeleteMessage(request string) map[string]interface{} {
-	var req DelMessageReq
-	err := json.Unmarshal([]byte(request), &req)
+// queueLink keeps the hudori:// link the app was launched with, to follow
+// once the frontend is ready for it.
+func (a *App) queueLink(raw string) {
+	link, err := deeplink.Parse(raw)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+		a.warnf("ignoring link: %v", err)
+		return
 	}
 
-	url := fmt.Sprintf("%s/api/v1/messages/delete", "https://localhost:8080")
-	response, err := authFetch("DELETE", url, req, nil)
+	a.linkMu.Lock()
+	defer a.linkMu.Unlock()
+	a.link = &link
+}
+
+// secondInstance is called when the app is launched while it runs, which
+// the desktop does to open a hudori:// link with it. The running instance
+// shows its window and follows the link.
+func (a *App) secondInstance(data options.SecondInstanceData) {
+	// The launch may come before startup, and the link needs the saved
+	// accounts.
+	<-a.restored
+	a.showWindow()
+
+	raw, ok := deeplink.Find(data.Args)
+	if !ok {
+		return
+	}
+	link, err := deeplink.Parse(raw)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to delete message",
-		}
+		a.warnf("ignoring link: %v", err)
+		return
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+	a.linkMu.Lock()
+	if !a.linksReady || a.accounts.Current().ID() == "" {
+		// The frontend asks for it once an account is signed in.
+		a.link = &link
+		a.linkMu.Unlock()
+		return
+	}
+	a.linkMu.Unlock()
 
-	return result
+	a.followLink(link)
 }
 
-type EditMessageReq struct {
-	ChannelId      string   `json:"channel_id"`
-	MessageId      string   `json:"message_id"`
-	PrivateMessage bool     `json:"private_message"`
-	AuthorId       string   `json:"author_id"`
-	Content        string   `json:"content"`
-	Mentions       []string `json:"mentions"`
-}
+// OpenPendingLink follows the hudori:// link waiting for the frontend, if
+// any. The frontend calls it once it listens to the navigate event, with
+// an account signed in.
+func (a *App) OpenPendingLink() {
+	a.linkMu.Lock()
+	a.linksReady = true
+	link := a.link
+	a.link = nil
+	a.linkMu.Unlock()
 
-func (a *App) EditMessage(request string) map[string]interface{} {
-	var req EditMessageReq
-	err := json.Unmarshal([]byte(request), &req)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format",
-		}
+	if link != nil {
+		a.followLink(*link)
 	}
+}
 
-	url := fmt.Sprintf("%s/api/v1/messages/edit", "https://localhost:8080")
-	response, err := authFetch("PUT", url, req, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to refuse friend request",
+// followLink joins the server of an invitation, or shows the conversation
+// a link points to.
+func (a *App) followLink(link deeplink.Link) {
+	switch link.Kind {
+	case deeplink.Invite:
+		a.joinInvitation(link.InviteID)
+	case deeplink.Channel:
+		a.open("/hudori/chat/community/" + link.ServerID + "/channels/" + link.ChannelID)
+	case deeplink.Message:
+		path := a.conversationPath(link.ChannelID)
+		if path != "/hudori/chat/friends" {
+			// The frontend loads the conversation back to the message and
+			// scrolls to it.
+			path += "?message=" + link.MessageID
 		}
+		a.open(path)
 	}
-	defer response.Body.Close()
+}
 
-	var result map[string]interface{}
-	json.NewDecoder(response.Body).Decode(&result)
+// joinInvitation joins the server of an invitation for the account in use,
+// and shows it. The frontend is given the server with server:joined.
+func (a *App) joinInvitation(id string) {
+	acc := a.accounts.Current()
+	resp := a.JoinServer(client.JoinServerRequest{User: acc.User(), InviteID: id})
+	if resp.Message != "success" || resp.Server == nil {
+		a.showWindow()
+		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:    runtime.ErrorDialog,
+			Title:   "Could not join the community",
+			Message: resp.Message,
+		})
+		return
+	}
 
-	return result
+	a.nameChannels(*resp.Server)
+	a.emit("server:joined", resp.Server)
+	a.open("/hudori/chat/community/" + shortID(resp.Server.ID))
 }
 
-func (a *App) ChangeBanner(fileData []byte, fileName string, cropY, cropX, cropWidth, cropHeight int, oldBanner string) map[string]interface{} {
-	url := fmt.Sprintf("%s/api/v1/user/change_banner", "https://localhost:8080")
+// conversationPath returns the route of the conversation of channel: that
+// of the server channel when it is one, the private conversation with the
+// user of that id otherwise.
+func (a *App) conversationPath(channel string) string {
+	a.focusMu.Lock()
+	known, ok := a.channels[channel]
+	a.focusMu.Unlock()
 
-	body := MultipartData{
-		Fields: map[string]string{
-			"cropY":      fmt.Sprintf("%d", cropY),
-			"cropX":      fmt.Sprintf("%d", cropX),
-			"cropWidth":  fmt.Sprintf("%d", cropWidth),
-			"cropHeight": fmt.Sprintf("%d", cropHeight),
-			"old_banner": oldBanner,
-		},
-		Files: map[string]File{
-			"banner": {
-				Name: fileName,
-				Data: fileData,
-			},
-		},
+	if !ok {
+		// The servers may not have been loaded yet.
+		acc := a.accounts.Current()
+		a.GetServers(client.UserRequest{UserID: acc.User().ID})
+
+		a.focusMu.Lock()
+		known, ok = a.channels[channel]
+		a.focusMu.Unlock()
 	}
 
-	response, err := authFetch("POST", url, body, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to change banner: " + err.Error(),
-		}
+	switch {
+	case ok:
+		return "/hudori/chat/community/" + shortID(known.ServerID) + "/channels/" + channel
+	case channel == shortID(a.accounts.Current().User().ID):
+		// A private message sent to the user, whose author the link does
+		// not tell.
+		return "/hudori/chat/friends"
+	default:
+		return "/hudori/chat/friends/" + channel
 	}
-	defer response.Body.Close()
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+func (a *App) CreateInvitation(req client.ServerRequest) client.InvitationResponse {
+	resp, err := a.api().CreateInvitation(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		resp.Result = client.Failure("Failed to create invitation", err)
 	}
-
-	return result
+	return resp
 }
 
-type AvatarChangeRequest struct {
-	FileData   []byte   `json:"fileData"`
-	FileName   string   `json:"fileName"`
-	CropY      int      `json:"cropY"`
-	CropX      int      `json:"cropX"`
-	CropWidth  int      `json:"cropWidth"`
-	CropHeight int      `json:"cropHeight"`
-	OldAvatar  string   `json:"oldAvatar"`
-	ServerID   string   `json:"serverId,omitempty"`
-	Friends    []string `json:"friends,omitempty"`
+func (a *App) GetProfile(req client.UserRequest) client.UserResponse {
+	resp, err := a.api().Profile(a.ctx, req.UserID)
+	if err != nil {
+		resp.Result = client.Failure("Failed to get profile", err)
+	}
+	return resp
 }
 
-func (a *App) ChangeAvatar(requestJSON string) map[string]interface{} {
-	var req AvatarChangeRequest
-	err := json.Unmarshal([]byte(requestJSON), &req)
+func (a *App) DeleteServer(req client.ServerRequest) client.Result {
+	resp, err := a.api().DeleteServer(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		resp = client.Failure("Failed to delete server", err)
 	}
+	return resp
+}
 
-	url := fmt.Sprintf("%s/api/v1/user/change_avatar", "https://localhost:8080")
-
-	body := MultipartData{
-		Fields: map[string]string{
-			"cropY":      fmt.Sprintf("%d", req.CropY),
-			"cropX":      fmt.Sprintf("%d", req.CropX),
-			"cropWidth":  fmt.Sprintf("%d", req.CropWidth),
-			"cropHeight": fmt.Sprintf("%d", req.CropHeight),
-			"old_avatar": req.OldAvatar,
-		},
-		Files: map[string]File{
-			"avatar": {
-				Name: req.FileName,
-				Data: req.FileData,
-			},
-		},
+func (a *App) QuitServer(req client.ServerRequest) client.Result {
+	resp, err := a.api().QuitServer(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to quit server", err)
 	}
+	return resp
+}
 
-	if req.ServerID != "" {
-		body.Fields["server_id"] = req.ServerID
-	}
-	if len(req.Friends) > 0 {
-		friendsJSON, _ := json.Marshal(req.Friends)
-		body.Fields["friends"] = string(friendsJSON)
+func (a *App) JoinServer(req client.JoinServerRequest) client.ServerResponse {
+	resp, err := a.api().JoinServer(a.ctx, req)
+	if err != nil {
+		resp.Result = client.Failure("Failed to join server", err)
 	}
+	return resp
+}
 
-	response, err := authFetch("POST", url, body, nil)
+func (a *App) CreateServer(req client.CreateServerRequest) client.ServerResponse {
+	resp, err := a.api().CreateServer(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to change banner: " + err.Error(),
-		}
+		resp.Result = client.Failure("Failed to create server", err)
 	}
-	defer response.Body.Close()
+	return resp
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+func (a *App) CreateCategory(req client.CategoryRequest) client.Result {
+	resp, err := a.api().CreateCategory(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		resp = client.Failure("Failed to create category", err)
 	}
-
-	return result
+	return resp
 }
 
-type NameColorReq struct {
-	UserId        string `json:"user_id"`
-	UsernameColor string `json:"username_color"`
+func (a *App) DeleteCategory(req client.CategoryRequest) client.Result {
+	resp, err := a.api().DeleteCategory(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to delete category", err)
+	}
+	return resp
 }
 
-func (a *App) ChangeNameColor(requestJSON string) map[string]interface{} {
-	var req NameColorReq
-	err := json.Unmarshal([]byte(requestJSON), &req)
+func (a *App) DeleteFriend(req client.DeleteFriendRequest) client.Result {
+	resp, err := a.api().DeleteFriend(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		resp = client.Failure("Failed to delete friend", err)
 	}
+	return resp
+}
 
-	url := fmt.Sprintf("%s/api/v1/user/change_name_color", "https://localhost:8080")
-
-	response, err := authFetch("POST", url, req, nil)
+func (a *App) AcceptFriend(req client.FriendRequestReply) client.FriendResponse {
+	resp, err := a.api().AcceptFriend(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to change name color: " + err.Error(),
-		}
+		resp.Result = client.Failure("Failed to accept friend request", err)
 	}
-	defer response.Body.Close()
+	return resp
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+func (a *App) RefuseFriend(req client.FriendRequestReply) client.Result {
+	resp, err := a.api().RefuseFriend(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		resp = client.Failure("Failed to refuse friend request", err)
 	}
+	return resp
+}
 
-	return result
+func (a *App) AddFriend(req client.AddFriendRequest) client.Result {
+	resp, err := a.api().AddFriend(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to send friend request", err)
+	}
+	return resp
 }
 
-type DelChanReq struct {
-	ChannelId    string `json:"channel_id"`
-	CategoryName string `json:"category_name"`
-	ServerId     string `json:"server_id"`
+// filesDropped stages the files dropped on the window and hands them to
+// the frontend with an attachments:dropped event.
+func (a *App) filesDropped(x, y int, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	a.emit("attachments:dropped", a.staging.AddAll(paths))
 }
 
-func (a *App) DeleteChannel(requestJSON string) map[string]interface{} {
-	var req DelChanReq
-	err := json.Unmarshal([]byte(requestJSON), &req)
+// OpenAttachments lets the user pick files to attach with the native file
+// dialog, and stages them.
+func (a *App) OpenAttachments() attachment.Batch {
+	paths, err := runtime.OpenMultipleFilesDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Attach files",
+	})
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		return attachment.Batch{Staged: []attachment.Staged{}, Errors: []string{err.Error()}}
 	}
+	return a.staging.AddAll(paths)
+}
 
-	url := fmt.Sprintf("%s/api/v1/channels/delete", "https://localhost:8080")
+// UnstageAttachment drops a staged file the user no longer wants to send.
+func (a *App) UnstageAttachment(id string) {
+	a.staging.Remove(id)
+}
 
-	response, err := authFetch("POST", url, req, nil)
+// SaveFile asks the user where to save the file at url, suggesting name,
+// and downloads it there in the background. Its progress is sent with
+// download:update events. Nothing happens if the dialog is dismissed.
+func (a *App) SaveFile(url, name string) client.Result {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Save file",
+		DefaultFilename: name,
+	})
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to delete channel: " + err.Error(),
-		}
+		return client.Failure("Failed to choose where to save the file", err)
+	}
+	if path == "" {
+		return client.Success()
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+	a.downloads.Start(url, path)
+	return client.Success()
+}
+
+// Downloads returns the files downloaded since the app started.
+func (a *App) Downloads() []media.Download {
+	return a.downloads.Downloads()
+}
+
+func (a *App) CancelDownload(id string) client.Result {
+	err := a.downloads.Cancel(id)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		return client.Fail(404, err.Error())
 	}
+	return client.Success()
+}
 
-	return result
+// RetryDownload starts a failed download again, from where it stopped.
+func (a *App) RetryDownload(id string) client.Result {
+	err := a.downloads.Retry(id)
+	if err != nil {
+		return client.Fail(404, err.Error())
+	}
+	return client.Success()
 }
 
-type CreateChanReq struct {
-	Name         string `json:"name"`
-	ChannelType  string `json:"channel_type"`
-	CategoryName string `json:"category_name"`
-	ServerId     string `json:"server_id"`
+// MessageResponse is the outcome of queueing a message. Metadata lists
+// the private metadata removed from its attachments, as Staged.Metadata
+// does for a staged file.
+type MessageResponse struct {
+	client.Result
+	Metadata []string `json:"metadata,omitempty"`
 }
 
-func (a *App) CreateChannel(requestJSON string) map[string]interface{} {
-	var req CreateChanReq
-	err := json.Unmarshal([]byte(requestJSON), &req)
+// CreateMessage queues a message in the outbox of the account in use and
+// succeeds once it is stored. Attachments are given either as files or
+// as the ids of staged files. Its progress is sent with outbox:update
+// events until it is sent, and that of its attachments with
+// upload:progress events, tagged with the id of the entry. The location
+// and device metadata of pictures is removed first, unless original is set.
+func (a *App) CreateMessage(msg client.NewMessage, files []client.File, staged []string, original bool) MessageResponse {
+	box := a.accounts.Current().Outbox
+	if box == nil {
+		return MessageResponse{Result: client.Fail(401, "not signed in")}
+	}
+
+	stagedFiles, err := a.staging.Files(staged)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		return MessageResponse{Result: client.FieldError(400, "files", err.Error())}
 	}
+	files = append(files, stagedFiles...)
 
-	url := fmt.Sprintf("%s/api/v1/channels/create", "https://localhost:8080")
+	var removed []string
+	if !original {
+		for i, file := range files {
+			var labels []string
+			files[i], labels, err = attachment.ScrubFile(file)
+			if err != nil {
+				return MessageResponse{Result: client.FieldError(422, "files", err.Error()+", send the original instead")}
+			}
+			for _, label := range labels {
+				if !slices.Contains(removed, label) {
+					removed = append(removed, label)
+				}
+			}
+		}
+	}
 
-	response, err := authFetch("POST", url, req, nil)
+	err = client.CheckUpload(files)
+	if errors.Is(err, client.ErrTooLarge) {
+		return MessageResponse{Result: client.FieldError(413, "files", err.Error())}
+	}
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to create channel: " + err.Error(),
-		}
+		return MessageResponse{Result: client.Failure("Failed to read attachments", err)}
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+	_, err = box.Enqueue(msg, files)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		return MessageResponse{Result: client.Failure("Failed to queue message", err)}
 	}
+	a.staging.Remove(staged...)
 
-	return result
+	return MessageResponse{Result: client.Success(), Metadata: removed}
 }
 
-type DPNameReq struct {
-	UserId string `json:"user_id"`
-	DPName string `json:"display_name"`
+// PendingMessages returns the messages of the account in use that are not
+// sent yet, including those that failed.
+func (a *App) PendingMessages() []outbox.Entry {
+	box := a.accounts.Current().Outbox
+	if box == nil {
+		return nil
+	}
+	return box.Entries()
 }
 
-func (a *App) ChangeDPName(requestJSON string) map[string]interface{} {
-	var req DPNameReq
-	err := json.Unmarshal([]byte(requestJSON), &req)
+func (a *App) RetryMessage(id string) client.Result {
+	box := a.accounts.Current().Outbox
+	if box == nil {
+		return client.Fail(401, "not signed in")
+	}
+
+	err := box.Retry(id)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		return client.Fail(404, err.Error())
 	}
 
-	url := fmt.Sprintf("%s/api/v1/user/change_name", "https://localhost:8080")
+	return client.Success()
+}
 
-	response, err := authFetch("POST", url, req, nil)
+func (a *App) DiscardMessage(id string) client.Result {
+	box := a.accounts.Current().Outbox
+	if box == nil {
+		return client.Fail(401, "not signed in")
+	}
+
+	err := box.Discard(id)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to change dp name: " + err.Error(),
-		}
+		return client.Fail(404, err.Error())
 	}
-	defer response.Body.Close()
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+	return client.Success()
+}
+
+// CancelUpload stops sending a message and drops it. id is the id of its
+// outbox entry, as found in upload:progress events.
+func (a *App) CancelUpload(id string) client.Result {
+	box := a.accounts.Current().Outbox
+	if box == nil {
+		return client.Fail(401, "not signed in")
+	}
+
+	err := box.Cancel(id)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		return client.Fail(404, err.Error())
 	}
 
-	return result
+	return client.Success()
 }
 
-type UserNameReq struct {
-	UserId string `json:"user_id"`
-	Name   string `json:"username"`
+func (a *App) DeleteMessage(req client.DeleteMessageRequest) client.Result {
+	resp, err := a.api().DeleteMessage(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to delete message", err)
+	}
+	return resp
 }
 
-func (a *App) ChangeUsername(requestJSON string) map[string]interface{} {
-	var req UserNameReq
-	err := json.Unmarshal([]byte(requestJSON), &req)
+func (a *App) EditMessage(req client.EditMessageRequest) client.Result {
+	resp, err := a.api().EditMessage(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		resp = client.Failure("Failed to edit message", err)
 	}
+	return resp
+}
 
-	url := fmt.Sprintf("%s/api/v1/user/change_username", "https://localhost:8080")
+func (a *App) ChangeBanner(req client.BannerChangeRequest) client.BannerResponse {
+	var resp client.BannerResponse
+	resp.Result = processPicture(&req.Crop, &req.FileData, &req.FileName, imaging.Banner)
+	if resp.Status != 0 {
+		return resp
+	}
 
-	response, err := authFetch("POST", url, req, nil)
+	resp, err := a.api().ChangeBanner(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to change username: " + err.Error(),
-		}
+		resp.Result = client.Failure("Failed to change banner", err)
 	}
-	defer response.Body.Close()
+	return resp
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
-	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+func (a *App) ChangeAvatar(req client.AvatarChangeRequest) client.AvatarResponse {
+	var resp client.AvatarResponse
+	resp.Result = processPicture(&req.Crop, &req.FileData, &req.FileName, imaging.Avatar)
+	if resp.Status != 0 {
+		return resp
 	}
 
-	return result
+	resp, err := a.api().ChangeAvatar(a.ctx, req)
+	if err != nil {
+		resp.Result = client.Failure("Failed to change avatar", err)
+	}
+	return resp
 }
 
-type ChangeEmailReq struct {
-	UserId string `json:"user_id"`
-	Email  string `json:"email"`
+// processPicture crops and scales a picture down before it is uploaded, so
+// that only what the server keeps leaves the machine, without metadata. The
+// crop is then the whole processed picture. It returns an empty Result on
+// success.
+func processPicture(crop *client.Crop, data *[]byte, name *string, target imaging.Target) client.Result {
+	rect := image.Rect(crop.X, crop.Y, crop.X+crop.Width, crop.Y+crop.Height)
+	processed, err := imaging.Process(*data, rect, target, imaging.Auto)
+	if errors.Is(err, imaging.ErrUnsupported) {
+		return client.FieldError(415, "fileData", "pictures must be JPEG, PNG, GIF or WebP")
+	}
+	if err != nil {
+		return client.FieldError(400, "fileData", err.Error())
+	}
+
+	*data = processed.Data
+	*name = processed.Name(*name)
+	*crop = client.Crop{Width: processed.Width, Height: processed.Height}
+	return client.Result{}
 }
 
-func (a *App) ChangeEmail(requestJSON string) map[string]interface{} {
-	var req ChangeEmailReq
-	err := json.Unmarshal([]byte(requestJSON), &req)
+// ChangeStatus sets the status the friends of the user see, and tells the
+// frontend with a user:status event, as it may come from the tray menu.
+func (a *App) ChangeStatus(req client.StatusRequest) client.Result {
+	acc := a.accounts.Current()
+	resp, err := acc.Client.ChangeStatus(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  400,
-			"message": "Invalid request format: " + err.Error(),
-		}
+		return client.Failure("Failed to change status", err)
+	}
+	if resp.Message != "success" {
+		return resp
 	}
 
-	url := fmt.Sprintf("%s/api/v1/user/change_email", "https://localhost:8080")
+	user := acc.User()
+	user.Status = req.Status
+	acc.SetUser(user)
+	a.updateTray()
+	a.emit("user:status", req.Status)
 
-	response, err := authFetch("POST", url, req, nil)
+	return resp
+}
+
+func (a *App) ChangeNameColor(req client.NameColorRequest) client.Result {
+	resp, err := a.api().ChangeNameColor(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to change email: " + err.Error(),
-		}
+		resp = client.Failure("Failed to change name color", err)
 	}
-	defer response.Body.Close()
+	return resp
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+func (a *App) DeleteChannel(req client.DeleteChannelRequest) client.Result {
+	resp, err := a.api().DeleteChannel(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		resp = client.Failure("Failed to delete channel", err)
 	}
+	return resp
+}
 
-	return result
+func (a *App) CreateChannel(req client.CreateChannelRequest) client.Result {
+	resp, err := a.api().CreateChannel(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to create channel", err)
+	}
+	return resp
 }
 
-func (a *App) LogoutHudori() map[string]interface{} {
-	url := fmt.Sprintf("%s/api/v1/user/logout", "https://localhost:8080")
+func (a *App) ChangeDPName(req client.DisplayNameRequest) client.Result {
+	resp, err := a.api().ChangeDisplayName(a.ctx, req)
+	if err != nil {
+		resp = client.Failure("Failed to change dp name", err)
+	}
+	return resp
+}
 
-	response, err := authFetch("POST", url, nil, nil)
+func (a *App) ChangeUsername(req client.UsernameRequest) client.Result {
+	resp, err := a.api().ChangeUsername(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to logout: " + err.Error(),
-		}
+		resp = client.Failure("Failed to change username", err)
 	}
-	defer response.Body.Close()
+	return resp
+}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+func (a *App) ChangeEmail(req client.EmailRequest) client.Result {
+	resp, err := a.api().ChangeEmail(a.ctx, req)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		resp = client.Failure("Failed to change email", err)
 	}
+	return resp
+}
 
-	SessionId = ""
-	UserId = ""
+// LogoutHudori signs out the account in use.
+func (a *App) LogoutHudori() LogoutResponse {
+	acc := a.accounts.Current()
+	id := acc.ID()
+	if id == "" {
+		return LogoutResponse{Result: client.Fail(401, "not signed in")}
+	}
 
-	return result
+	warning := a.logout(acc)
+	a.removeAccount(id)
+
+	return LogoutResponse{Result: client.Success(), Warning: warning}
 }
 
-func (a *App) IsAuthenticated() map[string]interface{} {
-	if SessionId == "" && UserId == "" {
-		return map[string]interface{}{
-			"status": "401",
-		}
+// IsAuthenticated waits for the saved session to be checked before
+// answering, so the sign-in page is skipped when it is still valid.
+func (a *App) IsAuthenticated() client.Result {
+	<-a.restored
+
+	if !a.api().Authenticated() {
+		return client.Fail(401, "not signed in")
 	}
+	return client.Success()
+}
 
-	return map[string]interface{}{
-		"status": "200",
+// PassphraseResponse tells whether the sessions need a passphrase, there
+// being no keyring to protect them. Unlock is set when it is the one the
+// sessions of a previous run were saved with.
+type PassphraseResponse struct {
+	client.Result
+	Needed bool `json:"needed"`
+	Unlock bool `json:"unlock"`
+}
+
+// SessionPassphrase waits for the saved sessions to be checked and tells
+// whether a passphrase is needed. A later need, when the sessions are
+// first saved, is sent with the session:passphrase event.
+func (a *App) SessionPassphrase() PassphraseResponse {
+	<-a.restored
+
+	return PassphraseResponse{
+		Result: client.Success(),
+		Needed: a.needPassphrase.Load(),
+		Unlock: a.locked.Load(),
 	}
 }
 
-func (a *App) GenerateRoomToken(channelId, userId string) map[string]interface{} {
-	url := fmt.Sprintf("%s/api/v1/rtc/%s/%s}", "https://localhost:8080", channelId, userId)
+// SetSessionPassphrase sets the passphrase protecting the sessions. The
+// sessions saved with it are resumed, after which the frontend reloads.
+func (a *App) SetSessionPassphrase(passphrase string) client.Result {
+	if a.sessions == nil {
+		return client.Fail(404, "sessions are not kept")
+	}
+	if passphrase == "" {
+		return client.FieldError(400, "passphrase", "a passphrase is required")
+	}
 
-	response, err := authFetch("POST", url, nil, nil)
-	if err != nil {
-		return map[string]interface{}{
-			"status":  500,
-			"message": "Failed to create room token: " + err.Error(),
+	a.sessions.SetPassphrase(passphrase)
+	if a.locked.Load() {
+		state, err := a.sessions.Load()
+		if errors.Is(err, session.ErrPassphrase) {
+			return client.FieldError(403, "passphrase", "wrong passphrase")
+		}
+		a.locked.Store(false)
+		if err == nil {
+			a.resumeSessions(state)
+			a.updateTray()
+		} else if !errors.Is(err, session.ErrNoSession) {
+			a.warnf("could not restore sessions: %v", err)
 		}
 	}
-	defer response.Body.Close()
+	a.needPassphrase.Store(false)
+	a.saveSessions()
+
+	return client.Success()
+}
+
+// ForgetSessions gives up on the saved sessions locked with a forgotten
+// passphrase. They are replaced by the accounts signed in since.
+func (a *App) ForgetSessions() client.Result {
+	a.locked.Store(false)
+	a.needPassphrase.Store(false)
+	a.saveSessions()
+
+	return client.Success()
+}
+
+func (a *App) GenerateRoomToken(channelId, userId string) client.RoomTokenResponse {
+	resp, err := a.api().RoomToken(a.ctx, channelId, userId)
+	if err != nil {
+		resp.Result = client.Failure("Failed to create room token", err)
+	}
+	return resp
+}
+
+// SendParticipantUpdate relays a voice channel update to the other
+// members through the realtime connection.
+func (a *App) SendParticipantUpdate(update gateway.ParticipantUpdate) client.Result {
+	gw := a.accounts.Current().Gateway
+	if gw == nil {
+		return client.Fail(401, "not signed in")
+	}
 
-	var result map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&result)
+	err := gw.Send(update)
 	if err != nil {
-		return map[string]interface{}{"error": "Failed to parse response"}
+		return client.Failure("Failed to send participant update", err)
 	}
 
-	return result
+	return client.Success()
+}
+
+// GatewayState returns the state of the realtime connection of the
+// account in use; later changes are sent with the gateway:state event.
+func (a *App) GatewayState() gateway.StateChange {
+	gw := a.accounts.Current().Gateway
+	if gw == nil {
+		return gateway.StateChange{State: gateway.StateOffline}
+	}
+	return gw.State()
 }
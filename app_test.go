@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hudori-desktop/client"
+	"hudori-desktop/config"
+	"hudori-desktop/fake"
+	"hudori-desktop/gateway"
+	"hudori-desktop/outbox"
+	"hudori-desktop/session"
+)
+
+// recorder collects the events an App emits.
+type recorder struct {
+	mu     sync.Mutex
+	events []event
+}
+
+type event struct {
+	name string
+	data []interface{}
+}
+
+func (r *recorder) emit(name string, data ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event{name: name, data: data})
+}
+
+// wait waits for an event named name whose data matches.
+func (r *recorder) wait(t *testing.T, name string, match func(data interface{}) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		for _, e := range r.events {
+			if e.name == name && len(e.data) > 0 && match(e.data[0]) {
+				r.mu.Unlock()
+				return
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no %s event emitted", name)
+}
+
+// newApp returns an App running against the fake backend seeded with the
+// demo data, without the Wails runtime and without keeping sessions.
+func newApp(t *testing.T) (*App, *recorder, string) {
+	t.Helper()
+	fixture, err := fake.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := fake.New(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(backend)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	cfg, err := config.Load([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.UseDemo(srv.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := &recorder{}
+	a := NewApp(cfg, nil, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	a.ctx = ctx
+	a.emit = events.emit
+	a.warnf = func(string, ...interface{}) {}
+
+	t.Cleanup(func() {
+		cancel()
+		for _, acc := range a.accounts.All() {
+			acc.Close()
+		}
+		backend.Close()
+		srv.Close()
+	})
+	return a, events, srv.URL
+}
+
+func contents(msgs []client.Message) []string {
+	var contents []string
+	for _, msg := range msgs {
+		contents = append(contents, msg.Content)
+	}
+	return contents
+}
+
+// signIn signs in the demo user of that email.
+func signIn(t *testing.T, a *App, email string) client.User {
+	t.Helper()
+	resp := a.SignIn(client.SigninRequest{Email: email, Password: "demo"})
+	if resp.Message != "success" || resp.User == nil {
+		t.Fatalf("SignIn = %+v", resp)
+	}
+	return *resp.User
+}
+
+func TestMessages(t *testing.T) {
+	a, events, url := newApp(t)
+
+	alice := signIn(t, a, "alice@hudori.test")
+	events.wait(t, "gateway:state", func(data interface{}) bool {
+		return data.(gateway.StateChange).State == gateway.StateOnline
+	})
+
+	resp := a.GetMessages(client.MessagesRequest{ChannelID: "general"})
+	if resp.Status >= 300 {
+		t.Fatalf("GetMessages = %+v", resp.Result)
+	}
+	if len(resp.Messages) != 3 {
+		t.Fatalf("GetMessages returned %d messages, want 3", len(resp.Messages))
+	}
+
+	result := a.CreateMessage(client.NewMessage{
+		Author:    alice,
+		ChannelID: "channels:general",
+		ServerID:  "servers:lounge",
+		Content:   "<p>sent from the test</p>",
+	}, nil, nil, true)
+	if result.Status >= 300 {
+		t.Fatalf("CreateMessage = %+v", result)
+	}
+	events.wait(t, "outbox:update", func(data interface{}) bool {
+		return data.(outbox.Entry).State == outbox.StateSent
+	})
+
+	// Messages sent while the account is offline are fetched once it is
+	// back, even when a newer one was received live.
+	a.accounts.Current().Gateway.Close()
+	events.wait(t, "gateway:state", func(data interface{}) bool {
+		return data.(gateway.StateChange).State == gateway.StateOffline
+	})
+
+	bob := client.New(func() string { return url }, nil)
+	_, err := bob.SignIn(context.Background(), client.SigninRequest{Email: "bob@hudori.test", Password: "demo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		_, err := bob.CreateMessage(context.Background(), client.NewMessage{
+			ChannelID: "channels:general",
+			ServerID:  "servers:lounge",
+			Content:   fmt.Sprintf("<p>missed %d</p>", i),
+		}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	missed, err := bob.Messages(context.Background(), client.MessagesRequest{ChannelID: "general"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := missed.Messages[len(missed.Messages)-1]
+	a.recordMessage(a.accounts.Current(), "text_message", live)
+
+	resp = a.GetMessages(client.MessagesRequest{ChannelID: "general"})
+	if resp.Status >= 300 {
+		t.Fatalf("GetMessages = %+v", resp.Result)
+	}
+	got := contents(resp.Messages)
+	want := append(contents(missed.Messages[:3]), "<p>sent from the test</p>", "<p>missed 1</p>", "<p>missed 2</p>")
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetMessages = %q, want %q", got, want)
+	}
+}
+
+func TestRemoveAccount(t *testing.T) {
+	a, _, _ := newApp(t)
+
+	signIn(t, a, "alice@hudori.test")
+	acc := a.accounts.Current()
+	id := acc.ID()
+	path := filepath.Join(a.dataDir, "history", acc.Key()+".db")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("no message history: %v", err)
+	}
+
+	result := a.RemoveAccount(id)
+	if result.Status >= 300 {
+		t.Fatalf("RemoveAccount = %+v", result)
+	}
+	if _, ok := a.accounts.Get(id); ok {
+		t.Error("account still registered")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("message history left at %s", path)
+	}
+}
+
+func TestRemoveAccountOffline(t *testing.T) {
+	a, _, _ := newApp(t)
+
+	signIn(t, a, "alice@hudori.test")
+	acc := a.accounts.Current()
+	id := acc.ID()
+	path := filepath.Join(a.dataDir, "history", acc.Key()+".db")
+
+	// The server cannot be told.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.ctx = ctx
+	var warnings []string
+	a.warnf = func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	resp := a.RemoveAccount(id)
+	if resp.Message != "success" || resp.Warning == "" {
+		t.Fatalf("RemoveAccount = %+v, want success with a warning", resp)
+	}
+	if len(warnings) == 0 {
+		t.Error("failed logout not logged")
+	}
+	if _, ok := a.accounts.Get(id); ok {
+		t.Error("account still registered")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("message history left at %s", path)
+	}
+}
+
+func TestSessionPassphrase(t *testing.T) {
+	// No keyring, and no passphrase to start with.
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(t.TempDir(), "bus"))
+	a, events, _ := newApp(t)
+	dir := t.TempDir()
+	a.sessions = session.NewStore(dir, "")
+	close(a.restored)
+
+	signIn(t, a, "alice@hudori.test")
+	events.wait(t, "session:passphrase", func(data interface{}) bool {
+		return data.(PassphraseResponse).Needed
+	})
+	if resp := a.SessionPassphrase(); !resp.Needed || resp.Unlock {
+		t.Errorf("SessionPassphrase = %+v, want needed to save", resp)
+	}
+
+	if result := a.SetSessionPassphrase("secret"); result.Message != "success" {
+		t.Fatalf("SetSessionPassphrase = %+v", result)
+	}
+	a.saving.Wait()
+	state, err := session.NewStore(dir, "secret").Load()
+	if err != nil || len(state.Sessions) != 1 {
+		t.Fatalf("saved sessions = %+v, %v", state, err)
+	}
+	if _, err := session.NewStore(dir, "").Load(); !errors.Is(err, session.ErrPassphrase) {
+		t.Errorf("Load without the passphrase = %v", err)
+	}
+
+	// Start over, with the saved sessions to unlock.
+	id := a.accounts.Current().ID()
+	acc, _ := a.accounts.Remove(id)
+	acc.Close()
+	a.accounts.Guest(acc.Profile)
+	a.sessions = session.NewStore(dir, "")
+	a.locked.Store(true)
+	a.needPassphrase.Store(true)
+
+	if result := a.SetSessionPassphrase("wrong"); result.Status != 403 {
+		t.Errorf("SetSessionPassphrase with a wrong passphrase = %+v", result)
+	}
+	if result := a.SetSessionPassphrase("secret"); result.Message != "success" {
+		t.Fatalf("SetSessionPassphrase = %+v", result)
+	}
+	if _, ok := a.accounts.Get(id); !ok {
+		t.Error("saved account not resumed")
+	}
+	if resp := a.SessionPassphrase(); resp.Needed || resp.Unlock {
+		t.Errorf("SessionPassphrase = %+v once unlocked", resp)
+	}
+}
+
+func TestMediaSource(t *testing.T) {
+	a, _, url := newApp(t)
+
+	for source, allowed := range map[string]bool{
+		url + "/files/avatar.png":                           true,
+		"https://example.com/a.png":                         false,
+		"http://127.0.0.1:1/a.png":                          false,
+		strings.Replace(url, "http", "https", 1) + "/a.png": false,
+	} {
+		if _, fetch := a.mediaSource(source); (fetch != nil) != allowed {
+			t.Errorf("mediaSource(%s) fetches: %v, want %v", source, fetch != nil, allowed)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package attachment
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// The EXIF tags pointing to other IFDs.
+const (
+	exifIFDTag = 0x8769
+	gpsIFDTag  = 0x8825
+)
+
+// privateTags are the EXIF fields telling where a picture was taken and
+// what with, and what is reported when they are removed. Orientation,
+// dimensions, color and exposure settings are kept.
+var privateTags = map[uint16]string{
+	0x010f: "camera make and model", // Make
+	0x0110: "camera make and model", // Model
+	0x0131: "software",              // Software
+	0x013c: "computer name",         // HostComputer
+	0x8825: "location",              // GPSInfo
+	0x927c: "maker notes",           // MakerNote
+	0xa420: "image ID",              // ImageUniqueID
+	0xa430: "owner name",            // CameraOwnerName
+	0xa431: "serial numbers",        // BodySerialNumber
+	0xa432: "lens",                  // LensSpecification
+	0xa433: "lens",                  // LensMake
+	0xa434: "lens",                  // LensModel
+	0xa435: "serial numbers",        // LensSerialNumber
+	0xc62f: "serial numbers",        // CameraSerialNumber
+}
+
+// typeSizes are the sizes of the EXIF value types, by type number.
+var typeSizes = [...]uint64{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+var errBadEXIF = errors.New("malformed EXIF data")
+
+// exif is EXIF data, in the TIFF format, scrubbed in place: removed
+// entries are taken out of their IFD and their values zeroed, so that no
+// offset changes.
+type exif struct {
+	data    []byte
+	order   binary.ByteOrder
+	visited map[uint32]bool
+	report  *report
+}
+
+// scrubEXIF removes the private fields of EXIF data, in place.
+func scrubEXIF(data []byte, r *report) error {
+	if len(data) < 8 {
+		return errBadEXIF
+	}
+
+	e := &exif{data: data, visited: map[uint32]bool{}, report: r}
+	switch string(data[:2]) {
+	case "II":
+		e.order = binary.LittleEndian
+	case "MM":
+		e.order = binary.BigEndian
+	default:
+		return errBadEXIF
+	}
+
+	// IFD0 describes the picture, and the IFD it links to its thumbnail.
+	offset := e.order.Uint32(data[4:])
+	for offset != 0 {
+		next, err := e.scrubIFD(offset)
+		if err != nil {
+			return err
+		}
+		offset = next
+	}
+
+	return nil
+}
+
+// scrubIFD scrubs the IFD at offset and the Exif IFD it points to, and
+// returns the offset of the next IFD.
+func (e *exif) scrubIFD(offset uint32) (uint32, error) {
+	if e.visited[offset] {
+		return 0, errBadEXIF
+	}
+	e.visited[offset] = true
+
+	start := uint64(offset)
+	if start+2 > uint64(len(e.data)) {
+		return 0, errBadEXIF
+	}
+	count := uint64(e.order.Uint16(e.data[start:]))
+	if start+2+count*12+4 > uint64(len(e.data)) {
+		return 0, errBadEXIF
+	}
+
+	for i := uint64(0); i < count; {
+		entry := start + 2 + i*12
+		tag := e.order.Uint16(e.data[entry:])
+
+		if tag == exifIFDTag {
+			_, err := e.scrubIFD(e.order.Uint32(e.data[entry+8:]))
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		label, private := privateTags[tag]
+		if !private {
+			i++
+			continue
+		}
+
+		if tag == gpsIFDTag {
+			err := e.zeroIFD(e.order.Uint32(e.data[entry+8:]))
+			if err != nil {
+				return 0, err
+			}
+		} else {
+			e.zeroValue(entry)
+		}
+
+		// The entries behind, and the offset of the next IFD, move up.
+		end := start + 2 + count*12 + 4
+		copy(e.data[entry:end], e.data[entry+12:end])
+		clear(e.data[end-12 : end])
+		count--
+		e.order.PutUint16(e.data[start:], uint16(count))
+		e.report.add(label)
+	}
+
+	return e.order.Uint32(e.data[start+2+count*12:]), nil
+}
+
+// zeroIFD zeroes the IFD at offset and the values of its entries.
+func (e *exif) zeroIFD(offset uint32) error {
+	start := uint64(offset)
+	if start+2 > uint64(len(e.data)) {
+		return errBadEXIF
+	}
+	count := uint64(e.order.Uint16(e.data[start:]))
+	end := start + 2 + count*12 + 4
+	if end > uint64(len(e.data)) {
+		return errBadEXIF
+	}
+
+	for i := uint64(0); i < count; i++ {
+		e.zeroValue(start + 2 + i*12)
+	}
+	clear(e.data[start:end])
+
+	return nil
+}
+
+// zeroValue zeroes the value of the entry at offset when it is stored
+// outside of the entry. Values within it go with the entry.
+func (e *exif) zeroValue(entry uint64) {
+	kind := e.order.Uint16(e.data[entry+2:])
+	if int(kind) >= len(typeSizes) {
+		return
+	}
+	size := typeSizes[kind] * uint64(e.order.Uint32(e.data[entry+4:]))
+	if size <= 4 {
+		return
+	}
+
+	offset := uint64(e.order.Uint32(e.data[entry+8:]))
+	if offset+size > uint64(len(e.data)) {
+		return
+	}
+	clear(e.data[offset : offset+size])
+}
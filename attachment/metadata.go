@@ -0,0 +1,288 @@
+package attachment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"hudori-desktop/client"
+
+	"github.com/wailsapp/mimetype"
+)
+
+// Metadata blocks that are removed as a whole, as they mix private fields
+// with the rest in ways not worth untangling.
+const (
+	xmpLabel  = "XMP metadata"
+	iptcLabel = "IPTC metadata"
+	exifLabel = "EXIF metadata"
+)
+
+var (
+	jpegXMP      = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	jpegXMPExt   = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	jpegEXIF     = []byte("Exif\x00\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// report collects what was removed from a file, each kind of field once.
+type report struct {
+	labels []string
+}
+
+func (r *report) add(label string) {
+	for _, l := range r.labels {
+		if l == label {
+			return
+		}
+	}
+	r.labels = append(r.labels, label)
+}
+
+// Scrubbable tells whether Scrub handles files of the given MIME type.
+func Scrubbable(mime string) bool {
+	switch mime {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Scrub removes the location and device fields of the metadata of a JPEG,
+// PNG or WebP picture, and returns the picture without them and what was
+// removed. Pixels are left alone. Other files are returned as they are.
+func Scrub(data []byte) ([]byte, []string, error) {
+	r := &report{}
+
+	var (
+		scrubbed []byte
+		err      error
+	)
+	switch mimetype.Detect(data).String() {
+	case "image/jpeg":
+		scrubbed, err = scrubJPEG(data, r)
+	case "image/png":
+		scrubbed, err = scrubPNG(data, r)
+	case "image/webp":
+		scrubbed, err = scrubWebP(data, r)
+	default:
+		return data, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return scrubbed, r.labels, nil
+}
+
+// ScrubFile scrubs the metadata of file as Scrub does. The scrubbed content
+// is held in memory, as the original file is not the app's to change.
+func ScrubFile(file client.File) (client.File, []string, error) {
+	data := file.Data
+	if file.Path != "" {
+		mime, err := mimetype.DetectFile(file.Path)
+		if err != nil {
+			return file, nil, fmt.Errorf("error reading %s: %w", file.Name, err)
+		}
+		if !Scrubbable(mime.String()) {
+			return file, nil, nil
+		}
+
+		data, err = os.ReadFile(file.Path)
+		if err != nil {
+			return file, nil, fmt.Errorf("error reading %s: %w", file.Name, err)
+		}
+	}
+
+	scrubbed, removed, err := Scrub(data)
+	if err != nil {
+		return file, nil, fmt.Errorf("error removing metadata from %s: %w", file.Name, err)
+	}
+	if len(removed) == 0 {
+		return file, nil, nil
+	}
+
+	return client.File{Name: file.Name, Data: scrubbed}, removed, nil
+}
+
+// scrubJPEG scrubs the EXIF segment of a JPEG and drops its XMP and IPTC
+// segments.
+func scrubJPEG(data []byte, r *report) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errors.New("not a JPEG")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	i := 2
+	for {
+		if i+2 > len(data) || data[i] != 0xff {
+			return nil, errors.New("malformed JPEG")
+		}
+		marker := data[i+1]
+		// Fill bytes may come before a marker.
+		if marker == 0xff {
+			i++
+			continue
+		}
+		// The metadata segments all come before the start of scan, and
+		// the rest of the file is only image data.
+		if marker == 0xda || marker == 0xd9 {
+			return append(out, data[i:]...), nil
+		}
+		if i+4 > len(data) {
+			return nil, errors.New("malformed JPEG")
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			return nil, errors.New("malformed JPEG")
+		}
+
+		segment := data[i+4 : end]
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(segment, jpegEXIF):
+			start := len(out)
+			out = append(out, data[i:end]...)
+			err := scrubEXIF(out[start+4+len(jpegEXIF):], r)
+			if err != nil {
+				out = out[:start]
+				r.add(exifLabel)
+			}
+		case marker == 0xe1 && (bytes.HasPrefix(segment, jpegXMP) || bytes.HasPrefix(segment, jpegXMPExt)):
+			r.add(xmpLabel)
+		case marker == 0xed:
+			r.add(iptcLabel)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+// scrubPNG scrubs the eXIf chunk of a PNG and drops its XMP chunk and
+// the EXIF some tools store as text.
+func scrubPNG(data []byte, r *report) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+
+	for i := len(pngSignature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, errors.New("malformed PNG")
+		}
+		length := uint64(binary.BigEndian.Uint32(data[i:]))
+		end := uint64(i) + 12 + length
+		if end > uint64(len(data)) {
+			return nil, errors.New("malformed PNG")
+		}
+		kind := string(data[i+4 : i+8])
+		body := data[i+8 : i+8+int(length)]
+
+		switch {
+		case kind == "eXIf":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			err := scrubEXIF(out[start+8:start+8+int(length)], r)
+			if err != nil {
+				out = out[:start]
+				r.add(exifLabel)
+				break
+			}
+			binary.BigEndian.PutUint32(out[start+8+int(length):], crc32.ChecksumIEEE(out[start+4:start+8+int(length)]))
+		case kind == "iTXt" || kind == "tEXt" || kind == "zTXt":
+			keyword, _, _ := bytes.Cut(body, []byte{0})
+			switch string(keyword) {
+			case "XML:com.adobe.xmp", "Raw profile type xmp":
+				r.add(xmpLabel)
+			case "Raw profile type exif", "Raw profile type APP1":
+				r.add(exifLabel)
+			case "Raw profile type iptc":
+				r.add(iptcLabel)
+			default:
+				out = append(out, data[i:end]...)
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+
+		if kind == "IEND" {
+			break
+		}
+		i = int(end)
+	}
+
+	return out, nil
+}
+
+// The VP8X flags telling which metadata chunks a WebP has.
+const (
+	webpXMPFlag  = 0x04
+	webpEXIFFlag = 0x08
+)
+
+// scrubWebP scrubs the EXIF chunk of a WebP and drops its XMP chunk.
+func scrubWebP(data []byte, r *report) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("not a WebP")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	flags := -1
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errors.New("malformed WebP")
+		}
+		size := uint64(binary.LittleEndian.Uint32(data[i+4:]))
+		end := uint64(i) + 8 + size + size&1
+		if end > uint64(len(data)) {
+			// The padding byte of the last chunk is sometimes missing.
+			if end-1 != uint64(len(data)) || size&1 == 0 {
+				return nil, errors.New("malformed WebP")
+			}
+			end--
+		}
+		kind := string(data[i : i+4])
+
+		switch kind {
+		case "VP8X":
+			if size >= 1 {
+				flags = len(out) + 8
+			}
+			out = append(out, data[i:end]...)
+		case "EXIF":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			body := out[start+8 : start+8+int(size)]
+			// Some writers keep the JPEG header of the EXIF data.
+			body = bytes.TrimPrefix(body, jpegEXIF)
+			err := scrubEXIF(body, r)
+			if err != nil {
+				out = out[:start]
+				r.add(exifLabel)
+				if flags >= 0 {
+					out[flags] &^= webpEXIFFlag
+				}
+			}
+		case "XMP ":
+			r.add(xmpLabel)
+			if flags >= 0 {
+				out[flags] &^= webpXMPFlag
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = int(end)
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
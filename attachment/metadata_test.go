@@ -0,0 +1,389 @@
+package attachment
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"hudori-desktop/client"
+	"hudori-desktop/imaging"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	orientationTag = 0x0112
+	makeTag        = 0x010f
+)
+
+// byteOrder is the byte order EXIF data is written in.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// entry is an EXIF field. Values over 4 bytes are stored after the IFD.
+type entry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// writeIFD appends an IFD of entries and their values to out, and returns
+// where each entry starts.
+func writeIFD(order byteOrder, out []byte, entries []entry) ([]byte, []int) {
+	start := len(out)
+	dataStart := start + 2 + 12*len(entries) + 4
+	var data []byte
+	var positions []int
+
+	out = order.AppendUint16(out, uint16(len(entries)))
+	for _, e := range entries {
+		positions = append(positions, len(out))
+		out = order.AppendUint16(out, e.tag)
+		out = order.AppendUint16(out, e.kind)
+		out = order.AppendUint32(out, e.count)
+		if len(e.value) <= 4 {
+			value := make([]byte, 4)
+			copy(value, e.value)
+			out = append(out, value...)
+			continue
+		}
+		out = order.AppendUint32(out, uint32(dataStart+len(data)))
+		data = append(data, e.value...)
+	}
+	out = order.AppendUint32(out, 0)
+	return append(out, data...), positions
+}
+
+// tiff returns EXIF data with the fields of ifd0, and a GPS IFD holding
+// gps when it is set.
+func tiff(order byteOrder, ifd0, gps []entry) []byte {
+	out := []byte("MM")
+	if order == binary.LittleEndian {
+		out = []byte("II")
+	}
+	out = order.AppendUint16(out, 42)
+	out = order.AppendUint32(out, 8)
+
+	if gps != nil {
+		ifd0 = append(ifd0, entry{tag: gpsIFDTag, kind: 4, count: 1})
+	}
+	out, positions := writeIFD(order, out, ifd0)
+	if gps != nil {
+		order.PutUint32(out[positions[len(positions)-1]+8:], uint32(len(out)))
+		out, _ = writeIFD(order, out, gps)
+	}
+	return out
+}
+
+// fields returns the tags of IFD0 of EXIF data and their inline values.
+func fields(t *testing.T, data []byte) map[uint16]uint32 {
+	t.Helper()
+	order := binary.ByteOrder(binary.BigEndian)
+	if string(data[:2]) == "II" {
+		order = binary.LittleEndian
+	}
+	offset := order.Uint32(data[4:])
+	count := int(order.Uint16(data[offset:]))
+	tags := map[uint16]uint32{}
+	for i := 0; i < count; i++ {
+		e := data[int(offset)+2+i*12:]
+		value := order.Uint32(e[8:])
+		if order.Uint16(e[2:]) == 3 {
+			value = uint32(order.Uint16(e[8:]))
+		}
+		tags[order.Uint16(e)] = value
+	}
+	return tags
+}
+
+var cameraMake = []byte("Hudori Camera\x00")
+
+// latitude is 48° 51' 24", as three rationals.
+var latitude = []byte{0, 0, 0, 48, 0, 0, 0, 1, 0, 0, 0, 51, 0, 0, 0, 1, 0, 0, 0, 24, 0, 0, 0, 1}
+
+func privateEXIF(order byteOrder) []byte {
+	orientation := make([]byte, 2)
+	order.PutUint16(orientation, 6)
+	return tiff(order,
+		[]entry{
+			{tag: makeTag, kind: 2, count: uint32(len(cameraMake)), value: cameraMake},
+			{tag: orientationTag, kind: 3, count: 1, value: orientation},
+		},
+		[]entry{
+			{tag: 0x0001, kind: 2, count: 2, value: []byte("N\x00")},
+			{tag: 0x0002, kind: 5, count: 3, value: latitude},
+		},
+	)
+}
+
+func plainJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withSegment returns a JPEG with a segment of the given marker added
+// after its start of image.
+func withSegment(jpg []byte, marker byte, body []byte) []byte {
+	segment := []byte{0xff, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(body)+2))
+	segment = append(segment, body...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func plainPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withChunks returns a PNG with chunks added after its header.
+func withChunks(data []byte, chunks ...[]byte) []byte {
+	// The signature and the IHDR chunk.
+	end := len(pngSignature) + 12 + 13
+	out := append([]byte{}, data[:end]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, data[end:]...)
+}
+
+func pngChunk(kind string, body []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	out = append(out, kind...)
+	out = append(out, body...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[4:]))
+}
+
+func riffChunk(kind string, body []byte) []byte {
+	out := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// webpWith returns an extended WebP of an 8x8 picture with the given
+// metadata chunks.
+func webpWith(t *testing.T, exif, xmp []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := imaging.EncodeWebP(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	simple := buf.Bytes()
+
+	vp8x := make([]byte, 10)
+	if exif != nil {
+		vp8x[0] |= webpEXIFFlag
+	}
+	if xmp != nil {
+		vp8x[0] |= webpXMPFlag
+	}
+	vp8x[4], vp8x[7] = 7, 7
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	// The VP8L chunk of the simple file.
+	body = append(body, simple[12:]...)
+	if exif != nil {
+		body = append(body, riffChunk("EXIF", exif)...)
+	}
+	if xmp != nil {
+		body = append(body, riffChunk("XMP ", xmp)...)
+	}
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+// checkEXIF checks that exif lost its make and location and kept its
+// orientation.
+func checkEXIF(t *testing.T, exif []byte) {
+	t.Helper()
+	tags := fields(t, exif)
+	if _, ok := tags[makeTag]; ok {
+		t.Error("camera make kept")
+	}
+	if _, ok := tags[gpsIFDTag]; ok {
+		t.Error("location kept")
+	}
+	if tags[orientationTag] != 6 {
+		t.Errorf("orientation = %d, want 6", tags[orientationTag])
+	}
+	if bytes.Contains(exif, cameraMake[:6]) || bytes.Contains(exif, latitude) {
+		t.Error("values of the removed fields left in the data")
+	}
+}
+
+func TestScrubJPEG(t *testing.T) {
+	for _, order := range []byteOrder{binary.BigEndian, binary.LittleEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			data := withSegment(plainJPEG(t), 0xe1, append(append([]byte{}, jpegEXIF...), privateEXIF(order)...))
+			data = withSegment(data, 0xe1, append(append([]byte{}, jpegXMP...), "<x:xmpmeta/>"...))
+
+			scrubbed, removed, err := Scrub(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, label := range []string{"camera make and model", "location", xmpLabel} {
+				if !slices.Contains(removed, label) {
+					t.Errorf("removed = %q, want %q among them", removed, label)
+				}
+			}
+			if bytes.Contains(scrubbed, []byte("xmpmeta")) {
+				t.Error("XMP kept")
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(scrubbed)); err != nil {
+				t.Fatalf("scrubbed JPEG does not decode: %v", err)
+			}
+
+			i := bytes.Index(scrubbed, jpegEXIF)
+			if i < 0 {
+				t.Fatal("EXIF segment dropped, want it scrubbed")
+			}
+			checkEXIF(t, scrubbed[i+len(jpegEXIF):])
+		})
+	}
+}
+
+func TestScrubPNG(t *testing.T) {
+	data := withChunks(plainPNG(t),
+		pngChunk("eXIf", privateEXIF(binary.BigEndian)),
+		pngChunk("tEXt", []byte("XML:com.adobe.xmp\x00<x:xmpmeta/>")),
+		pngChunk("iTXt", []byte("Raw profile type exif\x00\x00\x00\x00\x00exif")),
+		pngChunk("tEXt", []byte("Comment\x00kept")),
+	)
+
+	scrubbed, removed, err := Scrub(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"camera make and model", "location", xmpLabel, exifLabel} {
+		if !slices.Contains(removed, label) {
+			t.Errorf("removed = %q, want %q among them", removed, label)
+		}
+	}
+	if bytes.Contains(scrubbed, []byte("xmpmeta")) || bytes.Contains(scrubbed, []byte("Raw profile")) {
+		t.Error("text metadata kept")
+	}
+	if !bytes.Contains(scrubbed, []byte("Comment\x00kept")) {
+		t.Error("other text chunks dropped")
+	}
+	// The decoder checks the CRC of every chunk.
+	if _, err := png.Decode(bytes.NewReader(scrubbed)); err != nil {
+		t.Fatalf("scrubbed PNG does not decode: %v", err)
+	}
+
+	i := bytes.Index(scrubbed, []byte("eXIf"))
+	if i < 0 {
+		t.Fatal("eXIf chunk dropped, want it scrubbed")
+	}
+	checkEXIF(t, scrubbed[i+4:])
+}
+
+func TestScrubWebP(t *testing.T) {
+	data := webpWith(t, privateEXIF(binary.LittleEndian), []byte("<x:xmpmeta/>"))
+
+	scrubbed, removed, err := Scrub(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{"camera make and model", "location", xmpLabel} {
+		if !slices.Contains(removed, label) {
+			t.Errorf("removed = %q, want %q among them", removed, label)
+		}
+	}
+	if bytes.Contains(scrubbed, []byte("xmpmeta")) {
+		t.Error("XMP kept")
+	}
+	if flags := scrubbed[20]; flags&webpXMPFlag != 0 || flags&webpEXIFFlag == 0 {
+		t.Errorf("VP8X flags = %#x, want EXIF without XMP", flags)
+	}
+	if got := binary.LittleEndian.Uint32(scrubbed[4:]); int(got) != len(scrubbed)-8 {
+		t.Errorf("RIFF size = %d, want %d", got, len(scrubbed)-8)
+	}
+	if _, err := webp.Decode(bytes.NewReader(scrubbed)); err != nil {
+		t.Fatalf("scrubbed WebP does not decode: %v", err)
+	}
+
+	i := bytes.Index(scrubbed, []byte("EXIF"))
+	if i < 0 {
+		t.Fatal("EXIF chunk dropped, want it scrubbed")
+	}
+	checkEXIF(t, scrubbed[i+8:])
+}
+
+func TestScrubWithoutMetadata(t *testing.T) {
+	tests := map[string][]byte{
+		"jpeg": plainJPEG(t),
+		"png":  plainPNG(t),
+		"webp": webpWith(t, nil, nil),
+		"text": []byte("just some text"),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			scrubbed, removed, err := Scrub(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(removed) != 0 {
+				t.Errorf("removed = %q, want nothing", removed)
+			}
+			if !bytes.Equal(scrubbed, data) {
+				t.Error("file changed")
+			}
+		})
+	}
+}
+
+func TestScrubFile(t *testing.T) {
+	dir := t.TempDir()
+	private := filepath.Join(dir, "private.jpg")
+	data := withSegment(plainJPEG(t), 0xe1, append(append([]byte{}, jpegEXIF...), privateEXIF(binary.BigEndian)...))
+	if err := os.WriteFile(private, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.jpg")
+	if err := os.WriteFile(plain, plainJPEG(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, removed, err := ScrubFile(client.File{Name: "private.jpg", Path: private})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Path != "" || len(file.Data) == 0 || len(removed) == 0 {
+		t.Errorf("ScrubFile = %s with %d bytes, %q, want the scrubbed content in memory", file.Path, len(file.Data), removed)
+	}
+	if original, _ := os.ReadFile(private); !bytes.Equal(original, data) {
+		t.Error("original file changed")
+	}
+
+	file, removed, err = ScrubFile(client.File{Name: "plain.jpg", Path: plain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Path != plain || len(removed) != 0 {
+		t.Errorf("ScrubFile = %s, %q, want the file as it is", file.Path, removed)
+	}
+}
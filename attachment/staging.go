@@ -0,0 +1,148 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"hudori-desktop/client"
+
+	"github.com/wailsapp/mimetype"
+)
+
+// staleAfter is how long a file stays staged when no message claims it,
+// e.g. when it was dropped outside of a conversation.
+const staleAfter = time.Hour
+
+// Staged is a file picked by the user and waiting to be attached to a
+// message. The frontend only holds this handle; the content stays on disk
+// until it is sent.
+type Staged struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// MIME is detected from the content, not from the file name.
+	MIME string `json:"mime"`
+	// Thumbnail is a data URL of a small preview, for images.
+	Thumbnail string `json:"thumbnail,omitempty"`
+	// Metadata lists the private metadata of the file, such as its
+	// location, which is removed when it is sent unless the original is.
+	Metadata []string `json:"metadata,omitempty"`
+
+	path     string
+	stagedAt time.Time
+}
+
+// Batch is the result of staging several files at once. Files that could
+// not be staged are reported in Errors, one line each.
+type Batch struct {
+	Staged []Staged `json:"staged"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Staging holds the staged files of the app.
+type Staging struct {
+	mu     sync.Mutex
+	staged map[string]*Staged
+}
+
+func NewStaging() *Staging {
+	return &Staging{staged: map[string]*Staged{}}
+}
+
+// Add stages the file at path. Files over client.MaxFileSize are refused
+// right away rather than when the message is sent.
+func (s *Staging) Add(path string) (Staged, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Staged{}, fmt.Errorf("error reading %s: %w", filepath.Base(path), err)
+	}
+	if !info.Mode().IsRegular() {
+		return Staged{}, fmt.Errorf("%s is not a file", filepath.Base(path))
+	}
+	if info.Size() > client.MaxFileSize {
+		return Staged{}, fmt.Errorf("%s is larger than %d MB: %w", filepath.Base(path), client.MaxFileSize>>20, client.ErrTooLarge)
+	}
+
+	mime, err := mimetype.DetectFile(path)
+	if err != nil {
+		return Staged{}, fmt.Errorf("error reading %s: %w", filepath.Base(path), err)
+	}
+
+	staged := &Staged{
+		ID:       client.NewID(),
+		Name:     filepath.Base(path),
+		Size:     info.Size(),
+		MIME:     mime.String(),
+		path:     path,
+		stagedAt: time.Now(),
+	}
+	staged.Thumbnail, _ = Thumbnail(path, staged.MIME)
+	if Scrubbable(staged.MIME) {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			_, staged.Metadata, _ = Scrub(data)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	s.staged[staged.ID] = staged
+
+	return *staged, nil
+}
+
+// AddAll stages every file of paths, collecting the errors.
+func (s *Staging) AddAll(paths []string) Batch {
+	batch := Batch{Staged: []Staged{}}
+	for _, path := range paths {
+		staged, err := s.Add(path)
+		if err != nil {
+			batch.Errors = append(batch.Errors, err.Error())
+			continue
+		}
+		batch.Staged = append(batch.Staged, staged)
+	}
+	return batch
+}
+
+// Remove unstages a file. The file itself is left alone.
+func (s *Staging) Remove(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.staged, id)
+	}
+}
+
+// Files returns the staged files with the given ids, ready to be streamed
+// from disk.
+func (s *Staging) Files(ids []string) ([]client.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]client.File, 0, len(ids))
+	for _, id := range ids {
+		staged, ok := s.staged[id]
+		if !ok {
+			return nil, errors.New("attachment is no longer staged, add it again")
+		}
+		files = append(files, client.File{Name: staged.Name, Path: staged.path})
+	}
+	return files, nil
+}
+
+// prune drops the files staged for too long. s.mu must be held.
+func (s *Staging) prune() {
+	for id, staged := range s.staged {
+		if time.Since(staged.stagedAt) > staleAfter {
+			delete(s.staged, id)
+		}
+	}
+}
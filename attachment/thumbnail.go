@@ -0,0 +1,89 @@
+package attachment
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"strings"
+
+	"hudori-desktop/imaging"
+
+	// Decoders for the formats thumbnails are made of.
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// thumbnailSize is the largest side of a thumbnail, in pixels.
+	thumbnailSize = 256
+	// maxPixels keeps huge pictures from being decoded only to make a
+	// thumbnail of them.
+	maxPixels = 50_000_000
+)
+
+// ErrNoThumbnail is returned for files thumbnails are not made of.
+var ErrNoThumbnail = errors.New("no thumbnail for this kind of file")
+
+// Thumbnail returns a JPEG data URL of a small version of the picture at
+// path, whose content has the given MIME type.
+func Thumbnail(path, mime string) (string, error) {
+	switch mime {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return "", ErrNoThumbnail
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening picture: %w", err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading picture: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return "", ErrNoThumbnail
+	}
+
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		return "", fmt.Errorf("error reading picture: %w", err)
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return "", fmt.Errorf("error decoding picture: %w", err)
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, fit(src, thumbnailSize, thumbnailSize), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return "", fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+
+	var url strings.Builder
+	url.WriteString("data:image/jpeg;base64,")
+	url.WriteString(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return url.String(), nil
+}
+
+// fit scales src down, keeping its proportions, so that it fits in a
+// width by height box. Transparent parts are painted white, as JPEG has no
+// transparency.
+func fit(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	w, h := imaging.FitSize(bounds.Dx(), bounds.Dy(), imaging.Target{Width: width, Height: height})
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
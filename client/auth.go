@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SignIn exchanges the user's credentials for a session. On success the
+// cookies set and the user id are kept for the following requests,
+// along with the refresh token the session is renewed with, if any.
+func (c *Client) SignIn(ctx context.Context, req SigninRequest) (UserResponse, error) {
+	var result UserResponse
+
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+
+	jsonBody, err := json.Marshal(req)
+	if err != nil {
+		return result, fmt.Errorf("error marshaling body: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL()+"/auth/signin", bytes.NewReader(jsonBody))
+	if err != nil {
+		return result, fmt.Errorf("error creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.send(httpReq)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("error reading response: %w", err)
+	}
+	var tokens sessionTokens
+	err = json.Unmarshal(data, &result)
+	if err == nil {
+		err = json.Unmarshal(data, &tokens)
+	}
+	if err != nil && len(data) > 0 && resp.StatusCode == http.StatusOK {
+		return result, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		result.fail(resp.StatusCode)
+		return result, nil
+	}
+
+	if result.User != nil {
+		c.setUser(result.User.ID)
+		c.SetRefreshToken(tokens.RefreshToken)
+	}
+
+	return result, nil
+}
+
+// Verify checks that the current session is still valid and returns the
+// user it belongs to.
+func (c *Client) Verify(ctx context.Context) (UserResponse, error) {
+	var result UserResponse
+	err := c.do(ctx, "GET", "/auth/verify", nil, &result)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Message == "success" && result.User != nil {
+		c.setUser(result.User.ID)
+	}
+
+	return result, nil
+}
+
+// Logout ends the session on the server and forgets it locally.
+func (c *Client) Logout(ctx context.Context) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/user/logout", nil, &result)
+	if err != nil {
+		return result, err
+	}
+
+	c.clearSession()
+	c.setUser("")
+	c.SetRefreshToken("")
+
+	return result, nil
+}
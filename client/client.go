@@ -0,0 +1,257 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Client talks to the Hudori REST API on behalf of one user. Each client
+// has its own cookie jar, so several users can be signed in side by side.
+// The session is the SessionCookie of the jar.
+type Client struct {
+	baseURL func() string
+	http    *http.Client
+	jar     *Jar
+
+	mu           sync.RWMutex
+	userID       string
+	refreshToken string
+	// renewal is the renewal of the session under way, if any.
+	renewal   *renewal
+	onExpired func(renewed bool)
+}
+
+// New returns a client whose requests go to the URL returned by baseURL,
+// which is resolved again for every request. Its connections are those of
+// rt, or of the transport shared by all clients when rt is nil.
+func New(baseURL func() string, rt http.RoundTripper) *Client {
+	if rt == nil {
+		rt = transport
+	}
+	jar := NewJar()
+
+	return &Client{
+		baseURL: baseURL,
+		http:    &http.Client{Jar: jar, Transport: rt},
+		jar:     jar,
+	}
+}
+
+// SetSession sets the credentials sent with every request: the session
+// cookie, for sessions saved without their cookies, and the user id.
+func (c *Client) SetSession(sessionID, userID string) {
+	if sessionID != "" {
+		if api, err := url.Parse(c.baseURL()); err == nil {
+			c.jar.SetCookies(api, []*http.Cookie{{Name: SessionCookie, Value: sessionID, Path: "/"}})
+		}
+	}
+	c.setUser(userID)
+}
+
+func (c *Client) setUser(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.userID = userID
+}
+
+// SetCookies puts cookies saved from the jar of a previous run back.
+func (c *Client) SetCookies(cookies []Cookie) {
+	c.jar.Load(cookies)
+}
+
+// Cookies returns the cookies of the jar to save.
+func (c *Client) Cookies() []Cookie {
+	return c.jar.Saved()
+}
+
+// OnCookies sets the function called when the server set or removed a
+// cookie, e.g. to rotate the session or a CSRF token.
+func (c *Client) OnCookies(f func()) {
+	c.jar.OnChange(f)
+}
+
+// clearSession forgets the session and every other cookie.
+func (c *Client) clearSession() {
+	c.jar.Clear()
+}
+
+// SetRefreshToken sets the token the session is renewed with once it
+// expires, empty if the backend gave none.
+func (c *Client) SetRefreshToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshToken = token
+}
+
+// RefreshToken returns the token the session is renewed with.
+func (c *Client) RefreshToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.refreshToken
+}
+
+// Session returns the current session id and user id.
+func (c *Client) Session() (string, string) {
+	sessionID := c.session()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return sessionID, c.userID
+}
+
+// session returns the session cookie sent to the API.
+func (c *Client) session() string {
+	api, err := url.Parse(c.baseURL())
+	if err != nil {
+		return ""
+	}
+	return c.jar.Value(api, SessionCookie)
+}
+
+// Jar returns the cookie jar of the client, so other connections made for
+// the same user carry the same cookies.
+func (c *Client) Jar() http.CookieJar {
+	return c.jar
+}
+
+// Authenticated reports whether the client holds any credentials.
+func (c *Client) Authenticated() bool {
+	sessionID, userID := c.Session()
+	return sessionID != "" || userID != ""
+}
+
+func (c *Client) authFetch(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	url := c.baseURL() + path
+
+	var req *http.Request
+	var err error
+
+	if multipartBody, ok := body.(MultipartData); ok {
+		stream, contentType, length, err := multipartBody.stream()
+		if err != nil {
+			return nil, err
+		}
+
+		req, err = http.NewRequestWithContext(ctx, method, url, stream)
+		if err != nil {
+			stream.Close()
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		req.ContentLength = length
+		req.Header.Set("Content-Type", contentType)
+	} else {
+		var bodyReader io.Reader
+		if body != nil {
+			jsonBody, err := json.Marshal(body)
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling body: %w", err)
+			}
+			bodyReader = bytes.NewBuffer(jsonBody)
+		}
+
+		req, err = http.NewRequestWithContext(ctx, method, url, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	_, userID := c.Session()
+	req.Header.Set("X-User-ID", userID)
+
+	return c.send(req)
+}
+
+// Fetch GETs url with the given headers and returns the response, whose
+// body the caller closes. The URL may be outside of the API, e.g. an
+// attachment on a CDN; the cookies of the jar go where they belong, and
+// the user id only to the host of the API. Like the calls to the API, it
+// is retried on the way and refused while the host is known to be down.
+func (c *Client) Fetch(ctx context.Context, rawURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if c.isAPI(req.URL) {
+		_, userID := c.Session()
+		req.Header.Set("X-User-ID", userID)
+	}
+
+	return c.send(req)
+}
+
+// Authenticates reports whether Fetch sends credentials of the user with
+// a request for rawURL: the user id, to the host of the API, or cookies.
+func (c *Client) Authenticates(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return c.isAPI(u) || len(c.jar.Cookies(u)) > 0
+}
+
+// isAPI reports whether u is on the host of the API.
+func (c *Client) isAPI(u *url.URL) bool {
+	api, err := url.Parse(c.baseURL())
+	return err == nil && api.Scheme == u.Scheme && api.Host == u.Host
+}
+
+type failer interface {
+	fail(status int)
+}
+
+// do sends an authenticated request and decodes the JSON response into out.
+// Error responses are decoded as well so the caller sees the server's
+// message, and completed into a failed Result; only transport and decoding
+// failures are returned as errors. A request refused because the session
+// expired is sent again once it is renewed, see renew.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	if d := timeout(path, body); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	c.awaitRenewal(ctx)
+	sessionID, _ := c.Session()
+	resp, err := c.authFetch(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if expired(resp.StatusCode) && sessionID != "" && c.renew(ctx, sessionID) {
+		resp.Body.Close()
+		resp, err = c.authFetch(ctx, method, path, body)
+		if err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	// Error bodies that are not JSON, e.g. the pages of a proxy, are
+	// described by their status alone.
+	if err != nil && !errors.Is(err, io.EOF) && resp.StatusCode < 300 {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		if r, ok := out.(failer); ok {
+			r.fail(resp.StatusCode)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// The codes of a failed Result, for the frontend to tell failures apart
+// without reading their message.
+const (
+	// CodeValidation is a request the server refused as it was, e.g. a
+	// form with a wrong value.
+	CodeValidation   = "validation"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeRateLimited  = "rate_limited"
+	// CodeNetwork is a server that could not be reached.
+	CodeNetwork = "network"
+	// CodeServer is a failure on the other end of the call: the Hudori
+	// server, or the app itself for the frontend.
+	CodeServer = "server"
+)
+
+// Success returns the result of a call that went through.
+func Success() Result {
+	return Result{Message: "success"}
+}
+
+// Fail returns a failure with the given HTTP status.
+func Fail(status int, message string) Result {
+	return Result{Status: status, Code: code(status), Message: message}
+}
+
+// FieldError returns a failure caused by the value of a form field.
+func FieldError(status int, field, message string) Result {
+	r := Fail(status, message)
+	r.Name = field
+	r.Fields = map[string]string{field: message}
+	return r
+}
+
+// Failure returns the result of a call that could not be completed: a
+// network failure when the server could not be reached or is known to be
+// down, a server one otherwise.
+func Failure(message string, err error) Result {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) || errors.Is(err, ErrUnavailable) {
+		return Result{Code: CodeNetwork, Message: message + ": " + err.Error()}
+	}
+	return Fail(http.StatusInternalServerError, message+": "+err.Error())
+}
+
+// code returns the code of a failure with the given HTTP status.
+func code(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeUnauthorized
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status >= 400 && status < 500:
+		return CodeValidation
+	default:
+		return CodeServer
+	}
+}
+
+// fail completes the result of a call the server answered with an error
+// status, from whatever part of the envelope the body held.
+func (r *Result) fail(status int) {
+	r.Status = status
+	r.Code = code(status)
+	if r.Name != "" && r.Fields == nil {
+		r.Fields = map[string]string{r.Name: r.Message}
+	}
+	if r.Name == "" {
+		for field := range r.Fields {
+			if r.Name == "" || field < r.Name {
+				r.Name = field
+			}
+		}
+	}
+	if r.Message == "" || r.Message == "success" {
+		r.Message = http.StatusText(status)
+	}
+}
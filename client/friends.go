@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+func (c *Client) Friends(ctx context.Context, userID string) (FriendsResponse, error) {
+	var result FriendsResponse
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/friends/%s", userID), nil, &result)
+	return result, err
+}
+
+func (c *Client) AddFriend(ctx context.Context, req AddFriendRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/friends/add", req, &result)
+	return result, err
+}
+
+func (c *Client) AcceptFriend(ctx context.Context, req FriendRequestReply) (FriendResponse, error) {
+	var result FriendResponse
+	err := c.do(ctx, "POST", "/api/v1/friends/accept", req, &result)
+	return result, err
+}
+
+func (c *Client) RefuseFriend(ctx context.Context, req FriendRequestReply) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/friends/refuse", req, &result)
+	return result, err
+}
+
+func (c *Client) DeleteFriend(ctx context.Context, req DeleteFriendRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/friends/delete", req, &result)
+	return result, err
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SessionCookie names the cookie holding the session.
+const SessionCookie = "session"
+
+// Cookie is a cookie as it is saved, with the URL of the response that set
+// it.
+type Cookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  *time.Time    `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+// cookieKey identifies a cookie the way browsers do: a cookie set again
+// with the same key replaces the previous one.
+type cookieKey struct {
+	domain, path, name string
+}
+
+// Jar is a cookie jar whose cookies can be saved and loaded back. The
+// rules of which cookie goes where, their expiry and the Secure flag are
+// those of net/http/cookiejar; Jar keeps a copy of what it was given.
+type Jar struct {
+	mu       sync.Mutex
+	jar      *cookiejar.Jar
+	cookies  map[cookieKey]Cookie
+	onChange func()
+}
+
+// NewJar returns an empty jar.
+func NewJar() *Jar {
+	return &Jar{
+		jar:     newCookieJar(),
+		cookies: make(map[cookieKey]Cookie),
+	}
+}
+
+func newCookieJar() *cookiejar.Jar {
+	// cookiejar.New never fails.
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if j.set(u, cookies) {
+		j.mu.Lock()
+		onChange := j.onChange
+		j.mu.Unlock()
+		if onChange != nil {
+			onChange()
+		}
+	}
+}
+
+// set stores cookies and reports whether any of them changed.
+func (j *Jar) set(u *url.URL, cookies []*http.Cookie) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	changed := false
+	for _, c := range cookies {
+		key := cookieKey{domain: strings.TrimPrefix(strings.ToLower(c.Domain), "."), path: c.Path, name: c.Name}
+		if key.domain == "" {
+			key.domain = u.Hostname()
+		}
+		if !strings.HasPrefix(key.path, "/") {
+			key.path = defaultPath(u.Path)
+		}
+
+		var expires *time.Time
+		switch {
+		case c.MaxAge > 0:
+			t := now.Add(time.Duration(c.MaxAge) * time.Second)
+			expires = &t
+		case c.MaxAge == 0 && !c.Expires.IsZero():
+			t := c.Expires
+			expires = &t
+		}
+
+		if c.MaxAge < 0 || (expires != nil && !expires.After(now)) {
+			if _, ok := j.cookies[key]; ok {
+				delete(j.cookies, key)
+				changed = true
+			}
+			continue
+		}
+
+		saved := Cookie{
+			URL:      origin,
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: c.SameSite,
+		}
+		if previous, ok := j.cookies[key]; !ok || previous.Value != saved.Value || !sameTime(previous.Expires, expires) {
+			changed = true
+		}
+		j.cookies[key] = saved
+	}
+
+	return changed
+}
+
+// defaultPath is the path of a cookie set without one from path, as in
+// RFC 6265 section 5.1.4.
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Saved returns the cookies of the jar that have not expired.
+func (j *Jar) Saved() []Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	saved := make([]Cookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		if c.Expires == nil || c.Expires.After(now) {
+			saved = append(saved, c)
+		}
+	}
+	return saved
+}
+
+// Load puts saved cookies back in the jar.
+func (j *Jar) Load(saved []Cookie) {
+	for _, c := range saved {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			continue
+		}
+		cookie := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: c.SameSite,
+		}
+		if c.Expires != nil {
+			cookie.Expires = *c.Expires
+		}
+		j.set(u, []*http.Cookie{cookie})
+	}
+}
+
+// Value returns the value of the cookie name sent to u, if any.
+func (j *Jar) Value(u *url.URL, name string) string {
+	for _, c := range j.Cookies(u) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// Clear drops every cookie.
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	changed := len(j.cookies) > 0
+	j.jar = newCookieJar()
+	j.cookies = make(map[cookieKey]Cookie)
+	onChange := j.onChange
+	j.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange()
+	}
+}
+
+// OnChange sets the function called when cookies were added, changed or
+// removed, for them to be saved.
+func (j *Jar) OnChange(f func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.onChange = f
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Messages returns the messages of a server channel, or of the private
+// conversation with req.UserID when it is set. Servers that do not page
+// ignore After, Before and Limit and return every message.
+func (c *Client) Messages(ctx context.Context, req MessagesRequest) (MessagesResponse, error) {
+	path := fmt.Sprintf("/api/v1/messages/%s", req.ChannelID)
+	if req.UserID != "" {
+		path = fmt.Sprintf("/api/v1/messages/%s/private/%s", req.ChannelID, req.UserID)
+	}
+
+	query := url.Values{}
+	if req.After != "" {
+		query.Set("after", req.After)
+	}
+	if req.Before != "" {
+		query.Set("before", req.Before)
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var result MessagesResponse
+	err := c.do(ctx, "GET", path, nil, &result)
+	return result, err
+}
+
+// CreateMessage sends a message with its attachments, which are streamed
+// from memory or disk. progress, if not nil, is called as they are sent.
+// Attachments over the size limits are refused with status 413 before
+// anything is sent.
+func (c *Client) CreateMessage(ctx context.Context, msg NewMessage, files []File, progress func(UploadProgress)) (Result, error) {
+	err := CheckUpload(files)
+	if errors.Is(err, ErrTooLarge) {
+		return FieldError(http.StatusRequestEntityTooLarge, "files", err.Error()), nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	jsonData, err := json.Marshal(msg)
+	if err != nil {
+		return Result{}, fmt.Errorf("error marshaling message: %w", err)
+	}
+
+	body := MultipartData{
+		Fields:   map[string]string{"body": string(jsonData)},
+		Files:    make([]FormFile, 0, len(files)),
+		Progress: progress,
+	}
+	for i, file := range files {
+		body.Files = append(body.Files, FormFile{Field: fmt.Sprintf("file-%d", i), File: file})
+	}
+
+	var result Result
+	err = c.do(ctx, "POST", "/api/v1/messages/create", body, &result)
+	return result, err
+}
+
+func (c *Client) EditMessage(ctx context.Context, req EditMessageRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "PUT", "/api/v1/messages/edit", req, &result)
+	return result, err
+}
+
+func (c *Client) DeleteMessage(ctx context.Context, req DeleteMessageRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "DELETE", "/api/v1/messages/delete", req, &result)
+	return result, err
+}
+
+func (c *Client) Typing(ctx context.Context, req TypingRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/channels/typing", req, &result)
+	return result, err
+}
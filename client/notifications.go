@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+func (c *Client) Notifications(ctx context.Context, userID string) (NotificationsResponse, error) {
+	var result NotificationsResponse
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/notifications/%s", userID), nil, &result)
+	return result, err
+}
+
+// SyncNotifications marks the new_message notifications of the given
+// channels as read.
+func (c *Client) SyncNotifications(ctx context.Context, req SyncNotificationsRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/notifications/message_update", req, &result)
+	return result, err
+}
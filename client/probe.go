@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ProbeResult tells how a backend was reached.
+type ProbeResult struct {
+	// Proxy is the proxy the request went through, without its password,
+	// or empty when it went direct.
+	Proxy string `json:"proxy,omitempty"`
+	// Status is the HTTP status the backend answered with.
+	Status    int   `json:"status,omitempty"`
+	LatencyMS int64 `json:"latency_ms,omitempty"`
+	// WebsocketLatencyMS is how long the websocket endpoint took to answer
+	// the handshake, through the same proxy.
+	WebsocketLatencyMS int64 `json:"websocket_latency_ms,omitempty"`
+}
+
+// Probe sends a request to the backend at baseURL through rt, whatever
+// the state of its circuit breaker, and reports which proxy it went
+// through and how long the backend took to answer. Any answer means that
+// it was reached.
+func Probe(ctx context.Context, rt *http.Transport, baseURL string) (ProbeResult, error) {
+	var result ProbeResult
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/", nil)
+	if err != nil {
+		return result, fmt.Errorf("error creating request: %w", err)
+	}
+
+	if rt.Proxy != nil {
+		proxy, err := rt.Proxy(req)
+		if err != nil {
+			return result, fmt.Errorf("error choosing proxy: %w", err)
+		}
+		if proxy != nil {
+			result.Proxy = proxy.Redacted()
+		}
+	}
+
+	client := &http.Client{
+		Transport: rt,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("error sending request: %w", err)
+	}
+	resp.Body.Close()
+
+	result.Status = resp.StatusCode
+	result.LatencyMS = time.Since(start).Milliseconds()
+
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package client
+
+type SigninRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UserRequest is used by every endpoint that only needs a user id.
+type UserRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// MessagesRequest selects the messages of a conversation. After and Before
+// are message ids; when set, only the messages newer than After or older
+// than Before are requested, at most Limit of them.
+type MessagesRequest struct {
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id,omitempty"`
+	After     string `json:"after,omitempty"`
+	Before    string `json:"before,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+}
+
+// SearchRequest is a search of the stored messages. Query holds words and
+// filters, e.g. "lunch from:alice has:image".
+type SearchRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type ServerRequest struct {
+	UserID   string `json:"user_id"`
+	ServerID string `json:"server_id"`
+}
+
+type TypingRequest struct {
+	UserID      string `json:"user_id"`
+	ChannelID   string `json:"channel_id"`
+	DisplayName string `json:"display_name"`
+	Status      string `json:"status"`
+}
+
+type SyncNotificationsRequest struct {
+	UserID   string   `json:"user_id"`
+	Channels []string `json:"channels"`
+}
+
+type JoinServerRequest struct {
+	User     User   `json:"user"`
+	InviteID string `json:"invite_id"`
+}
+
+type CreateServerRequest struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type CategoryRequest struct {
+	ServerID     string `json:"server_id"`
+	CategoryName string `json:"category_name"`
+}
+
+type CreateChannelRequest struct {
+	Name         string `json:"name"`
+	ChannelType  string `json:"channel_type"`
+	CategoryName string `json:"category_name"`
+	ServerID     string `json:"server_id"`
+}
+
+type DeleteChannelRequest struct {
+	ChannelID    string `json:"channel_id"`
+	CategoryName string `json:"category_name"`
+	ServerID     string `json:"server_id"`
+}
+
+type DeleteFriendRequest struct {
+	UserID   string `json:"user_id"`
+	FriendID string `json:"friend_id"`
+}
+
+// FriendRequestReply accepts or refuses the friend request RequestID,
+// ID being the notification that carried it.
+type FriendRequestReply struct {
+	ID        string `json:"id"`
+	RequestID string `json:"request_id"`
+}
+
+type AddFriendRequest struct {
+	InitiatorID       string `json:"initiator_id"`
+	InitiatorUsername string `json:"initiator_username"`
+	ReceiverUsername  string `json:"receiver_username"`
+}
+
+type NewMessage struct {
+	Author         User     `json:"author"`
+	ChannelID      string   `json:"channel_id"`
+	Content        string   `json:"content"`
+	Mentions       []string `json:"mentions"`
+	Reply          string   `json:"reply"`
+	PrivateMessage bool     `json:"private_message"`
+	ServerID       string   `json:"server_id,omitempty"`
+}
+
+type DeleteMessageRequest struct {
+	ChannelID      string `json:"channel_id"`
+	MessageID      string `json:"message_id"`
+	PrivateMessage bool   `json:"private_message"`
+	AuthorID       string `json:"author_id"`
+}
+
+type EditMessageRequest struct {
+	ChannelID      string   `json:"channel_id"`
+	MessageID      string   `json:"message_id"`
+	PrivateMessage bool     `json:"private_message"`
+	AuthorID       string   `json:"author_id"`
+	Content        string   `json:"content"`
+	Mentions       []string `json:"mentions"`
+}
+
+// Crop is the region of an uploaded picture the server keeps.
+type Crop struct {
+	X      int `json:"cropX"`
+	Y      int `json:"cropY"`
+	Width  int `json:"cropWidth"`
+	Height int `json:"cropHeight"`
+}
+
+type BannerChangeRequest struct {
+	Crop
+	FileData  []byte `json:"fileData"`
+	FileName  string `json:"fileName"`
+	OldBanner string `json:"oldBanner"`
+}
+
+type AvatarChangeRequest struct {
+	Crop
+	FileData  []byte   `json:"fileData"`
+	FileName  string   `json:"fileName"`
+	OldAvatar string   `json:"oldAvatar"`
+	ServerID  string   `json:"serverId,omitempty"`
+	Friends   []string `json:"friends,omitempty"`
+}
+
+type NameColorRequest struct {
+	UserID        string `json:"user_id"`
+	UsernameColor string `json:"username_color"`
+}
+
+// StatusRequest sets the status the friends of a user see: online,
+// absent, dontdisturb or offline.
+type StatusRequest struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+type DisplayNameRequest struct {
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+}
+
+type UsernameRequest struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type EmailRequest struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// RefreshRequest exchanges a refresh token for a new session.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,87 @@
+package client
+
+// Result holds the fields every Hudori response shares, and is the error
+// envelope of every App method. Successful calls carry the message
+// "success". Failures carry a Code saying what went wrong, the HTTP
+// status, if any, and for form errors the fields at fault: Name is the
+// first of them, kept for the forms that read a single one.
+type Result struct {
+	Status  int               `json:"status,omitempty"`
+	Code    string            `json:"code,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Message string            `json:"message"`
+}
+
+type UserResponse struct {
+	Result
+	User *User `json:"user,omitempty"`
+}
+
+type FriendsResponse struct {
+	Result
+	Friends []User `json:"friends"`
+}
+
+type FriendResponse struct {
+	Result
+	Friend *User `json:"friend,omitempty"`
+}
+
+// SearchResponse holds the best matches of a search, best first, and how
+// many messages matched in total.
+type SearchResponse struct {
+	Result
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+}
+
+type ServersResponse struct {
+	Result
+	Servers []Server `json:"servers"`
+}
+
+type ServerResponse struct {
+	Result
+	Server *Server `json:"server,omitempty"`
+}
+
+// MessagesResponse holds messages oldest first. HasMore is set by the app,
+// not the server, when older messages can be loaded.
+type MessagesResponse struct {
+	Result
+	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"has_more,omitempty"`
+}
+
+type NotificationsResponse struct {
+	Result
+	Notifications []Notification `json:"notifications"`
+}
+
+type InvitationResponse struct {
+	Result
+	ID string `json:"id,omitempty"`
+}
+
+type AvatarResponse struct {
+	Result
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type BannerResponse struct {
+	Result
+	Banner string `json:"banner,omitempty"`
+}
+
+type RoomTokenResponse struct {
+	Result
+	Token string `json:"token,omitempty"`
+}
+
+// ProbeResponse is the outcome of a connectivity test. Probe is set even
+// when the backend could not be reached, to tell which proxy was tried.
+type ProbeResponse struct {
+	Result
+	Probe *ProbeResult `json:"probe,omitempty"`
+}
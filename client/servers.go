@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+func (c *Client) Servers(ctx context.Context, userID string) (ServersResponse, error) {
+	var result ServersResponse
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/servers/%s", userID), nil, &result)
+	return result, err
+}
+
+func (c *Client) Server(ctx context.Context, req ServerRequest) (ServerResponse, error) {
+	var result ServerResponse
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/server/%s/%s", req.UserID, req.ServerID), nil, &result)
+	return result, err
+}
+
+func (c *Client) CreateServer(ctx context.Context, req CreateServerRequest) (ServerResponse, error) {
+	var result ServerResponse
+	err := c.do(ctx, "POST", "/api/v1/server/create", req, &result)
+	return result, err
+}
+
+func (c *Client) JoinServer(ctx context.Context, req JoinServerRequest) (ServerResponse, error) {
+	var result ServerResponse
+	err := c.do(ctx, "POST", "/api/v1/server/join", req, &result)
+	return result, err
+}
+
+func (c *Client) DeleteServer(ctx context.Context, req ServerRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/server/delete", req, &result)
+	return result, err
+}
+
+func (c *Client) QuitServer(ctx context.Context, req ServerRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/server/leave", req, &result)
+	return result, err
+}
+
+func (c *Client) CreateInvitation(ctx context.Context, req ServerRequest) (InvitationResponse, error) {
+	var result InvitationResponse
+	err := c.do(ctx, "POST", "/api/v1/invites/create", req, &result)
+	return result, err
+}
+
+func (c *Client) CreateCategory(ctx context.Context, req CategoryRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/category/create", req, &result)
+	return result, err
+}
+
+func (c *Client) DeleteCategory(ctx context.Context, req CategoryRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/category/delete", req, &result)
+	return result, err
+}
+
+func (c *Client) CreateChannel(ctx context.Context, req CreateChannelRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/channels/create", req, &result)
+	return result, err
+}
+
+func (c *Client) DeleteChannel(ctx context.Context, req DeleteChannelRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/channels/delete", req, &result)
+	return result, err
+}
+
+func (c *Client) RoomToken(ctx context.Context, channelID, userID string) (RoomTokenResponse, error) {
+	var result RoomTokenResponse
+	err := c.do(ctx, "POST", fmt.Sprintf("/api/v1/rtc/%s/%s", channelID, userID), nil, &result)
+	return result, err
+}
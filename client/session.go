@@ -0,0 +1,173 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// statusSessionExpired is the status some backends answer with once the
+// session expired, instead of 401.
+const statusSessionExpired = 419
+
+// errNoRefresh is the renewal of a session the backend gave no refresh
+// token for.
+var errNoRefresh = errors.New("no refresh token")
+
+// renewal is the exchange of the refresh token for a new session, which
+// the requests refused in the meantime wait for.
+type renewal struct {
+	done chan struct{}
+	ok   bool
+}
+
+// OnExpired sets the function called once the session expired and its
+// renewal was attempted. renewed tells whether the client holds a new
+// session; when it does not, the user has to sign in again.
+func (c *Client) OnExpired(f func(renewed bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onExpired = f
+}
+
+// expired reports whether a response with the given status means that
+// the session is no longer valid.
+func expired(status int) bool {
+	return status == http.StatusUnauthorized || status == statusSessionExpired
+}
+
+// awaitRenewal waits for the renewal of the session under way, if any, so
+// that requests are not sent with a session known to be expired.
+func (c *Client) awaitRenewal(ctx context.Context) {
+	c.mu.RLock()
+	r := c.renewal
+	c.mu.RUnlock()
+	if r == nil {
+		return
+	}
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
+
+// renew renews the session stale, which a request was refused with, and
+// reports whether the request can be sent again. Only one renewal runs at
+// a time: the requests refused meanwhile wait for its outcome, and those
+// refused with a session renewed since are sent again at once.
+func (c *Client) renew(ctx context.Context, stale string) bool {
+	c.mu.Lock()
+	if sessionID := c.session(); sessionID != stale {
+		c.mu.Unlock()
+		return sessionID != ""
+	}
+	r := c.renewal
+	if r == nil {
+		r = &renewal{done: make(chan struct{})}
+		c.renewal = r
+		// The renewal outlives the request that started it, so that
+		// canceling that request does not end the session.
+		go c.refresh(r)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// refresh exchanges the refresh token for a new session and completes r.
+// The session is dropped when that fails.
+func (c *Client) refresh(r *renewal) {
+	ctx, cancel := context.WithTimeout(context.Background(), authTimeout)
+	defer cancel()
+
+	err := c.exchange(ctx)
+	r.ok = err == nil
+
+	if !r.ok {
+		c.clearSession()
+	}
+
+	c.mu.Lock()
+	if !r.ok {
+		c.refreshToken = ""
+	}
+	c.renewal = nil
+	onExpired := c.onExpired
+	c.mu.Unlock()
+
+	close(r.done)
+	if onExpired != nil {
+		onExpired(r.ok)
+	}
+}
+
+// exchange trades the refresh token for a new session.
+func (c *Client) exchange(ctx context.Context) error {
+	token := c.RefreshToken()
+	if token == "" {
+		return errNoRefresh
+	}
+
+	jsonBody, err := json.Marshal(RefreshRequest{RefreshToken: token})
+	if err != nil {
+		return fmt.Errorf("error marshaling body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL()+"/auth/refresh", bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error refreshing session: %s", resp.Status)
+	}
+
+	var tokens sessionTokens
+	err = json.NewDecoder(resp.Body).Decode(&tokens)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if sessionCookie(resp) == "" {
+		return errors.New("error refreshing session: no session in response")
+	}
+	if tokens.RefreshToken != "" {
+		c.SetRefreshToken(tokens.RefreshToken)
+	}
+
+	return nil
+}
+
+// sessionTokens is the part of the sign-in and refresh responses the
+// client keeps to itself.
+type sessionTokens struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// sessionCookie returns the session set by resp, if any. The jar of the
+// client already holds it.
+func sessionCookie(resp *http.Response) string {
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == SessionCookie {
+			return cookie.Value
+		}
+	}
+	return ""
+}
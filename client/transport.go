@@ -0,0 +1,312 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Timeouts of a call to the API, from sending the request to reading the
+// whole response. Uploads have none, as they may take long on a slow link;
+// the transport still gives up on a server that does not answer them.
+const (
+	authTimeout = 10 * time.Second
+	callTimeout = 20 * time.Second
+)
+
+// Retries of the calls that failed on the way, with a delay growing from
+// minRetryDelay. A Retry-After longer than maxRetryAfter is not waited for.
+const (
+	maxAttempts   = 3
+	minRetryDelay = 250 * time.Millisecond
+	maxRetryAfter = 30 * time.Second
+)
+
+// The circuit breaker of a backend opens after breakerThreshold failed
+// calls in a row, for breakerCooldown, doubling up to maxBreakerCooldown
+// while the calls let through to probe it keep failing.
+const (
+	breakerThreshold   = 5
+	breakerCooldown    = 2 * time.Second
+	maxBreakerCooldown = time.Minute
+)
+
+// ErrUnavailable is returned without sending anything while the backend is
+// known to be down.
+var ErrUnavailable = errors.New("backend unavailable")
+
+// transport is shared by the clients of every account that use the
+// default TLS configuration, so connections to a backend are reused
+// whoever makes the call.
+var transport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// NewTransport returns a transport tuned like the shared one, for the
+// clients of a backend with its own TLS configuration and proxy.
+func NewTransport(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	t := transport.Clone()
+	t.TLSClientConfig = tlsConfig
+	t.Proxy = proxy
+	return t
+}
+
+// ProxyFunc returns the function choosing the proxy of each request: the
+// HTTP or SOCKS5 proxy at proxyURL, except for the hosts of bypass, or the
+// proxy of the environment when proxyURL is empty. bypass is in the format
+// of NO_PROXY.
+func ProxyFunc(proxyURL string, bypass []string) func(*http.Request) (*url.URL, error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment
+	}
+
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  proxyURL,
+		HTTPSProxy: proxyURL,
+		NoProxy:    strings.Join(bypass, ","),
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
+
+// timeout returns the timeout of a call to path with the given body, or 0
+// for none.
+func timeout(path string, body interface{}) time.Duration {
+	if _, ok := body.(MultipartData); ok {
+		return 0
+	}
+	if strings.HasPrefix(path, "/auth/") {
+		return authTimeout
+	}
+	return callTimeout
+}
+
+// send sends a request to the API. Idempotent requests are retried when
+// they fail on the way, and any request whose body can be sent again when
+// the server asks to come back later with a Retry-After. Nothing is sent
+// while the circuit breaker of the backend is open.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	b := breakerOf(req.URL.Host)
+
+	for attempt := 1; ; attempt++ {
+		if !b.allow() {
+			// Do would have closed the body. Left open, a multipart body
+			// keeps its writer and files open.
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, fmt.Errorf("error sending request: %w", ErrUnavailable)
+		}
+
+		resp, err := c.http.Do(req)
+		switch {
+		case err != nil && errors.Is(err, context.Canceled):
+			b.release()
+		case err != nil:
+			b.record(false)
+		default:
+			b.record(!unhealthy(resp.StatusCode))
+		}
+
+		delay, ok := retryDelay(req, resp, err, attempt)
+		if !ok {
+			if err != nil {
+				return nil, fmt.Errorf("error sending request: %w", err)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, fmt.Errorf("error sending request: %w", req.Context().Err())
+		case <-timer.C:
+		}
+
+		req, err = rewind(req)
+		if err != nil {
+			return nil, fmt.Errorf("error sending request: %w", err)
+		}
+	}
+}
+
+// unhealthy reports whether a response with the given status means that
+// the backend, or the proxy in front of it, is in trouble.
+func unhealthy(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// retryDelay returns how long to wait before sending req again after the
+// given attempt, and false if it should not be.
+func retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= maxAttempts || req.Context().Err() != nil {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	if err != nil {
+		return Backoff(attempt, minRetryDelay, maxRetryAfter), idempotent
+	}
+
+	status := resp.StatusCode
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		// The server did not handle the request, whatever its method.
+		if delay, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if delay > maxRetryAfter {
+				return 0, false
+			}
+			if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < delay {
+				return 0, false
+			}
+			return delay, true
+		}
+	}
+	return Backoff(attempt, minRetryDelay, maxRetryAfter), idempotent && (status == http.StatusTooManyRequests || unhealthy(status))
+}
+
+// retryAfter parses the value of a Retry-After header, either a number of
+// seconds or a date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// Backoff returns how long to wait after the given failed attempt,
+// counted from 1: a delay doubling from first up to limit, of which a
+// random half is kept, so that clients failing together do not retry
+// together.
+func Backoff(attempt int, first, limit time.Duration) time.Duration {
+	delay := limit
+	if attempt < 16 {
+		delay = min(first<<(max(attempt, 1)-1), limit)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// rewind returns a copy of req to send again, with its body from the
+// start.
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("error rewinding body: %w", err)
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// breaker stops the calls to a backend that keeps failing, so they fail
+// at once instead of each waiting for its timeout. Once its cooldown is
+// over a single call is let through, which closes it again if it succeeds.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	cooldown  time.Duration
+	openUntil time.Time
+	probing   bool
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*breaker)
+)
+
+// breakerOf returns the circuit breaker of the backend at host, shared by
+// every client calling it.
+func breakerOf(host string) *breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	b, ok := breakers[host]
+	if !ok {
+		b = &breaker{}
+		breakers[host] = b
+	}
+	return b
+}
+
+// allow reports whether a call may be sent.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the outcome of a call that was let through.
+func (b *breaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if ok {
+		b.failures = 0
+		b.cooldown = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.cooldown = min(max(2*b.cooldown, breakerCooldown), maxBreakerCooldown)
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release lets another call through after one that was canceled, which
+// says nothing of the backend.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	const first, limit = time.Second, 30 * time.Second
+	for attempt := 0; attempt <= 70; attempt++ {
+		want := limit
+		if attempt < 16 {
+			want = min(first<<(max(attempt, 1)-1), limit)
+		}
+		for i := 0; i < 100; i++ {
+			delay := Backoff(attempt, first, limit)
+			if delay < want/2 || delay > want {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", attempt, delay, want/2, want)
+			}
+		}
+	}
+}
+
+func TestFetchGoesThroughBreaker(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	// The failed requests, retries included, open the breaker, which then
+	// refuses to send more.
+	c := New(func() string { return srv.URL }, nil)
+	var err error
+	for i := 0; i <= breakerThreshold && err == nil; i++ {
+		var resp *http.Response
+		resp, err = c.Fetch(context.Background(), srv.URL+"/files/a.png", nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Fetch = %v, want ErrUnavailable", err)
+	}
+	if requests != breakerThreshold {
+		t.Errorf("%d requests sent, want %d", requests, breakerThreshold)
+	}
+}
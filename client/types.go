@@ -0,0 +1,118 @@
+package client
+
+// The types in this file mirror frontend/src/lib/types.ts and the
+// messages in gateway/pb/message.proto.
+
+type User struct {
+	ID            string `json:"id"`
+	Email         string `json:"email,omitempty"`
+	Username      string `json:"username"`
+	DisplayName   string `json:"display_name"`
+	UsernameColor string `json:"username_color,omitempty"`
+	Avatar        string `json:"avatar"`
+	Banner        string `json:"banner"`
+	Status        string `json:"status"`
+	AboutMe       string `json:"about_me"`
+	CreatedAt     string `json:"created_at"`
+	Deafen        bool   `json:"deafen"`
+	Muted         bool   `json:"muted"`
+	Talking       bool   `json:"talking"`
+}
+
+type Server struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Icon       string     `json:"icon"`
+	Banner     string     `json:"banner"`
+	Categories []Category `json:"categories"`
+	Roles      []string   `json:"roles,omitempty"`
+	Members    []User     `json:"members"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+type Category struct {
+	Name     string    `json:"name"`
+	Channels []Channel `json:"channels"`
+}
+
+type Channel struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Private      bool   `json:"private"`
+	CreatedAt    string `json:"created_at"`
+	Participants []User `json:"participants"`
+}
+
+type Message struct {
+	ID        string   `json:"id"`
+	Author    User     `json:"author"`
+	ChannelID string   `json:"channel_id"`
+	Content   string   `json:"content"`
+	Edited    bool     `json:"edited"`
+	Images    []string `json:"images"`
+	Mentions  []string `json:"mentions"`
+	Replies   *Reply   `json:"replies,omitempty"`
+	UpdatedAt string   `json:"updated_at"`
+	CreatedAt string   `json:"created_at,omitempty"`
+}
+
+type Reply struct {
+	ID      string `json:"id"`
+	Author  User   `json:"author"`
+	Content string `json:"content"`
+}
+
+// SearchHit is a message matching a search. Text is the plain text of its
+// content and Highlights the parts of Text that matched, in UTF-16 code
+// units so they can be sliced in JavaScript.
+type SearchHit struct {
+	Conversation string  `json:"conversation"`
+	Message      Message `json:"message"`
+	Text         string  `json:"text"`
+	Highlights   []Span  `json:"highlights"`
+	Score        float64 `json:"score"`
+}
+
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Notification covers every notification kind the backend sends:
+// friend_request, new_video and new_message.
+type Notification struct {
+	ID          string   `json:"id"`
+	UserID      string   `json:"user_id"`
+	Type        string   `json:"type"`
+	Message     string   `json:"message,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+	InitiatorID string   `json:"initiator_id,omitempty"`
+	RequestID   string   `json:"request_id,omitempty"`
+	ChannelID   string   `json:"channel_id,omitempty"`
+	Counter     int      `json:"counter,omitempty"`
+	Mentions    []string `json:"mentions,omitempty"`
+	ServerID    string   `json:"server_id,omitempty"`
+	Read        bool     `json:"read,omitempty"`
+}
+
+// File is an attachment as it is sent from the frontend. Its content is
+// either held in Data or read from Path when it is uploaded.
+type File struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+	Path string `json:"path,omitempty"`
+}
+
+// UploadProgress reports how much of an upload was sent. File and Index
+// name the attachment being sent; Sent and Size count its bytes, and
+// TotalSent and Total those of the whole request.
+type UploadProgress struct {
+	ID        string `json:"id"`
+	File      string `json:"file"`
+	Index     int    `json:"index"`
+	Sent      int64  `json:"sent"`
+	Size      int64  `json:"size"`
+	TotalSent int64  `json:"total_sent"`
+	Total     int64  `json:"total"`
+}
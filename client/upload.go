@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"sort"
+	"time"
+)
+
+// The largest attachment, and the largest set of attachments of one
+// message, the client agrees to send.
+const (
+	MaxFileSize   = 250 << 20
+	MaxUploadSize = 500 << 20
+)
+
+// progressRate is how often the progress of a transfer is reported.
+const progressRate = 100 * time.Millisecond
+
+// ErrTooLarge is returned when attachments go over the size limits.
+var ErrTooLarge = errors.New("upload too large")
+
+// Size returns the size of the file content.
+func (f File) Size() (int64, error) {
+	if f.Path == "" {
+		return int64(len(f.Data)), nil
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %w", f.Name, err)
+	}
+	return info.Size(), nil
+}
+
+func (f File) open() (io.ReadCloser, error) {
+	if f.Path == "" {
+		return io.NopCloser(bytes.NewReader(f.Data)), nil
+	}
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", f.Name, err)
+	}
+	return file, nil
+}
+
+// CheckUpload returns an error wrapping ErrTooLarge if files go over
+// MaxFileSize or MaxUploadSize.
+func CheckUpload(files []File) error {
+	var total int64
+	for _, file := range files {
+		size, err := file.Size()
+		if err != nil {
+			return err
+		}
+		if size > MaxFileSize {
+			return fmt.Errorf("%s is larger than %d MB: %w", file.Name, MaxFileSize>>20, ErrTooLarge)
+		}
+		total += size
+	}
+	if total > MaxUploadSize {
+		return fmt.Errorf("attachments are larger than %d MB together: %w", MaxUploadSize>>20, ErrTooLarge)
+	}
+	return nil
+}
+
+// MultipartData is a form with files. Files are streamed in order when
+// the request is sent, and Progress, when set, is called as they are.
+type MultipartData struct {
+	Fields   map[string]string
+	Files    []FormFile
+	Progress func(UploadProgress)
+}
+
+// FormFile is a file of a form and the field it is sent as.
+type FormFile struct {
+	Field string
+	File  File
+}
+
+// part is a file of a form, in the order it is sent.
+type part struct {
+	key  string
+	file File
+	size int64
+}
+
+// stream returns the body of a multipart request, written as it is read,
+// its content type and its length.
+func (m MultipartData) stream() (io.ReadCloser, string, int64, error) {
+	parts := make([]part, 0, len(m.Files))
+	var total int64
+	for _, f := range m.Files {
+		size, err := f.File.Size()
+		if err != nil {
+			return nil, "", 0, err
+		}
+		parts = append(parts, part{key: f.Field, file: f.File, size: size})
+		total += size
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	// The length is known in advance: the same form without the file
+	// contents, plus their sizes.
+	counter := &countingWriter{}
+	dry := multipart.NewWriter(counter)
+	err := dry.SetBoundary(writer.Boundary())
+	if err == nil {
+		err = m.write(dry, parts, total, false)
+	}
+	if err != nil {
+		pr.Close()
+		return nil, "", 0, err
+	}
+	length := counter.n + total
+
+	go func() {
+		pw.CloseWithError(m.write(writer, parts, total, true))
+	}()
+
+	return pr, writer.FormDataContentType(), length, nil
+}
+
+// write writes the form to w, whose files add up to total bytes. The
+// contents of the files are only written, and their progress reported,
+// with contents set.
+func (m MultipartData) write(w *multipart.Writer, parts []part, total int64, contents bool) error {
+	keys := make([]string, 0, len(m.Fields))
+	for key := range m.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		err := w.WriteField(key, m.Fields[key])
+		if err != nil {
+			return fmt.Errorf("error writing field: %w", err)
+		}
+	}
+
+	var sent int64
+	for i, p := range parts {
+		dst, err := w.CreateFormFile(p.key, p.file.Name)
+		if err != nil {
+			return fmt.Errorf("error creating form file: %w", err)
+		}
+		if !contents {
+			continue
+		}
+
+		src, err := p.file.open()
+		if err != nil {
+			return err
+		}
+		// The writes to dst go straight to the request body, so what is
+		// reported is what was sent.
+		progress := &ProgressWriter{W: dst, Report: func(n int64) {
+			if m.Progress != nil {
+				m.Progress(UploadProgress{
+					File:      p.file.Name,
+					Index:     i,
+					Sent:      n,
+					Size:      p.size,
+					TotalSent: sent + n,
+					Total:     total,
+				})
+			}
+		}}
+		// The length of the request was computed from the size of the
+		// file, so no more than that is sent if it grows meanwhile.
+		_, err = io.Copy(progress, io.LimitReader(src, p.size))
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("error writing file data: %w", err)
+		}
+		progress.Flush()
+		sent += progress.N
+	}
+
+	err := w.Close()
+	if err != nil {
+		return fmt.Errorf("error closing multipart writer: %w", err)
+	}
+
+	return nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// ProgressWriter counts the bytes written to W and reports the count to
+// Report at most every progressRate, and when flushed.
+type ProgressWriter struct {
+	W      io.Writer
+	Report func(n int64)
+	// N is how many bytes were written.
+	N int64
+
+	reported time.Time
+}
+
+func (p *ProgressWriter) Write(b []byte) (int, error) {
+	n, err := p.W.Write(b)
+	p.N += int64(n)
+	if time.Since(p.reported) >= progressRate {
+		p.Flush()
+	}
+	return n, err
+}
+
+// Flush reports the count now.
+func (p *ProgressWriter) Flush() {
+	p.reported = time.Now()
+	p.Report(p.N)
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestCreateMessageKeepsOrder(t *testing.T) {
+	var mu sync.Mutex
+	var fields, names []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if part.FileName() != "" {
+				mu.Lock()
+				fields = append(fields, part.FormName())
+				names = append(names, part.FileName())
+				mu.Unlock()
+			}
+		}
+		w.Write([]byte(`{"status":200,"message":"success"}`))
+	}))
+	defer srv.Close()
+
+	var files []File
+	for i := 0; i < 12; i++ {
+		files = append(files, File{Name: fmt.Sprintf("%d.txt", i), Data: []byte("attachment")})
+	}
+	var indexes []int
+	c := New(func() string { return srv.URL }, nil)
+	_, err := c.CreateMessage(context.Background(), NewMessage{Content: "<p>files</p>"}, files, func(p UploadProgress) {
+		if len(indexes) == 0 || indexes[len(indexes)-1] != p.Index {
+			indexes = append(indexes, p.Index)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fields) != len(files) || len(indexes) != len(files) {
+		t.Fatalf("%d parts sent and %d reported, want %d", len(fields), len(indexes), len(files))
+	}
+	for i := range files {
+		if fields[i] != fmt.Sprintf("file-%d", i) || names[i] != files[i].Name {
+			t.Fatalf("part %d is %s %s, want file-%d %s", i, fields[i], names[i], i, files[i].Name)
+		}
+		if indexes[i] != i {
+			t.Fatalf("progress of file %d reported as index %d", i, indexes[i])
+		}
+	}
+}
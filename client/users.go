@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+func (c *Client) Profile(ctx context.Context, userID string) (UserResponse, error) {
+	var result UserResponse
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/user/%s", userID), nil, &result)
+	return result, err
+}
+
+func (c *Client) ChangeBanner(ctx context.Context, req BannerChangeRequest) (BannerResponse, error) {
+	body := MultipartData{
+		Fields: cropFields(req.Crop),
+		Files: []FormFile{{
+			Field: "banner",
+			File:  File{Name: req.FileName, Data: req.FileData},
+		}},
+	}
+	body.Fields["old_banner"] = req.OldBanner
+
+	var result BannerResponse
+	err := c.do(ctx, "POST", "/api/v1/user/change_banner", body, &result)
+	return result, err
+}
+
+func (c *Client) ChangeAvatar(ctx context.Context, req AvatarChangeRequest) (AvatarResponse, error) {
+	body := MultipartData{
+		Fields: cropFields(req.Crop),
+		Files: []FormFile{{
+			Field: "avatar",
+			File:  File{Name: req.FileName, Data: req.FileData},
+		}},
+	}
+	body.Fields["old_avatar"] = req.OldAvatar
+
+	if req.ServerID != "" {
+		body.Fields["server_id"] = req.ServerID
+	}
+	if len(req.Friends) > 0 {
+		friendsJSON, err := json.Marshal(req.Friends)
+		if err != nil {
+			return AvatarResponse{}, fmt.Errorf("error marshaling friends: %w", err)
+		}
+		body.Fields["friends"] = string(friendsJSON)
+	}
+
+	var result AvatarResponse
+	err := c.do(ctx, "POST", "/api/v1/user/change_avatar", body, &result)
+	return result, err
+}
+
+func cropFields(crop Crop) map[string]string {
+	return map[string]string{
+		"cropY":      fmt.Sprintf("%d", crop.Y),
+		"cropX":      fmt.Sprintf("%d", crop.X),
+		"cropWidth":  fmt.Sprintf("%d", crop.Width),
+		"cropHeight": fmt.Sprintf("%d", crop.Height),
+	}
+}
+
+func (c *Client) ChangeNameColor(ctx context.Context, req NameColorRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/user/change_name_color", req, &result)
+	return result, err
+}
+
+func (c *Client) ChangeStatus(ctx context.Context, req StatusRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/user/change_status", req, &result)
+	return result, err
+}
+
+func (c *Client) ChangeDisplayName(ctx context.Context, req DisplayNameRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/user/change_name", req, &result)
+	return result, err
+}
+
+func (c *Client) ChangeUsername(ctx context.Context, req UsernameRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/user/change_username", req, &result)
+	return result, err
+}
+
+func (c *Client) ChangeEmail(ctx context.Context, req EmailRequest) (Result, error) {
+	var result Result
+	err := c.do(ctx, "POST", "/api/v1/user/change_email", req, &result)
+	return result, err
+}
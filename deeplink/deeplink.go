@@ -0,0 +1,119 @@
+package deeplink
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Scheme is the URL scheme of the links the app opens, which the desktop
+// entry registers it for.
+const Scheme = "hudori"
+
+// Kind is what a link points to.
+type Kind string
+
+const (
+	// Invite is a link to an invitation to a server, as
+	// hudori://invite/<invite>.
+	Invite Kind = "invite"
+	// Channel is a link to a server channel, as
+	// hudori://channel/<server>/<channel>.
+	Channel Kind = "channel"
+	// Message is a link to a message, as hudori://message/<channel>/<message>,
+	// where the channel is a server channel or the other user of a private
+	// conversation.
+	Message Kind = "message"
+)
+
+// Link is a parsed hudori:// link. Its ids are short, as in the routes of
+// the frontend: the table a full id starts with, as in servers:<id>, is
+// dropped.
+type Link struct {
+	Kind      Kind
+	InviteID  string
+	ServerID  string
+	ChannelID string
+	MessageID string
+}
+
+// Parse parses a hudori:// link.
+func Parse(raw string) (Link, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return Link{}, fmt.Errorf("error parsing link: %w", err)
+	}
+	if u.Scheme != Scheme {
+		return Link{}, fmt.Errorf("not a %s link: %s", Scheme, raw)
+	}
+
+	// hudori://invite/x has invite as its host, hudori:invite/x as the
+	// start of its opaque part.
+	path := u.Opaque
+	if path == "" {
+		path = u.Host + u.EscapedPath()
+	}
+	var ids []string
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		id, err := url.PathUnescape(segment)
+		if err != nil {
+			return Link{}, fmt.Errorf("error parsing link %s: %w", raw, err)
+		}
+		ids = append(ids, id)
+	}
+
+	kind := Kind(strings.ToLower(ids[0]))
+	ids = ids[1:]
+	want := map[Kind]int{Invite: 1, Channel: 2, Message: 2}[kind]
+	if want == 0 {
+		return Link{}, fmt.Errorf("unknown link %s", raw)
+	}
+	if len(ids) != want {
+		return Link{}, fmt.Errorf("link %s should have %d ids", raw, want)
+	}
+	for i, id := range ids {
+		if _, short, ok := strings.Cut(id, ":"); ok {
+			id = short
+		}
+		if !valid(id) {
+			return Link{}, fmt.Errorf("invalid id %q in link %s", ids[i], raw)
+		}
+		ids[i] = id
+	}
+
+	switch kind {
+	case Invite:
+		return Link{Kind: kind, InviteID: ids[0]}, nil
+	case Channel:
+		return Link{Kind: kind, ServerID: ids[0], ChannelID: ids[1]}, nil
+	default:
+		return Link{Kind: kind, ChannelID: ids[0], MessageID: ids[1]}, nil
+	}
+}
+
+// valid reports whether id can be an id, so that a link cannot lead the
+// frontend anywhere but where it says.
+func valid(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns the first hudori:// link of args, which is how the desktop
+// hands a link to the app it launches for it.
+func Find(args []string) (string, bool) {
+	for _, arg := range args {
+		if strings.HasPrefix(strings.ToLower(arg), Scheme+":") {
+			return arg, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,267 @@
+// Package fake is an in-process Hudori backend. It implements the REST
+// endpoints and the realtime gateway the app uses, on top of in-memory
+// state seeded from fixtures, so the client can be run and tested without
+// the real server.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"hudori-desktop/client"
+)
+
+const sessionCookie = "session"
+
+// Server is a fake Hudori backend. It is an http.Handler, so it can be
+// mounted on an httptest.Server, or listen by itself with Start.
+type Server struct {
+	mux        *http.ServeMux
+	httpServer *http.Server
+
+	mu sync.Mutex
+	// base is the URL the server is reached at, which the paths of the
+	// files it serves are resolved against.
+	base          string
+	users         map[string]*user
+	sessions      map[string]string
+	servers       map[string]*server
+	channels      map[string]string
+	invites       map[string]string
+	requests      map[string]request
+	conversations map[string][]*message
+	notifications map[string][]*client.Notification
+	files         map[string]file
+	clock         time.Time
+
+	hub *hub
+}
+
+// New returns a server holding the state described by fixture.
+func New(fixture Fixture) (*Server, error) {
+	s := &Server{
+		mux:           http.NewServeMux(),
+		users:         map[string]*user{},
+		sessions:      map[string]string{},
+		servers:       map[string]*server{},
+		channels:      map[string]string{},
+		invites:       map[string]string{},
+		requests:      map[string]request{},
+		conversations: map[string][]*message{},
+		notifications: map[string][]*client.Notification{},
+		files:         map[string]file{},
+		hub:           newHub(),
+	}
+
+	err := s.seed(fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	s.routes()
+
+	return s, nil
+}
+
+// Start serves on addr, e.g. "127.0.0.1:0" for any free port, and returns
+// the base URL of the server.
+func (s *Server) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+
+	base := "http://" + listener.Addr().String()
+	s.mu.Lock()
+	s.base = base
+	s.mu.Unlock()
+
+	s.httpServer = &http.Server{Handler: s}
+	go s.httpServer.Serve(listener)
+
+	return base, nil
+}
+
+// Close stops the server started by Start and drops every realtime
+// connection.
+func (s *Server) Close() {
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+	s.hub.close()
+}
+
+// ServeHTTP serves the API. A server that was not started learns its
+// URL from the first request, e.g. when it runs in an httptest.Server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		s.base = scheme + "://" + r.Host
+	}
+	s.mu.Unlock()
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("/auth/signin", s.signIn)
+	s.mux.HandleFunc("/auth/verify", s.authed(s.verify))
+	s.mux.HandleFunc("/ws/", s.authed(s.gateway))
+	s.mux.HandleFunc("/files/", s.serveFile)
+
+	s.mux.HandleFunc("/api/v1/user/logout", s.authed(s.logout))
+	s.mux.HandleFunc("/api/v1/user/change_banner", s.authed(s.changeBanner))
+	s.mux.HandleFunc("/api/v1/user/change_avatar", s.authed(s.changeAvatar))
+	s.mux.HandleFunc("/api/v1/user/change_name_color", s.authed(s.changeNameColor))
+	s.mux.HandleFunc("/api/v1/user/change_status", s.authed(s.changeStatus))
+	s.mux.HandleFunc("/api/v1/user/change_name", s.authed(s.changeDisplayName))
+	s.mux.HandleFunc("/api/v1/user/change_username", s.authed(s.changeUsername))
+	s.mux.HandleFunc("/api/v1/user/change_email", s.authed(s.changeEmail))
+	s.mux.HandleFunc("/api/v1/user/", s.authed(s.profile))
+
+	s.mux.HandleFunc("/api/v1/friends/add", s.authed(s.addFriend))
+	s.mux.HandleFunc("/api/v1/friends/accept", s.authed(s.acceptFriend))
+	s.mux.HandleFunc("/api/v1/friends/refuse", s.authed(s.refuseFriend))
+	s.mux.HandleFunc("/api/v1/friends/delete", s.authed(s.deleteFriend))
+	s.mux.HandleFunc("/api/v1/friends/", s.authed(s.friends))
+
+	s.mux.HandleFunc("/api/v1/messages/create", s.authed(s.createMessage))
+	s.mux.HandleFunc("/api/v1/messages/edit", s.authed(s.editMessage))
+	s.mux.HandleFunc("/api/v1/messages/delete", s.authed(s.deleteMessage))
+	s.mux.HandleFunc("/api/v1/messages/", s.authed(s.messages))
+	s.mux.HandleFunc("/api/v1/channels/typing", s.authed(s.typing))
+
+	s.mux.HandleFunc("/api/v1/notifications/message_update", s.authed(s.syncNotifications))
+	s.mux.HandleFunc("/api/v1/notifications/", s.authed(s.listNotifications))
+
+	s.mux.HandleFunc("/api/v1/servers/", s.authed(s.listServers))
+	s.mux.HandleFunc("/api/v1/server/create", s.authed(s.createServer))
+	s.mux.HandleFunc("/api/v1/server/join", s.authed(s.joinServer))
+	s.mux.HandleFunc("/api/v1/server/delete", s.authed(s.deleteServer))
+	s.mux.HandleFunc("/api/v1/server/leave", s.authed(s.leaveServer))
+	s.mux.HandleFunc("/api/v1/server/", s.authed(s.getServer))
+	s.mux.HandleFunc("/api/v1/invites/create", s.authed(s.createInvitation))
+	s.mux.HandleFunc("/api/v1/category/create", s.authed(s.createCategory))
+	s.mux.HandleFunc("/api/v1/category/delete", s.authed(s.deleteCategory))
+	s.mux.HandleFunc("/api/v1/channels/create", s.authed(s.createChannel))
+	s.mux.HandleFunc("/api/v1/channels/delete", s.authed(s.deleteChannel))
+	s.mux.HandleFunc("/api/v1/rtc/", s.authed(s.roomToken))
+}
+
+// handler is an endpoint that needs a signed in user, whose short id it
+// is given.
+type handler func(w http.ResponseWriter, r *http.Request, userID string)
+
+// authed resolves the session sent with the request, as a bearer token or
+// a cookie, and refuses the request when there is none.
+func (s *Server) authed(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if cookie, err := r.Cookie(sessionCookie); sessionID == "" && err == nil {
+			sessionID = cookie.Value
+		}
+
+		s.mu.Lock()
+		userID, ok := s.sessions[sessionID]
+		s.mu.Unlock()
+
+		if !ok {
+			fail(w, http.StatusUnauthorized, "", "unauthorized")
+			return
+		}
+		h(w, r, userID)
+	}
+}
+
+// decode reads the JSON body of r into v, answering with a 400 when it
+// cannot.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "", fmt.Sprintf("invalid body: %v", err))
+		return false
+	}
+	return true
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func success(w http.ResponseWriter) {
+	reply(w, client.Success())
+}
+
+// fail answers with an error in the format of the backend. name is the
+// form field at fault, if any.
+func fail(w http.ResponseWriter, status int, name, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	result := client.Fail(status, message)
+	if name != "" {
+		result = client.FieldError(status, name, message)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// pathArgs returns the segments of the request path after prefix.
+func pathArgs(r *http.Request, prefix string) []string {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if rest == "" {
+		return nil
+	}
+	return strings.Split(rest, "/")
+}
+
+// shortID returns the part of a record id after its table, e.g. "abc" for
+// "users:abc". The state is keyed by short ids, as the app sends either.
+func shortID(id string) string {
+	if _, short, ok := strings.Cut(id, ":"); ok {
+		return short
+	}
+	return id
+}
+
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// newID returns a random record id of the given table, in the format of
+// the backend.
+func newID(table string) string {
+	id := make([]byte, 20)
+	for i := range id {
+		id[i] = idAlphabet[rand.Intn(len(idAlphabet))]
+	}
+	return table + ":" + string(id)
+}
+
+// now returns the time of a new record. Records made in the same instant
+// still get increasing times, so that they sort in the order they were
+// made. s.mu must be held.
+func (s *Server) now() string {
+	t := time.Now().UTC()
+	if !t.After(s.clock) {
+		t = s.clock.Add(time.Microsecond)
+	}
+	s.clock = t
+	return t.Format(time.RFC3339Nano)
+}
+
+// url resolves the path of a file served by s to its URL. Other URLs are
+// returned as they are. s.mu must be held.
+func (s *Server) url(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return s.base + path
+	}
+	return path
+}
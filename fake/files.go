@@ -0,0 +1,143 @@
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxMemory is how much of a multipart body is held in memory; the rest
+// goes to temporary files.
+const maxMemory = 32 << 20
+
+// avatarColors are the backgrounds of the avatars made for users who
+// have none.
+var avatarColors = []color.NRGBA{
+	{R: 0x63, G: 0x66, B: 0xf1, A: 0xff},
+	{R: 0xec, G: 0x48, B: 0x99, A: 0xff},
+	{R: 0x14, G: 0xb8, B: 0xa6, A: 0xff},
+	{R: 0xf5, G: 0x9e, B: 0x0b, A: 0xff},
+	{R: 0x8b, G: 0x5c, B: 0xf6, A: 0xff},
+	{R: 0x22, G: 0xc5, B: 0x5e, A: 0xff},
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	f, ok := s.files[strings.TrimPrefix(r.URL.Path, "/files/")]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Write(f.data)
+}
+
+// store keeps data as a file and returns its path. s.mu must be held.
+func (s *Server) store(name string, data []byte) string {
+	id := shortID(newID("files"))
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		id += strings.ToLower(name[i:])
+	}
+	s.files[id] = file{name: name, contentType: http.DetectContentType(data), data: data}
+	return "/files/" + id
+}
+
+// upload stores the file sent in the given field of a multipart request
+// and returns its path.
+func (s *Server) upload(w http.ResponseWriter, r *http.Request, field string) (string, bool) {
+	err := r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "", fmt.Sprintf("invalid body: %v", err))
+		return "", false
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File[field]
+	if len(headers) == 0 {
+		fail(w, http.StatusBadRequest, field, "No file sent")
+		return "", false
+	}
+	data, err := read(headers[0])
+	if err != nil {
+		fail(w, http.StatusBadRequest, field, err.Error())
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(headers[0].Filename, data), true
+}
+
+func read(header *multipart.FileHeader) ([]byte, error) {
+	f, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", header.Filename, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", header.Filename, err)
+	}
+	return data, nil
+}
+
+// avatar draws the initial of name on a color picked from id, and returns
+// the path of the picture. s.mu must be held.
+func (s *Server) avatar(id, name string) string {
+	const size = 128
+
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	background := avatarColors[hash.Sum32()%uint32(len(avatarColors))]
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	initial, _ := utf8.DecodeRuneInString(name)
+	label := string(unicode.ToUpper(initial))
+
+	// The initial is drawn with the small bitmap font, then scaled up.
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, label).Ceil()
+	text := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	drawer := font.Drawer{
+		Dst:  text,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	drawer.DrawString(label)
+
+	scale := size / 2 / face.Height
+	origin := image.Pt((size-width*scale)/2, (size-face.Height*scale)/2)
+	for y := 0; y < face.Height*scale; y++ {
+		for x := 0; x < width*scale; x++ {
+			if text.AlphaAt(x/scale, y/scale).A > 0x7f {
+				img.SetNRGBA(origin.X+x, origin.Y+y, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return s.store(id+".png", buf.Bytes())
+}
@@ -0,0 +1,185 @@
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+)
+
+// request is a pending friend request.
+type request struct {
+	initiator string
+	receiver  string
+}
+
+func (s *Server) friends(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/friends/")
+	if len(args) != 1 || shortID(args[0]) != userID {
+		fail(w, http.StatusForbidden, "", "forbidden")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	friends := []client.User{}
+	for id := range s.users[userID].friends {
+		friends = append(friends, s.public(s.users[id]))
+	}
+	sort.Slice(friends, func(i, j int) bool {
+		return friends[i].DisplayName < friends[j].DisplayName
+	})
+
+	reply(w, client.FriendsResponse{Result: client.Success(), Friends: friends})
+}
+
+func (s *Server) addFriend(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.AddFriendRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var receiver *user
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, req.ReceiverUsername) {
+			receiver = u
+		}
+	}
+	switch {
+	case receiver == nil:
+		fail(w, http.StatusNotFound, "username", "This user does not exist")
+		return
+	case shortID(receiver.ID) == userID:
+		fail(w, http.StatusBadRequest, "username", "You cannot add yourself")
+		return
+	case receiver.friends[userID]:
+		fail(w, http.StatusConflict, "username", "You are already friends")
+		return
+	}
+	for _, pending := range s.requests {
+		if pending.initiator == userID && pending.receiver == shortID(receiver.ID) {
+			fail(w, http.StatusConflict, "username", "A request was already sent to this user")
+			return
+		}
+	}
+
+	initiator := s.users[userID]
+	requestID := newID("friend_requests")
+	s.requests[requestID] = request{initiator: userID, receiver: shortID(receiver.ID)}
+
+	n := &client.Notification{
+		ID:          newID("notifications"),
+		UserID:      receiver.ID,
+		Type:        "friend_request",
+		Message:     fmt.Sprintf("%s sent you a friend request", initiator.Username),
+		CreatedAt:   s.now(),
+		InitiatorID: initiator.ID,
+		RequestID:   requestID,
+	}
+	s.notifications[shortID(receiver.ID)] = append(s.notifications[shortID(receiver.ID)], n)
+
+	s.emit([]string{shortID(receiver.ID)}, &pb.WSMessage{
+		Type: "friend_request",
+		Content: &pb.WSMessage_FriendRequest{FriendRequest: &pb.FriendRequest{
+			Id:          n.ID,
+			InitiatorId: n.InitiatorID,
+			Message:     n.Message,
+			RequestId:   n.RequestID,
+			Type:        n.Type,
+			UserId:      n.UserID,
+			CreatedAt:   n.CreatedAt,
+		}},
+	})
+	success(w)
+}
+
+// answer resolves the friend request of a reply, which only its receiver
+// may answer, and drops it with its notification. s.mu must be held.
+func (s *Server) answer(w http.ResponseWriter, req client.FriendRequestReply, userID string) (request, bool) {
+	pending, ok := s.requests[req.RequestID]
+	if !ok || pending.receiver != userID {
+		fail(w, http.StatusNotFound, "", "friend request not found")
+		return pending, false
+	}
+
+	delete(s.requests, req.RequestID)
+	s.dropNotification(userID, func(n *client.Notification) bool {
+		return n.ID == req.ID || n.RequestID == req.RequestID
+	})
+	return pending, true
+}
+
+func (s *Server) acceptFriend(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.FriendRequestReply
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.answer(w, req, userID)
+	if !ok {
+		return
+	}
+
+	initiator, receiver := s.users[pending.initiator], s.users[pending.receiver]
+	initiator.friends[pending.receiver] = true
+	receiver.friends[pending.initiator] = true
+
+	s.emit([]string{pending.initiator}, &pb.WSMessage{
+		Type:    "friend_accept",
+		Content: &pb.WSMessage_FriendAccept{FriendAccept: pbUser(s.public(receiver))},
+	})
+
+	friend := s.public(initiator)
+	reply(w, client.FriendResponse{Result: client.Success(), Friend: &friend})
+}
+
+func (s *Server) refuseFriend(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.FriendRequestReply
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.answer(w, req, userID)
+	if !ok {
+		return
+	}
+	success(w)
+}
+
+func (s *Server) deleteFriend(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.DeleteFriendRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	friendID := shortID(req.FriendID)
+	if !s.users[userID].friends[friendID] {
+		fail(w, http.StatusNotFound, "", "friend not found")
+		return
+	}
+
+	delete(s.users[userID].friends, friendID)
+	delete(s.users[friendID].friends, userID)
+
+	s.emit([]string{friendID}, &pb.WSMessage{
+		Type:    "friend_remove",
+		Content: &pb.WSMessage_UserId{UserId: s.users[userID].ID},
+	})
+	success(w)
+}
@@ -0,0 +1,276 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway"
+	"hudori-desktop/gateway/pb"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// queued is how many frames a connection may lag behind before it is
+// dropped.
+const queued = 64
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type frame struct {
+	messageType int
+	data        []byte
+}
+
+// conn is a realtime connection. Frames are written from its own
+// goroutine, so that a slow client does not hold up the others.
+type conn struct {
+	ws  *websocket.Conn
+	out chan frame
+}
+
+// hub holds the realtime connections of every user.
+type hub struct {
+	mu    sync.Mutex
+	conns map[string]map[*conn]bool
+}
+
+func newHub() *hub {
+	return &hub{conns: map[string]map[*conn]bool{}}
+}
+
+func (h *hub) add(userID string, c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[userID] == nil {
+		h.conns[userID] = map[*conn]bool{}
+	}
+	h.conns[userID][c] = true
+}
+
+func (h *hub) remove(userID string, c *conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conns[userID][c] {
+		delete(h.conns[userID], c)
+		close(c.out)
+	}
+}
+
+// send queues a frame for every connection of the given users.
+func (h *hub) send(userIDs []string, f frame) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for c := range h.conns[userID] {
+			select {
+			case c.out <- f:
+			default:
+				c.ws.Close()
+			}
+		}
+	}
+}
+
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, conns := range h.conns {
+		for c := range conns {
+			c.ws.Close()
+		}
+	}
+}
+
+// gateway serves the realtime connection of a user: events are sent as
+// brotli-compressed hudori.WSMessage frames, and voice channel updates
+// are read as JSON and relayed to the other members of the server.
+func (s *Server) gateway(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/ws/")
+	if len(args) != 1 || shortID(args[0]) != userID {
+		fail(w, http.StatusForbidden, "", "forbidden")
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &conn{ws: ws, out: make(chan frame, queued)}
+	s.hub.add(userID, c)
+	defer s.hub.remove(userID, c)
+
+	go func() {
+		for f := range c.out {
+			err := ws.WriteMessage(f.messageType, f.data)
+			if err != nil {
+				ws.Close()
+			}
+		}
+	}()
+
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			ws.Close()
+			return
+		}
+		if messageType != websocket.TextMessage || string(data) == "heartbeat" {
+			continue
+		}
+
+		var update gateway.ParticipantUpdate
+		if json.Unmarshal(data, &update) == nil {
+			s.participant(userID, update, data)
+		}
+	}
+}
+
+// participant records a change of the voice channels and relays it, as
+// it was sent, to the other members of the server.
+func (s *Server) participant(userID string, update gateway.ParticipantUpdate, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.member(update.Content.ServerID, userID)
+	if !ok {
+		return
+	}
+	channel := srv.channel(update.Content.ChannelID)
+	if channel == nil {
+		return
+	}
+
+	switch update.Type {
+	case "new_participant":
+		if update.Content.User == nil {
+			return
+		}
+		channel.Participants = append(removeUser(channel.Participants, update.Content.User.ID), *update.Content.User)
+	case "quit_participant":
+		channel.Participants = removeUser(channel.Participants, update.Content.UserID)
+	case "participant_status":
+		for i := range channel.Participants {
+			if shortID(channel.Participants[i].ID) == shortID(update.Content.UserID) {
+				channel.Participants[i].Muted = update.Content.Muted
+				channel.Participants[i].Deafen = update.Content.Deafen
+			}
+		}
+	default:
+		return
+	}
+
+	s.hub.send(others(srv.members, userID), frame{websocket.TextMessage, data})
+}
+
+func removeUser(users []client.User, id string) []client.User {
+	kept := users[:0]
+	for _, u := range users {
+		if shortID(u.ID) != shortID(id) {
+			kept = append(kept, u)
+		}
+	}
+	return kept
+}
+
+// others returns ids without userID.
+func others(ids []string, userID string) []string {
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != userID {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// emit sends an event to the given users.
+func (s *Server) emit(userIDs []string, msg *pb.WSMessage) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	var compressed bytes.Buffer
+	writer := brotli.NewWriter(&compressed)
+	writer.Write(data)
+	writer.Close()
+
+	s.hub.send(userIDs, frame{websocket.BinaryMessage, compressed.Bytes()})
+}
+
+func pbUser(u client.User) *pb.User {
+	return &pb.User{
+		Id:            u.ID,
+		Email:         u.Email,
+		Username:      u.Username,
+		DisplayName:   u.DisplayName,
+		Avatar:        u.Avatar,
+		Banner:        u.Banner,
+		Status:        u.Status,
+		AboutMe:       u.AboutMe,
+		UsernameColor: u.UsernameColor,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+func pbMessage(m client.Message) *pb.Message {
+	msg := &pb.Message{
+		Id:        m.ID,
+		Author:    pbUser(m.Author),
+		ChannelId: m.ChannelID,
+		Content:   m.Content,
+		Edited:    m.Edited,
+		Images:    m.Images,
+		Mentions:  m.Mentions,
+		UpdatedAt: m.UpdatedAt,
+		CreatedAt: m.CreatedAt,
+	}
+	if m.Replies != nil {
+		msg.Replies = &pb.Reply{
+			Id:      m.Replies.ID,
+			Author:  pbUser(m.Replies.Author),
+			Content: m.Replies.Content,
+		}
+	}
+	return msg
+}
+
+func pbChannel(c client.Channel) *pb.Channel {
+	channel := &pb.Channel{
+		Id:        c.ID,
+		Name:      c.Name,
+		Type:      c.Type,
+		Private:   c.Private,
+		CreatedAt: c.CreatedAt,
+	}
+	for _, p := range c.Participants {
+		channel.Participants = append(channel.Participants, pbUser(p))
+	}
+	return channel
+}
+
+func pbNotification(n *client.Notification) *pb.MessageNotif {
+	return &pb.MessageNotif{
+		Id:        n.ID,
+		Type:      n.Type,
+		UserId:    n.UserID,
+		ChannelId: n.ChannelID,
+		ServerId:  n.ServerID,
+		Counter:   int32(n.Counter),
+		Mentions:  n.Mentions,
+		CreatedAt: n.CreatedAt,
+		Read:      n.Read,
+	}
+}
@@ -0,0 +1,334 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+)
+
+// target is the conversation a request is about.
+type target struct {
+	key string
+	// channelID is the channel its messages carry: the channel of a
+	// server, or the user a private message was sent to.
+	channelID string
+	// members are the users who see its messages.
+	members []string
+	server  *server
+}
+
+// target resolves the conversation of userID with channelID: a channel of
+// one of their servers, or a friend when private is set. s.mu must be
+// held.
+func (s *Server) target(userID, channelID string, private bool) (target, bool) {
+	id := shortID(channelID)
+
+	if private {
+		if id != userID && !s.users[userID].friends[id] {
+			return target{}, false
+		}
+		members := []string{userID}
+		if id != userID {
+			members = append(members, id)
+		}
+		return target{key: privateKey(userID, id), channelID: "users:" + id, members: members}, true
+	}
+
+	srv, ok := s.member(s.channels[id], userID)
+	if !ok {
+		return target{}, false
+	}
+	return target{key: id, channelID: "channels:" + id, members: srv.members, server: srv}, true
+}
+
+// find returns the index of the message with the given id in messages,
+// or -1.
+func find(messages []*message, id string) int {
+	for i, m := range messages {
+		if shortID(m.ID) == shortID(id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// message returns m as the API sends it. s.mu must be held.
+func (s *Server) message(t target, m *message) client.Message {
+	view := m.Message
+	view.Author = s.public(s.users[m.author])
+	view.Images = make([]string, len(m.Images))
+	for i, image := range m.Images {
+		view.Images[i] = s.url(image)
+	}
+	if view.Mentions == nil {
+		view.Mentions = []string{}
+	}
+	if m.reply != "" {
+		if i := find(s.conversations[t.key], m.reply); i >= 0 {
+			replied := s.conversations[t.key][i]
+			view.Replies = &client.Reply{
+				ID:      replied.ID,
+				Author:  s.public(s.users[replied.author]),
+				Content: replied.Content,
+			}
+		}
+	}
+	return view
+}
+
+// page returns the messages newer than after and older than before, at
+// most limit of them: the oldest when only after is set, the newest
+// otherwise.
+func page(messages []*message, after, before string, limit int) []*message {
+	start, end := 0, len(messages)
+	if i := find(messages, after); after != "" && i >= 0 {
+		start = i + 1
+	}
+	if i := find(messages, before); before != "" && i >= 0 {
+		end = i
+	}
+	if start > end {
+		return nil
+	}
+	if limit > 0 && end-start > limit {
+		if after != "" && before == "" {
+			end = start + limit
+		} else {
+			start = end - limit
+		}
+	}
+	return messages[start:end]
+}
+
+func (s *Server) messages(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/messages/")
+
+	var private bool
+	switch {
+	case len(args) == 1:
+	case len(args) == 3 && args[1] == "private" && shortID(args[2]) == userID:
+		private = true
+	default:
+		fail(w, http.StatusNotFound, "", "not found")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.target(userID, args[0], private)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "channel not found")
+		return
+	}
+
+	messages := []client.Message{}
+	for _, m := range page(s.conversations[t.key], query.Get("after"), query.Get("before"), limit) {
+		messages = append(messages, s.message(t, m))
+	}
+	reply(w, client.MessagesResponse{Result: client.Success(), Messages: messages})
+}
+
+// createMessage stores a message sent as a multipart form: the message
+// as JSON in the body field, and its pictures as the files file-0 to
+// file-N.
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request, userID string) {
+	err := r.ParseMultipartForm(maxMemory)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "", fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var msg client.NewMessage
+	err = json.Unmarshal([]byte(r.FormValue("body")), &msg)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "", fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	if strings.TrimSpace(msg.Content) == "" && len(r.MultipartForm.File) == 0 {
+		fail(w, http.StatusBadRequest, "content", "The message is empty")
+		return
+	}
+
+	// The files are stored in the order they were attached.
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		if strings.HasPrefix(field, "file-") {
+			fields = append(fields, field)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(fields[i], "file-"))
+		b, _ := strconv.Atoi(strings.TrimPrefix(fields[j], "file-"))
+		return a < b
+	})
+	files := make(map[string][]byte, len(fields))
+	for _, field := range fields {
+		data, err := read(r.MultipartForm.File[field][0])
+		if err != nil {
+			fail(w, http.StatusBadRequest, "files", err.Error())
+			return
+		}
+		files[field] = data
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.target(userID, msg.ChannelID, msg.PrivateMessage)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "channel not found")
+		return
+	}
+
+	now := s.now()
+	m := &message{
+		Message: client.Message{
+			ID:        newID("messages"),
+			ChannelID: t.channelID,
+			Content:   msg.Content,
+			Images:    []string{},
+			Mentions:  msg.Mentions,
+			UpdatedAt: now,
+			CreatedAt: now,
+		},
+		author: userID,
+		reply:  msg.Reply,
+	}
+	for _, field := range fields {
+		m.Images = append(m.Images, s.store(r.MultipartForm.File[field][0].Filename, files[field]))
+	}
+	s.conversations[t.key] = append(s.conversations[t.key], m)
+
+	s.emit(t.members, &pb.WSMessage{
+		Type:    "text_message",
+		Content: &pb.WSMessage_Mess{Mess: pbMessage(s.message(t, m))},
+	})
+
+	// A private conversation is named after the other user on each side.
+	channelID := func(string) string { return t.channelID }
+	serverID := ""
+	if t.server != nil {
+		serverID = t.server.ID
+	} else {
+		channelID = func(string) string { return s.users[userID].ID }
+	}
+	s.notify(others(t.members, userID), m, channelID, serverID)
+
+	success(w)
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.EditMessageRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.target(userID, req.ChannelID, req.PrivateMessage)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "channel not found")
+		return
+	}
+	i := find(s.conversations[t.key], req.MessageID)
+	if i < 0 {
+		fail(w, http.StatusNotFound, "", "message not found")
+		return
+	}
+	m := s.conversations[t.key][i]
+	if m.author != userID {
+		fail(w, http.StatusForbidden, "", "only the author can edit a message")
+		return
+	}
+
+	m.Content = req.Content
+	m.Mentions = req.Mentions
+	m.Edited = true
+	m.UpdatedAt = s.now()
+
+	s.emit(t.members, &pb.WSMessage{
+		Type:    "edit_message",
+		Content: &pb.WSMessage_Mess{Mess: pbMessage(s.message(t, m))},
+	})
+	success(w)
+}
+
+// deleteMessage deletes a message, which its author and the owner of its
+// server may do.
+func (s *Server) deleteMessage(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.DeleteMessageRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.target(userID, req.ChannelID, req.PrivateMessage)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "channel not found")
+		return
+	}
+	messages := s.conversations[t.key]
+	i := find(messages, req.MessageID)
+	if i < 0 {
+		fail(w, http.StatusNotFound, "", "message not found")
+		return
+	}
+	m := messages[i]
+	if m.author != userID && (t.server == nil || t.server.owner != userID) {
+		fail(w, http.StatusForbidden, "", "only the author can delete a message")
+		return
+	}
+
+	deleted := s.message(t, m)
+	s.conversations[t.key] = append(messages[:i], messages[i+1:]...)
+
+	s.emit(t.members, &pb.WSMessage{
+		Type:    "delete_message",
+		Content: &pb.WSMessage_Mess{Mess: pbMessage(deleted)},
+	})
+	success(w)
+}
+
+// typing tells the others in a conversation that a user started or
+// stopped typing. The channel is a friend for private conversations.
+func (s *Server) typing(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.TypingRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, private := s.users[shortID(req.ChannelID)]
+	t, ok := s.target(userID, req.ChannelID, private)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "channel not found")
+		return
+	}
+
+	s.emit(others(t.members, userID), &pb.WSMessage{
+		Type: "typing",
+		Content: &pb.WSMessage_Typing{Typing: &pb.Typing{
+			DisplayName: req.DisplayName,
+			ChannelId:   req.ChannelID,
+			UserId:      s.users[userID].ID,
+			Status:      req.Status,
+		}},
+	})
+	success(w)
+}
@@ -0,0 +1,98 @@
+package fake
+
+import (
+	"net/http"
+	"slices"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+)
+
+func (s *Server) listNotifications(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/notifications/")
+	if len(args) != 1 || shortID(args[0]) != userID {
+		fail(w, http.StatusForbidden, "", "forbidden")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notifications := []client.Notification{}
+	for _, n := range s.notifications[userID] {
+		notifications = append(notifications, *n)
+	}
+	reply(w, client.NotificationsResponse{Result: client.Success(), Notifications: notifications})
+}
+
+// syncNotifications marks the messages of the given channels read.
+func (s *Server) syncNotifications(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.SyncNotificationsRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range s.notifications[userID] {
+		if n.Type != "new_message" {
+			continue
+		}
+		if slices.ContainsFunc(req.Channels, func(channelID string) bool {
+			return shortID(channelID) == shortID(n.ChannelID)
+		}) {
+			n.Read = true
+			n.Counter = 0
+			n.Mentions = nil
+		}
+	}
+	success(w)
+}
+
+// dropNotification removes the notifications of userID that match.
+// s.mu must be held.
+func (s *Server) dropNotification(userID string, match func(*client.Notification) bool) {
+	s.notifications[userID] = slices.DeleteFunc(s.notifications[userID], match)
+}
+
+// notify counts a new message for each of userIDs, in the notification of
+// its conversation, and sends them the notification. channelID is the
+// channel the conversation has for them, serverID its server if any.
+// s.mu must be held.
+func (s *Server) notify(userIDs []string, m *message, channelID func(userID string) string, serverID string) {
+	for _, userID := range userIDs {
+		conversation := channelID(userID)
+
+		var n *client.Notification
+		for _, existing := range s.notifications[userID] {
+			if existing.Type == "new_message" && shortID(existing.ChannelID) == shortID(conversation) {
+				n = existing
+			}
+		}
+		if n == nil {
+			n = &client.Notification{
+				ID:        newID("notifications"),
+				UserID:    s.users[userID].ID,
+				Type:      "new_message",
+				ChannelID: conversation,
+				ServerID:  serverID,
+				Mentions:  []string{},
+			}
+			s.notifications[userID] = append(s.notifications[userID], n)
+		}
+
+		n.Counter++
+		n.Read = false
+		n.CreatedAt = m.CreatedAt
+		id := s.users[userID].ID
+		if slices.Contains(m.Mentions, id) && !slices.Contains(n.Mentions, id) {
+			n.Mentions = append(n.Mentions, id)
+		}
+
+		s.emit([]string{userID}, &pb.WSMessage{
+			Type:    "new_notification",
+			Content: &pb.WSMessage_Notification{Notification: pbNotification(n)},
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package fake
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+)
+
+// channel returns the channel of srv with the given id, or nil.
+func (srv *server) channel(id string) *client.Channel {
+	for i := range srv.Categories {
+		for j := range srv.Categories[i].Channels {
+			if shortID(srv.Categories[i].Channels[j].ID) == shortID(id) {
+				return &srv.Categories[i].Channels[j]
+			}
+		}
+	}
+	return nil
+}
+
+// category returns the category of srv with the given name, or nil.
+func (srv *server) category(name string) *client.Category {
+	for i := range srv.Categories {
+		if srv.Categories[i].Name == name {
+			return &srv.Categories[i]
+		}
+	}
+	return nil
+}
+
+// owned returns the server with the given id when userID owns it, and
+// answers with an error otherwise. s.mu must be held.
+func (s *Server) owned(w http.ResponseWriter, serverID, userID string) (*server, bool) {
+	srv, ok := s.member(serverID, userID)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "server not found")
+		return nil, false
+	}
+	if srv.owner != userID {
+		fail(w, http.StatusForbidden, "", "only the owner can change a server")
+		return nil, false
+	}
+	return srv, true
+}
+
+func (s *Server) listServers(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/servers/")
+	if len(args) != 1 || shortID(args[0]) != userID {
+		fail(w, http.StatusForbidden, "", "forbidden")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	servers := []client.Server{}
+	for _, srv := range s.servers {
+		if slices.Contains(srv.members, userID) {
+			servers = append(servers, s.view(srv))
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].CreatedAt < servers[j].CreatedAt
+	})
+
+	reply(w, client.ServersResponse{Result: client.Success(), Servers: servers})
+}
+
+func (s *Server) getServer(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/server/")
+	if len(args) != 2 || shortID(args[0]) != userID {
+		fail(w, http.StatusNotFound, "", "not found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.member(args[1], userID)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "server not found")
+		return
+	}
+
+	view := s.view(srv)
+	reply(w, client.ServerResponse{Result: client.Success(), Server: &view})
+}
+
+// createServer makes a server with a first text channel, as the backend
+// does.
+func (s *Server) createServer(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.CreateServerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		fail(w, http.StatusBadRequest, "id", "The name cannot be empty")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	channel := client.Channel{
+		ID:           newID("channels"),
+		Name:         "general",
+		Type:         "textual",
+		CreatedAt:    now,
+		Participants: []client.User{},
+	}
+	srv := &server{
+		Server: client.Server{
+			ID:         newID("servers"),
+			Name:       req.Name,
+			Categories: []client.Category{{Name: "General", Channels: []client.Channel{channel}}},
+			CreatedAt:  now,
+		},
+		owner:   userID,
+		members: []string{userID},
+	}
+	s.servers[shortID(srv.ID)] = srv
+	s.channels[shortID(channel.ID)] = shortID(srv.ID)
+
+	view := s.view(srv)
+	reply(w, client.ServerResponse{Result: client.Success(), Server: &view})
+}
+
+func (s *Server) joinServer(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.JoinServerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	serverID, ok := s.invites[req.InviteID]
+	if !ok {
+		fail(w, http.StatusNotFound, "id", "This invitation does not exist")
+		return
+	}
+	srv := s.servers[serverID]
+	if slices.Contains(srv.members, userID) {
+		fail(w, http.StatusConflict, "id", "You are already a member of this server")
+		return
+	}
+
+	s.emit(srv.members, &pb.WSMessage{
+		Type: "join_server",
+		Content: &pb.WSMessage_JoinServer{JoinServer: &pb.JoinServer{
+			ServerId: srv.ID,
+			User:     pbUser(s.public(s.users[userID])),
+		}},
+	})
+	srv.members = append(srv.members, userID)
+
+	view := s.view(srv)
+	reply(w, client.ServerResponse{Result: client.Success(), Server: &view})
+}
+
+func (s *Server) deleteServer(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.ServerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.owned(w, req.ServerID, userID)
+	if !ok {
+		return
+	}
+
+	id := shortID(srv.ID)
+	delete(s.servers, id)
+	for channelID, serverID := range s.channels {
+		if serverID == id {
+			delete(s.channels, channelID)
+			delete(s.conversations, channelID)
+		}
+	}
+	for invite, serverID := range s.invites {
+		if serverID == id {
+			delete(s.invites, invite)
+		}
+	}
+	for _, member := range srv.members {
+		s.dropNotification(member, func(n *client.Notification) bool {
+			return shortID(n.ServerID) == id
+		})
+	}
+
+	s.emit(others(srv.members, userID), &pb.WSMessage{
+		Type:    "delete_server",
+		Content: &pb.WSMessage_ServerId{ServerId: srv.ID},
+	})
+	success(w)
+}
+
+func (s *Server) leaveServer(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.ServerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.member(req.ServerID, userID)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "server not found")
+		return
+	}
+	if srv.owner == userID {
+		fail(w, http.StatusForbidden, "", "the owner cannot leave their server")
+		return
+	}
+
+	srv.members = slices.DeleteFunc(srv.members, func(id string) bool { return id == userID })
+	s.dropNotification(userID, func(n *client.Notification) bool {
+		return shortID(n.ServerID) == shortID(srv.ID)
+	})
+
+	s.emit(srv.members, &pb.WSMessage{
+		Type: "leave_server",
+		Content: &pb.WSMessage_QuitServer{QuitServer: &pb.QuitServer{
+			ServerId: srv.ID,
+			UserId:   s.users[userID].ID,
+		}},
+	})
+	success(w)
+}
+
+func (s *Server) createInvitation(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.ServerRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.member(req.ServerID, userID)
+	if !ok {
+		fail(w, http.StatusNotFound, "", "server not found")
+		return
+	}
+
+	invite := shortID(newID("invites"))
+	s.invites[invite] = shortID(srv.ID)
+	reply(w, client.InvitationResponse{Result: client.Success(), ID: invite})
+}
+
+func (s *Server) createCategory(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.CategoryRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.owned(w, req.ServerID, userID)
+	if !ok {
+		return
+	}
+	if srv.category(req.CategoryName) != nil {
+		fail(w, http.StatusConflict, "categoryName", "This category already exists")
+		return
+	}
+
+	srv.Categories = append(srv.Categories, client.Category{Name: req.CategoryName, Channels: []client.Channel{}})
+
+	s.emit(srv.members, &pb.WSMessage{
+		Type: "create_category",
+		Content: &pb.WSMessage_CreateCategory{CreateCategory: &pb.CreateCategory{
+			ServerId:     srv.ID,
+			CategoryName: req.CategoryName,
+		}},
+	})
+	success(w)
+}
+
+func (s *Server) deleteCategory(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.CategoryRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.owned(w, req.ServerID, userID)
+	if !ok {
+		return
+	}
+	category := srv.category(req.CategoryName)
+	if category == nil {
+		fail(w, http.StatusNotFound, "", "category not found")
+		return
+	}
+
+	for _, channel := range category.Channels {
+		delete(s.channels, shortID(channel.ID))
+		delete(s.conversations, shortID(channel.ID))
+	}
+	srv.Categories = slices.DeleteFunc(srv.Categories, func(c client.Category) bool {
+		return c.Name == req.CategoryName
+	})
+
+	s.emit(srv.members, &pb.WSMessage{
+		Type: "delete_category",
+		Content: &pb.WSMessage_DeleteCategory{DeleteCategory: &pb.DeleteCategory{
+			ServerId:     srv.ID,
+			CategoryName: req.CategoryName,
+		}},
+	})
+	success(w)
+}
+
+func (s *Server) createChannel(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.CreateChannelRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.ChannelType != "textual" && req.ChannelType != "voice" {
+		fail(w, http.StatusBadRequest, "type", "Unknown channel type")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.owned(w, req.ServerID, userID)
+	if !ok {
+		return
+	}
+	category := srv.category(req.CategoryName)
+	if category == nil {
+		fail(w, http.StatusNotFound, "", "category not found")
+		return
+	}
+
+	channel := client.Channel{
+		ID:           newID("channels"),
+		Name:         req.Name,
+		Type:         req.ChannelType,
+		CreatedAt:    s.now(),
+		Participants: []client.User{},
+	}
+	category.Channels = append(category.Channels, channel)
+	s.channels[shortID(channel.ID)] = shortID(srv.ID)
+
+	s.emit(srv.members, &pb.WSMessage{
+		Type: "create_channel",
+		Content: &pb.WSMessage_Channel{Channel: &pb.CreateChannel{
+			ServerId:     srv.ID,
+			Channel:      pbChannel(channel),
+			CategoryName: req.CategoryName,
+		}},
+	})
+	success(w)
+}
+
+func (s *Server) deleteChannel(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.DeleteChannelRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	srv, ok := s.owned(w, req.ServerID, userID)
+	if !ok {
+		return
+	}
+	category := srv.category(req.CategoryName)
+	if category == nil || srv.channel(req.ChannelID) == nil {
+		fail(w, http.StatusNotFound, "", "channel not found")
+		return
+	}
+
+	id := shortID(req.ChannelID)
+	category.Channels = slices.DeleteFunc(category.Channels, func(c client.Channel) bool {
+		return shortID(c.ID) == id
+	})
+	delete(s.channels, id)
+	delete(s.conversations, id)
+
+	s.emit(srv.members, &pb.WSMessage{
+		Type: "delete_channel",
+		Content: &pb.WSMessage_Delchannel{Delchannel: &pb.DeleteChannel{
+			ServerId:     srv.ID,
+			ChannelId:    "channels:" + id,
+			CategoryName: req.CategoryName,
+		}},
+	})
+	success(w)
+}
+
+// roomToken hands out a token for a voice channel. There is no media
+// server behind the fake backend, so the token only lets the app get as
+// far as connecting.
+func (s *Server) roomToken(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/rtc/")
+	if len(args) != 2 || shortID(args[1]) != userID {
+		fail(w, http.StatusNotFound, "", "not found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channelID := shortID(args[0])
+	srv, ok := s.member(s.channels[channelID], userID)
+	if !ok || srv.channel(channelID).Type != "voice" {
+		fail(w, http.StatusNotFound, "", "voice channel not found")
+		return
+	}
+
+	reply(w, client.RoomTokenResponse{
+		Result: client.Success(),
+		Token:  "fake." + channelID + "." + userID,
+	})
+}
@@ -0,0 +1,331 @@
+package fake
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"hudori-desktop/client"
+)
+
+//go:embed fixtures/demo.json
+var fixtures embed.FS
+
+// Fixture is the initial state of a server, as stored in fixture files.
+// Records refer to each other by id, with or without their table, e.g.
+// "users:alice" or "alice".
+type Fixture struct {
+	Users []FixtureUser `json:"users"`
+	// Friends lists pairs of users who are friends.
+	Friends [][2]string     `json:"friends"`
+	Servers []FixtureServer `json:"servers"`
+	// Invites maps invitation ids to the servers they let users join.
+	Invites       map[string]string     `json:"invites"`
+	Messages      []FixtureMessage      `json:"messages"`
+	Notifications []client.Notification `json:"notifications"`
+}
+
+// FixtureUser is a user and the password they sign in with.
+type FixtureUser struct {
+	client.User
+	Password string `json:"password"`
+}
+
+// FixtureServer is a server with the ids of its owner and members.
+type FixtureServer struct {
+	client.Server
+	Owner   string   `json:"owner"`
+	Members []string `json:"members"`
+}
+
+// FixtureMessage is a message of a server channel, or a private message
+// when ChannelID is the id of the user it was sent to. Messages without a
+// creation time are spread over the minutes before the server starts, in
+// the order they are listed.
+type FixtureMessage struct {
+	client.Message
+	Author string `json:"author"`
+	// Reply is the id of the message this one answers.
+	Reply string `json:"reply,omitempty"`
+}
+
+// Load reads the fixture files at paths and merges them into one. Without
+// paths it returns the demo fixture built into the package.
+func Load(paths ...string) (Fixture, error) {
+	var fixture Fixture
+
+	if len(paths) == 0 {
+		data, err := fixtures.ReadFile("fixtures/demo.json")
+		if err != nil {
+			return fixture, fmt.Errorf("error reading demo fixture: %w", err)
+		}
+		err = json.Unmarshal(data, &fixture)
+		if err != nil {
+			return fixture, fmt.Errorf("error parsing demo fixture: %w", err)
+		}
+		return fixture, nil
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fixture, fmt.Errorf("error reading fixture: %w", err)
+		}
+
+		var f Fixture
+		err = json.Unmarshal(data, &f)
+		if err != nil {
+			return fixture, fmt.Errorf("error parsing fixture %s: %w", path, err)
+		}
+
+		fixture.Users = append(fixture.Users, f.Users...)
+		fixture.Friends = append(fixture.Friends, f.Friends...)
+		fixture.Servers = append(fixture.Servers, f.Servers...)
+		fixture.Messages = append(fixture.Messages, f.Messages...)
+		fixture.Notifications = append(fixture.Notifications, f.Notifications...)
+		for id, serverID := range f.Invites {
+			if fixture.Invites == nil {
+				fixture.Invites = map[string]string{}
+			}
+			fixture.Invites[id] = serverID
+		}
+	}
+
+	return fixture, nil
+}
+
+type user struct {
+	client.User
+	password string
+	friends  map[string]bool
+}
+
+type server struct {
+	client.Server
+	owner   string
+	members []string
+}
+
+// message is a stored message. Its author and the message it answers are
+// kept by id, and filled in when it is sent, so that they are up to date.
+type message struct {
+	client.Message
+	author string
+	reply  string
+}
+
+type file struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// privateKey returns the key of the conversation between two users.
+func privateKey(a, b string) string {
+	a, b = shortID(a), shortID(b)
+	if a > b {
+		a, b = b, a
+	}
+	return "private/" + a + "/" + b
+}
+
+// seed loads fixture into the empty state of s.
+func (s *Server) seed(fixture Fixture) error {
+	for _, u := range fixture.Users {
+		id := shortID(u.ID)
+		if id == "" {
+			return fmt.Errorf("user %q has no id", u.Username)
+		}
+		u.ID = "users:" + id
+		if u.DisplayName == "" {
+			u.DisplayName = u.Username
+		}
+		if u.Status == "" {
+			u.Status = "online"
+		}
+		if u.CreatedAt == "" {
+			u.CreatedAt = s.now()
+		}
+		if u.Avatar == "" {
+			u.Avatar = s.avatar(id, u.DisplayName)
+		}
+		s.users[id] = &user{User: u.User, password: u.Password, friends: map[string]bool{}}
+	}
+
+	for _, pair := range fixture.Friends {
+		a, b := s.users[shortID(pair[0])], s.users[shortID(pair[1])]
+		if a == nil || b == nil {
+			return fmt.Errorf("unknown user in friends %v", pair)
+		}
+		a.friends[shortID(b.ID)] = true
+		b.friends[shortID(a.ID)] = true
+	}
+
+	for _, f := range fixture.Servers {
+		id := shortID(f.ID)
+		srv := &server{Server: f.Server, owner: shortID(f.Owner)}
+		srv.ID = "servers:" + id
+		srv.Members = nil
+		if srv.CreatedAt == "" {
+			srv.CreatedAt = s.now()
+		}
+		if _, ok := s.users[srv.owner]; !ok {
+			return fmt.Errorf("unknown owner %q of server %q", f.Owner, f.Name)
+		}
+		for _, member := range append([]string{f.Owner}, f.Members...) {
+			member = shortID(member)
+			if _, ok := s.users[member]; !ok {
+				return fmt.Errorf("unknown member %q of server %q", member, f.Name)
+			}
+			if !slices.Contains(srv.members, member) {
+				srv.members = append(srv.members, member)
+			}
+		}
+		// The categories are copied, as the server changes them.
+		srv.Categories = make([]client.Category, len(f.Categories))
+		for i, category := range f.Categories {
+			srv.Categories[i] = client.Category{Name: category.Name, Channels: make([]client.Channel, len(category.Channels))}
+			for j, channel := range category.Channels {
+				channelID := shortID(channel.ID)
+				channel.ID = "channels:" + channelID
+				if channel.Type == "" {
+					channel.Type = "textual"
+				}
+				if channel.CreatedAt == "" {
+					channel.CreatedAt = s.now()
+				}
+				channel.Participants = []client.User{}
+				srv.Categories[i].Channels[j] = channel
+				s.channels[channelID] = id
+			}
+		}
+		s.servers[id] = srv
+	}
+
+	for invite, serverID := range fixture.Invites {
+		if _, ok := s.servers[shortID(serverID)]; !ok {
+			return fmt.Errorf("invite %q to unknown server %q", invite, serverID)
+		}
+		s.invites[invite] = shortID(serverID)
+	}
+
+	start := time.Now().UTC().Add(-time.Duration(len(fixture.Messages)) * time.Minute)
+	for i, f := range fixture.Messages {
+		author := shortID(f.Author)
+		if _, ok := s.users[author]; !ok {
+			return fmt.Errorf("unknown author %q of message %q", f.Author, f.ID)
+		}
+
+		m := &message{Message: f.Message, author: author, reply: f.Reply}
+		if m.ID == "" {
+			m.ID = newID("messages")
+		}
+		if m.CreatedAt == "" {
+			m.CreatedAt = start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339Nano)
+		}
+		if m.UpdatedAt == "" {
+			m.UpdatedAt = m.CreatedAt
+		}
+
+		key, channelID, ok := s.conversation(author, f.ChannelID)
+		if !ok {
+			return fmt.Errorf("message %q to unknown channel %q", m.ID, f.ChannelID)
+		}
+		m.ChannelID = channelID
+		s.conversations[key] = append(s.conversations[key], m)
+	}
+	for _, messages := range s.conversations {
+		sort.SliceStable(messages, func(i, j int) bool {
+			return messages[i].CreatedAt < messages[j].CreatedAt
+		})
+	}
+
+	for _, n := range fixture.Notifications {
+		n := n
+		if n.ID == "" {
+			n.ID = newID("notifications")
+		}
+		if n.CreatedAt == "" {
+			n.CreatedAt = s.now()
+		}
+		userID := shortID(n.UserID)
+		if _, ok := s.users[userID]; !ok {
+			return fmt.Errorf("notification %q for unknown user %q", n.ID, n.UserID)
+		}
+		if n.Type == "friend_request" {
+			initiator, ok := s.users[shortID(n.InitiatorID)]
+			if !ok {
+				return fmt.Errorf("friend request %q from unknown user %q", n.ID, n.InitiatorID)
+			}
+			if n.RequestID == "" {
+				n.RequestID = newID("friend_requests")
+			}
+			if n.Message == "" {
+				n.Message = fmt.Sprintf("%s sent you a friend request", initiator.Username)
+			}
+			s.requests[n.RequestID] = request{initiator: shortID(initiator.ID), receiver: userID}
+		}
+		s.notifications[userID] = append(s.notifications[userID], &n)
+	}
+
+	return nil
+}
+
+// conversation resolves where a message of author to channelID goes: a
+// server channel, or the private conversation with the user channelID
+// names. It returns the key of the conversation and the channel id the
+// message carries. s.mu must be held.
+func (s *Server) conversation(author, channelID string) (string, string, bool) {
+	id := shortID(channelID)
+	if _, ok := s.channels[id]; ok && !strings.HasPrefix(channelID, "users:") {
+		return id, "channels:" + id, true
+	}
+	if _, ok := s.users[id]; ok {
+		return privateKey(author, id), "users:" + id, true
+	}
+	return "", "", false
+}
+
+// public returns u as other users see it.
+func (s *Server) public(u *user) client.User {
+	public := u.User
+	public.Email = ""
+	public.Avatar = s.url(public.Avatar)
+	public.Banner = s.url(public.Banner)
+	return public
+}
+
+// member returns the server with the given id when userID is one of its
+// members. s.mu must be held.
+func (s *Server) member(serverID, userID string) (*server, bool) {
+	srv, ok := s.servers[shortID(serverID)]
+	if !ok || !slices.Contains(srv.members, userID) {
+		return nil, false
+	}
+	return srv, true
+}
+
+// view returns srv with its members, as the API sends it. s.mu must be
+// held.
+func (s *Server) view(srv *server) client.Server {
+	view := srv.Server
+	view.Icon = s.url(view.Icon)
+	view.Banner = s.url(view.Banner)
+	view.Categories = make([]client.Category, len(srv.Categories))
+	for i, category := range srv.Categories {
+		view.Categories[i] = client.Category{
+			Name:     category.Name,
+			Channels: append([]client.Channel{}, category.Channels...),
+		}
+	}
+	view.Members = make([]client.User, 0, len(srv.members))
+	for _, id := range srv.members {
+		view.Members = append(view.Members, s.public(s.users[id]))
+	}
+	return view
+}
@@ -0,0 +1,264 @@
+package fake
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+)
+
+// self returns u as they see themselves. s.mu must be held.
+func (s *Server) self(u *user) client.User {
+	self := s.public(u)
+	self.Email = u.Email
+	return self
+}
+
+func (s *Server) signIn(w http.ResponseWriter, r *http.Request) {
+	var req client.SigninRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *user
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, req.Email) {
+			found = u
+		}
+	}
+	if found == nil {
+		fail(w, http.StatusUnauthorized, "email", "No account with this email")
+		return
+	}
+	if found.password != req.Password {
+		fail(w, http.StatusUnauthorized, "password", "Wrong password")
+		return
+	}
+
+	sessionID := shortID(newID("sessions"))
+	s.sessions[sessionID] = shortID(found.ID)
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: sessionID, Path: "/", HttpOnly: true})
+	self := s.self(found)
+	reply(w, client.UserResponse{Result: client.Success(), User: &self})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	self := s.self(s.users[userID])
+	reply(w, client.UserResponse{Result: client.Success(), User: &self})
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionID := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	delete(s.sessions, sessionID)
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		delete(s.sessions, cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	success(w)
+}
+
+func (s *Server) profile(w http.ResponseWriter, r *http.Request, userID string) {
+	args := pathArgs(r, "/api/v1/user/")
+	if len(args) != 1 {
+		fail(w, http.StatusNotFound, "", "not found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[shortID(args[0])]
+	if !ok {
+		fail(w, http.StatusNotFound, "", "user not found")
+		return
+	}
+
+	profile := s.public(u)
+	if shortID(u.ID) == userID {
+		profile = s.self(u)
+	}
+	reply(w, client.UserResponse{Result: client.Success(), User: &profile})
+}
+
+// contacts returns the users who see the changes of userID: their friends
+// and the members of their servers, themselves included. s.mu must be
+// held.
+func (s *Server) contacts(userID string) []string {
+	ids := []string{userID}
+	seen := map[string]bool{userID: true}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for id := range s.users[userID].friends {
+		add(id)
+	}
+	for _, srv := range s.servers {
+		if slices.Contains(srv.members, userID) {
+			for _, id := range srv.members {
+				add(id)
+			}
+		}
+	}
+	return ids
+}
+
+func (s *Server) changeBanner(w http.ResponseWriter, r *http.Request, userID string) {
+	path, ok := s.upload(w, r, "banner")
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID].Banner = path
+	reply(w, client.BannerResponse{Result: client.Success(), Banner: s.url(path)})
+}
+
+// changeAvatar sets the avatar of the user, or the icon of a server when
+// a server id is sent along.
+func (s *Server) changeAvatar(w http.ResponseWriter, r *http.Request, userID string) {
+	path, ok := s.upload(w, r, "avatar")
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if serverID := r.FormValue("server_id"); serverID != "" {
+		srv, ok := s.member(serverID, userID)
+		if !ok || srv.owner != userID {
+			fail(w, http.StatusForbidden, "", "only the owner can change the icon of a server")
+			return
+		}
+		srv.Icon = path
+		s.emit(srv.members, &pb.WSMessage{
+			Type:    "new_server_icon",
+			Content: &pb.WSMessage_ServerPic{ServerPic: &pb.ChangeServerEl{Id: srv.ID, Picture: s.url(path)}},
+		})
+		reply(w, client.AvatarResponse{Result: client.Success(), Avatar: s.url(path)})
+		return
+	}
+
+	// Unlike the other events, new_avatar carries the short id of the
+	// user.
+	s.users[userID].Avatar = path
+	s.emit(s.contacts(userID), &pb.WSMessage{
+		Type:    "new_avatar",
+		Content: &pb.WSMessage_ChangeAvatar{ChangeAvatar: &pb.ChangeAvatar{UserId: userID, Avatar: s.url(path)}},
+	})
+	reply(w, client.AvatarResponse{Result: client.Success(), Avatar: s.url(path)})
+}
+
+func (s *Server) changeNameColor(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.NameColorRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID].UsernameColor = req.UsernameColor
+	success(w)
+}
+
+func (s *Server) changeStatus(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.StatusRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	switch req.Status {
+	case "online", "absent", "dontdisturb", "offline":
+	default:
+		fail(w, http.StatusBadRequest, "status", "Unknown status")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.users[userID]
+	u.Status = req.Status
+	s.emit(others(s.contacts(userID), userID), &pb.WSMessage{
+		Type:    "change_status",
+		Content: &pb.WSMessage_ChangeStatus{ChangeStatus: &pb.ChangeStatus{UserId: u.ID, Status: u.Status}},
+	})
+	success(w)
+}
+
+func (s *Server) changeDisplayName(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.DisplayNameRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.DisplayName) == "" {
+		fail(w, http.StatusBadRequest, "display_name", "This cannot be empty")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[userID].DisplayName = req.DisplayName
+	success(w)
+}
+
+func (s *Server) changeUsername(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.UsernameRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, u := range s.users {
+		if id != userID && strings.EqualFold(u.Username, req.Username) {
+			fail(w, http.StatusConflict, "username", "This username is already taken")
+			return
+		}
+	}
+
+	s.users[userID].Username = req.Username
+	success(w)
+}
+
+func (s *Server) changeEmail(w http.ResponseWriter, r *http.Request, userID string) {
+	var req client.EmailRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, u := range s.users {
+		if id != userID && strings.EqualFold(u.Email, req.Email) {
+			fail(w, http.StatusConflict, "email", "This email is already used")
+			return
+		}
+	}
+
+	s.users[userID].Email = req.Email
+	success(w)
+}
@@ -16,7 +16,7 @@ export async function addFriend(
 	};
 
 	try {
-		const response = await AddFriend(JSON.stringify(body));
+		const response = await AddFriend(body);
 
 		if (response.message !== 'success') {
 			throw new Error(`error occured when accepting friend request ${response.status}`);
@@ -38,7 +38,7 @@ export async function acceptFriendRequest(
 	};
 
 	try {
-		const response = await AcceptFriend(JSON.stringify(body));
+		const response = await AcceptFriend(body);
 
 		if (response.message !== 'success') {
 			throw new Error(`error occured when accepting friend request ${response.status}`);
@@ -57,7 +57,7 @@ export async function refuseFriendRequest(request_id: string, notif_id: string):
 	};
 
 	try {
-		const response = await RefuseFriend(JSON.stringify(body));
+		const response = await RefuseFriend(body);
 
 		if (response.message !== 'success') {
 			throw new Error(`error occured when refusing friend request ${response.status}`);
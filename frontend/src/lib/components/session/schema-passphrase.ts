@@ -0,0 +1,7 @@
+import { z } from 'zod';
+
+export const passphraseSchema = z.object({
+	passphrase: z.string().min(1, { message: 'A passphrase is required' })
+});
+
+export type passphraseFormSchema = typeof passphraseSchema;
@@ -1,11 +1,14 @@
-import { messages, notifications, user } from './stores';
+import { messages, notifications, servers, user } from './stores';
 import { get } from 'svelte/store';
 import type { Message } from './types';
 import { page } from '$app/stores';
 import {
+	CachedMessages,
 	CreateInvitation,
 	GetMessages,
+	GetOlderMessages,
 	GetNotifications,
+	GetServers,
 	IndicateTyping,
 	SyncNotifications,
 	GetProfile
@@ -28,7 +31,7 @@ export async function getProfile(user_id: string) {
 		}
 	}
 	try {
-		const response = await GetProfile(JSON.stringify({ user_id: user_id }));
+		const response = await GetProfile({ user_id: user_id });
 
 		if (!response.user) {
 			throw new Error('Error occured when fetching profile.');
@@ -52,38 +55,103 @@ export async function getProfile(user_id: string) {
 
 export async function getMessages(params: any): Promise<Message[] | undefined> {
 	const messagesCache = get(messages);
-	const userStore = get(user);
 	const channelId = params.channelId ? params.channelId : params.id;
 	if (messagesCache && messagesCache[channelId]) {
 		return messagesCache[channelId].messages;
 	}
 
-	let response: { [key: string]: any };
+	await fetchMessages(params);
+}
+
+// fetchMessages loads the messages of a channel, or of a private
+// conversation, into the cache, replacing what it held before. The stored
+// history is shown right away, then completed with what is new on the
+// server.
+async function fetchMessages(params: any) {
+	const userStore = get(user);
+	const channelId = params.channelId ? params.channelId : params.id;
+	const request = params.channelId
+		? { channel_id: channelId }
+		: { channel_id: channelId, user_id: userStore?.id.split(':')[1] };
+
 	try {
-		if (params.channelId) {
-			response = await GetMessages(JSON.stringify({ channel_id: channelId }));
-		} else {
-			response = await GetMessages(
-				JSON.stringify({ channel_id: channelId, user_id: userStore?.id.split(':')[1] })
-			);
+		const cached = await CachedMessages(request);
+		if (cached.message === 'success' && cached.messages?.length > 0) {
+			setMessages(channelId, cached.messages, cached.has_more);
 		}
 
-		if (response.status && response.status !== 200) {
+		const response = await GetMessages(request);
+		if (response.message !== 'success') {
 			throw new Error(`error on validating session: ${response}`);
 		}
 
+		setMessages(channelId, response.messages, response.has_more);
+	} catch (error) {
+		console.error('Error fetching messages:', error);
+	}
+}
+
+function setMessages(channelId: string, list: Message[], more?: boolean) {
+	messages.update((cache) => {
+		cache[channelId] = {
+			...cache[channelId],
+			messages: list ?? [],
+			more: more,
+			date: Date.now()
+		};
+		return cache;
+	});
+}
+
+// getOlderMessages prepends the page of messages before the oldest one in
+// the cache and reports whether any were added.
+export async function getOlderMessages(params: any): Promise<boolean> {
+	const userStore = get(user);
+	const channelId = params.channelId ? params.channelId : params.id;
+	const current = get(messages)[channelId];
+	if (!current?.more || current.messages.length === 0) {
+		return false;
+	}
+
+	try {
+		const response = await GetOlderMessages({
+			channel_id: channelId,
+			user_id: params.channelId ? undefined : userStore?.id.split(':')[1],
+			before: current.messages[0].id
+		});
+		if (response.message !== 'success') {
+			throw new Error(`error loading older messages: ${response.message}`);
+		}
+
 		messages.update((cache) => {
 			cache[channelId] = {
-				messages: response.messages,
-				date: Date.now()
+				...cache[channelId],
+				messages: [...(response.messages ?? []), ...cache[channelId].messages],
+				more: response.has_more
 			};
 			return cache;
 		});
+
+		return response.messages?.length > 0;
 	} catch (error) {
-		console.error('Error fetching messages:', error);
+		console.error(error);
+		return false;
 	}
 }
 
+// loadMessage pages back through a conversation until the message of that
+// id is in the cache, and reports whether it was found.
+export async function loadMessage(params: any, id: string): Promise<boolean> {
+	const channelId = params.channelId ? params.channelId : params.id;
+	await getMessages(params);
+	while (!get(messages)[channelId]?.messages.some((message) => message.id === id)) {
+		if (!(await getOlderMessages(params))) {
+			return false;
+		}
+	}
+	return true;
+}
+
 export const mergeObj = (target: Object, source: Object) => {
 	for (let key in source) {
 		if (!target.hasOwnProperty(key)) {
@@ -106,7 +174,7 @@ export async function typing(status: string) {
 	};
 
 	try {
-		const response = await IndicateTyping(JSON.stringify(body));
+		const response = await IndicateTyping(body);
 
 		if (response.message !== 'success') {
 			throw new Error(`typing error ${response.status}`);
@@ -130,7 +198,7 @@ export function syncNotifications() {
 			.map((notif) => notif.channel_id)
 	};
 
-	SyncNotifications(JSON.stringify(body));
+	SyncNotifications(body);
 }
 
 let syncTimeout;
@@ -145,11 +213,9 @@ export async function fetchNotifs() {
 	if (!userInfos) return;
 
 	try {
-		const response = await GetNotifications(
-			JSON.stringify({ user_id: userInfos.id.split(':')[1] })
-		);
+		const response = await GetNotifications({ user_id: userInfos.id.split(':')[1] });
 
-		if (response.status && response.status !== 200) {
+		if (response.message !== 'success') {
 			throw new Error("couldn't fetch notifications");
 		}
 
@@ -170,9 +236,9 @@ export async function createInvitation() {
 	};
 
 	try {
-		const response = await CreateInvitation(JSON.stringify(body));
+		const response = await CreateInvitation(body);
 
-		if (response.status && response.status !== 200) {
+		if (response.message !== 'success') {
 			throw new Error(response.message);
 		}
 
@@ -181,3 +247,44 @@ export async function createInvitation() {
 		console.log(e);
 	}
 }
+
+export async function fetchServers() {
+	const userInfos = get(user);
+	if (!userInfos) return;
+
+	try {
+		const response = await GetServers({ user_id: userInfos.id.split(':')[1] });
+
+		if (response.message !== 'success') {
+			throw new Error("couldn't fetch servers");
+		}
+
+		servers.update((cache) => {
+			response.servers.forEach((server) => {
+				cache[server.id] = { ...server };
+			});
+			return cache;
+		});
+	} catch (error) {
+		console.log(error);
+	}
+}
+
+// resync catches up after the realtime connection was lost. The open
+// conversation is fetched again; the other ones are dropped from the cache
+// and fetched when they are next opened.
+export async function resync() {
+	const params = get(page).params;
+	const openId = params.channelId || params.id;
+
+	messages.update((cache) => {
+		for (const channelId in cache) {
+			if (channelId !== openId) {
+				delete cache[channelId];
+			}
+		}
+		return cache;
+	});
+
+	await Promise.all([openId ? fetchMessages(params) : undefined, fetchNotifs(), fetchServers()]);
+}
@@ -17,13 +17,13 @@ import {
 	servers,
 	sharingScreen,
 	user,
-	vcRoom,
-	wsConn
+	vcRoom
 } from './stores';
 import { get } from 'svelte/store';
 import { page } from '$app/stores';
 import { goto } from '$app/navigation';
-import { GenerateRoomToken } from '$lib/wailsjs/go/main/App';
+import { GenerateRoomToken, SendParticipantUpdate } from '$lib/wailsjs/go/main/App';
+import { gateway } from '$lib/wailsjs/go/models';
 
 export async function joinRoom(channelId: string, userId: string, serverId: string) {
 	const existingRoom = get(vcRoom);
@@ -96,8 +96,6 @@ export async function joinRoom(channelId: string, userId: string, serverId: stri
 	goto(`/hudori/chat/community/${serverId.split(':')[1]}/channels/${channelId.split(':')[1]}`);
 
 	if (!exist) {
-		const ws = get(wsConn);
-
 		const wsMessNew = {
 			type: 'new_participant',
 			content: {
@@ -112,7 +110,7 @@ export async function joinRoom(channelId: string, userId: string, serverId: stri
 			}
 		};
 
-		ws?.send(JSON.stringify(wsMessNew));
+		SendParticipantUpdate(gateway.ParticipantUpdate.createFrom(wsMessNew));
 	}
 }
 
@@ -126,7 +124,6 @@ export async function quitRoom(serverId: string) {
 	const pageInfos = get(page);
 
 	if (pageInfos.url.pathname.includes(serverId.split(':')[1])) {
-		const ws = get(wsConn);
 		const userInfos = get(user);
 		const wsMess = {
 			type: 'quit_participant',
@@ -137,7 +134,7 @@ export async function quitRoom(serverId: string) {
 			}
 		};
 
-		ws?.send(JSON.stringify(wsMess));
+		SendParticipantUpdate(gateway.ParticipantUpdate.createFrom(wsMess));
 	}
 }
 
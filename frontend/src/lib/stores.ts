@@ -6,12 +6,15 @@ import type {
 	MessageCache,
 	ServersCache,
 	TypingState,
-	Message
+	Message,
+	UploadProgress
 } from './types';
 import { type SuperValidated, type Infer } from 'sveltekit-superforms';
 import type { FriendRequestFormSchema } from './components/friends/schema-friend-request';
 import { browser } from '$app/environment';
 import type { Room } from 'livekit-client';
+import { SendParticipantUpdate } from '$lib/wailsjs/go/main/App';
+import { gateway, media, outbox as outboxModels } from '$lib/wailsjs/go/models';
 
 type ContextMenuServer = {
 	id: string;
@@ -24,14 +27,16 @@ export const messages = writable<MessageCache>({});
 export const notifications = writable<Notification[]>([]);
 export const friends = writable<User[]>();
 export const contextMenuInfo = writable<ContextMenuServer | undefined>();
-export const wsConn = writable<WebSocket | undefined>();
 export const vcRoom = writable<Room | undefined>();
 export const mutedState = writable({ muteHead: false, muteMic: false });
 export const settingsLastPage = writable<string | undefined>();
 export const loadingMessages = writable<boolean>(false);
 export const sharingScreen = writable<boolean>(false);
 export const usersTyping = writable<TypingState[]>([]);
-export const messProto = writable();
+export const gatewayState = writable<gateway.StateChange | undefined>();
+export const outbox = writable<{ [id: string]: outboxModels.Entry }>({});
+export const uploads = writable<{ [id: string]: UploadProgress }>({});
+export const downloads = writable<{ [id: string]: media.Download }>({});
 export const editingMessage = writable<string>('');
 export const replyTo = writable<Message | undefined>();
 
@@ -116,7 +121,6 @@ export const addParticipant = (serverId: string, channelId: string, user: User)
 	});
 
 	const states = get(mutedState);
-	const ws = get(wsConn);
 	const wsMessStatus = {
 		type: 'participant_status',
 		content: {
@@ -127,7 +131,7 @@ export const addParticipant = (serverId: string, channelId: string, user: User)
 			deafen: states.muteHead
 		}
 	};
-	ws?.send(JSON.stringify(wsMessStatus));
+	SendParticipantUpdate(gateway.ParticipantUpdate.createFrom(wsMessStatus));
 };
 
 export const removeParticipant = (serverId: string, channelId: string, userId: string) => {
@@ -90,6 +90,7 @@ export interface MessageCache {
 	[channelId: string]: {
 		messages: Message[];
 		scrollPosition?: number;
+		more?: boolean;
 		date: number;
 	};
 }
@@ -129,3 +130,15 @@ export interface TypingState {
 	display_name: string;
 	channel_id: string;
 }
+
+// UploadProgress is sent with upload:progress events while the attachments
+// of a message are uploaded.
+export interface UploadProgress {
+	id: string;
+	file: string;
+	index: number;
+	sent: number;
+	size: number;
+	total_sent: number;
+	total: number;
+}
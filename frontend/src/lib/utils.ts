@@ -106,6 +106,32 @@ export function manageTooltip(node: HTMLAnchorElement) {
 	};
 }
 
+// showMessage scrolls the message of that id into the middle of the view
+// and highlights it once it is there. It reports whether the message is
+// on screen to be shown.
+export function showMessage(id: string, behavior: ScrollBehavior = 'smooth'): boolean {
+	const message = document.querySelector(`[data-messageid='${id}']`);
+	if (!message) return false;
+	message.scrollIntoView({ behavior, block: 'center' });
+	const observer = new IntersectionObserver(
+		(entries) => {
+			entries.forEach((entry) => {
+				if (entry.isIntersecting) {
+					message.classList.add('!bg-zinc-650/60');
+					setTimeout(() => {
+						message.classList.remove('!bg-zinc-650/60');
+					}, 1000);
+					observer.disconnect();
+				}
+			});
+		},
+		{ threshold: 0.5 }
+	);
+
+	observer.observe(message);
+	return true;
+}
+
 export function handleContextMenu(id: string, roles: string[] | undefined = undefined) {
 	const informations = {
 		id: id,
@@ -162,3 +188,27 @@ export async function removeCachedProfile(user_id: string) {
 		await cache.delete(`${import.meta.env.VITE_API_URL}/api/v1/user/${user_id}`);
 	}
 }
+
+// mediaURL returns the local URL the Go side serves a remote file from, out of its media
+// cache. Pictures are resized when w or h is given; fit 'cover' crops them to fill the box.
+// Other URLs, such as data: URLs of previews, are returned as they are.
+export function mediaURL(
+	url: string | undefined,
+	size: { w?: number; h?: number; fit?: 'contain' | 'cover' } = {}
+): string | undefined {
+	if (!url || !/^https?:\/\//.test(url)) return url;
+
+	const bytes = new TextEncoder().encode(url);
+	const key = btoa(String.fromCharCode(...bytes))
+		.replace(/\+/g, '-')
+		.replace(/\//g, '_')
+		.replace(/=+$/, '');
+
+	const params = new URLSearchParams();
+	if (size.w) params.set('w', String(size.w));
+	if (size.h) params.set('h', String(size.h));
+	if (size.fit) params.set('fit', size.fit);
+	const query = params.toString();
+
+	return `/media/${key}${query ? `?${query}` : ''}`;
+}
@@ -1,75 +1,144 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {client} from '../models';
 import {main} from '../models';
+import {media} from '../models';
+import {gateway} from '../models';
+import {config} from '../models';
+import {account} from '../models';
+import {attachment} from '../models';
+import {outbox} from '../models';
 
-export function AcceptFriend(arg1:string):Promise<{[key: string]: any}>;
+export function AcceptFriend(arg1:client.FriendRequestReply):Promise<client.FriendResponse>;
 
-export function AddFriend(arg1:string):Promise<{[key: string]: any}>;
+export function AddAccount():Promise<client.Result>;
 
-export function AuthVerify():Promise<{[key: string]: any}>;
+export function AddFriend(arg1:client.AddFriendRequest):Promise<client.Result>;
 
-export function ChangeAvatar(arg1:string):Promise<{[key: string]: any}>;
+export function AuthVerify():Promise<client.UserResponse>;
 
-export function ChangeBanner(arg1:Array<number>,arg2:string,arg3:number,arg4:number,arg5:number,arg6:number,arg7:string):Promise<{[key: string]: any}>;
+export function CachedMessages(arg1:client.MessagesRequest):Promise<client.MessagesResponse>;
 
-export function ChangeDPName(arg1:string):Promise<{[key: string]: any}>;
+export function CancelDownload(arg1:string):Promise<client.Result>;
 
-export function ChangeEmail(arg1:string):Promise<{[key: string]: any}>;
+export function CancelUpload(arg1:string):Promise<client.Result>;
 
-export function ChangeNameColor(arg1:string):Promise<{[key: string]: any}>;
+export function ChangeAvatar(arg1:client.AvatarChangeRequest):Promise<client.AvatarResponse>;
 
-export function ChangeUsername(arg1:string):Promise<{[key: string]: any}>;
+export function ChangeBanner(arg1:client.BannerChangeRequest):Promise<client.BannerResponse>;
 
-export function CreateCategory(arg1:string):Promise<{[key: string]: any}>;
+export function ChangeDPName(arg1:client.DisplayNameRequest):Promise<client.Result>;
 
-export function CreateChannel(arg1:string):Promise<{[key: string]: any}>;
+export function ChangeEmail(arg1:client.EmailRequest):Promise<client.Result>;
 
-export function CreateInvitation(arg1:string):Promise<{[key: string]: any}>;
+export function ChangeNameColor(arg1:client.NameColorRequest):Promise<client.Result>;
 
-export function CreateMessage(arg1:any,arg2:string,arg3:string,arg4:Array<string>,arg5:string,arg6:boolean,arg7:string,arg8:Array<main.File>):Promise<{[key: string]: any}>;
+export function ChangeStatus(arg1:client.StatusRequest):Promise<client.Result>;
 
-export function CreateServer(arg1:string):Promise<{[key: string]: any}>;
+export function ChangeUsername(arg1:client.UsernameRequest):Promise<client.Result>;
 
-export function DeleteCategory(arg1:string):Promise<{[key: string]: any}>;
+export function CreateCategory(arg1:client.CategoryRequest):Promise<client.Result>;
 
-export function DeleteChannel(arg1:string):Promise<{[key: string]: any}>;
+export function CreateChannel(arg1:client.CreateChannelRequest):Promise<client.Result>;
 
-export function DeleteFriend(arg1:string):Promise<{[key: string]: any}>;
+export function CreateInvitation(arg1:client.ServerRequest):Promise<client.InvitationResponse>;
 
-export function DeleteMessage(arg1:string):Promise<{[key: string]: any}>;
+export function CreateMessage(arg1:client.NewMessage,arg2:Array<client.File>,arg3:Array<string>,arg4:boolean):Promise<main.MessageResponse>;
 
-export function DeleteServer(arg1:string):Promise<{[key: string]: any}>;
+export function CreateServer(arg1:client.CreateServerRequest):Promise<client.ServerResponse>;
 
-export function EditMessage(arg1:string):Promise<{[key: string]: any}>;
+export function DeleteCategory(arg1:client.CategoryRequest):Promise<client.Result>;
 
-export function GenerateRoomToken(arg1:string,arg2:string):Promise<{[key: string]: any}>;
+export function DeleteChannel(arg1:client.DeleteChannelRequest):Promise<client.Result>;
 
-export function GetFriends(arg1:string):Promise<{[key: string]: any}>;
+export function DeleteFriend(arg1:client.DeleteFriendRequest):Promise<client.Result>;
 
-export function GetMessages(arg1:string):Promise<{[key: string]: any}>;
+export function DeleteMessage(arg1:client.DeleteMessageRequest):Promise<client.Result>;
 
-export function GetNotifications(arg1:string):Promise<{[key: string]: any}>;
+export function DeleteServer(arg1:client.ServerRequest):Promise<client.Result>;
 
-export function GetProfile(arg1:string):Promise<{[key: string]: any}>;
+export function DiscardMessage(arg1:string):Promise<client.Result>;
 
-export function GetServer(arg1:string):Promise<{[key: string]: any}>;
+export function Downloads():Promise<Array<media.Download>>;
 
-export function GetServers(arg1:string):Promise<{[key: string]: any}>;
+export function EditMessage(arg1:client.EditMessageRequest):Promise<client.Result>;
+
+export function FocusConversation(arg1:string):Promise<void>;
+
+export function ForgetSessions():Promise<client.Result>;
+
+export function GatewayState():Promise<gateway.StateChange>;
+
+export function GenerateRoomToken(arg1:string,arg2:string):Promise<client.RoomTokenResponse>;
+
+export function GetBackendProfiles():Promise<{[key: string]: any}>;
+
+export function GetFriends(arg1:client.UserRequest):Promise<client.FriendsResponse>;
+
+export function GetMessages(arg1:client.MessagesRequest):Promise<client.MessagesResponse>;
+
+export function GetNotifications(arg1:client.UserRequest):Promise<client.NotificationsResponse>;
+
+export function GetOlderMessages(arg1:client.MessagesRequest):Promise<client.MessagesResponse>;
+
+export function GetPreferences():Promise<config.Preferences>;
+
+export function GetProfile(arg1:client.UserRequest):Promise<client.UserResponse>;
+
+export function GetServer(arg1:client.ServerRequest):Promise<client.ServerResponse>;
+
+export function GetServers(arg1:client.UserRequest):Promise<client.ServersResponse>;
 
 export function Greet(arg1:string):Promise<string>;
 
-export function IndicateTyping(arg1:string):Promise<{[key: string]: any}>;
+export function IndicateTyping(arg1:client.TypingRequest):Promise<client.Result>;
+
+export function IsAuthenticated():Promise<client.Result>;
+
+export function JoinServer(arg1:client.JoinServerRequest):Promise<client.ServerResponse>;
+
+export function ListAccounts():Promise<Array<account.Info>>;
+
+export function LogoutHudori():Promise<main.LogoutResponse>;
+
+export function OpenAttachments():Promise<attachment.Batch>;
+
+export function OpenPendingLink():Promise<void>;
+
+export function PendingMessages():Promise<Array<outbox.Entry>>;
+
+export function QuitServer(arg1:client.ServerRequest):Promise<client.Result>;
+
+export function RefuseFriend(arg1:client.FriendRequestReply):Promise<client.Result>;
+
+export function RemoveAccount(arg1:string):Promise<main.LogoutResponse>;
+
+export function RetryDownload(arg1:string):Promise<client.Result>;
+
+export function RetryMessage(arg1:string):Promise<client.Result>;
+
+export function SaveFile(arg1:string,arg2:string):Promise<client.Result>;
+
+export function SearchMessages(arg1:client.SearchRequest):Promise<client.SearchResponse>;
+
+export function SendParticipantUpdate(arg1:gateway.ParticipantUpdate):Promise<client.Result>;
+
+export function SessionPassphrase():Promise<main.PassphraseResponse>;
+
+export function SetPreferences(arg1:config.Preferences):Promise<client.Result>;
+
+export function SetSessionPassphrase(arg1:string):Promise<client.Result>;
 
-export function IsAuthenticated():Promise<{[key: string]: any}>;
+export function SetUnread(arg1:number,arg2:number):Promise<void>;
 
-export function JoinServer(arg1:string):Promise<{[key: string]: any}>;
+export function SignIn(arg1:client.SigninRequest):Promise<client.UserResponse>;
 
-export function LogoutHudori():Promise<{[key: string]: any}>;
+export function SwitchAccount(arg1:string):Promise<client.Result>;
 
-export function QuitServer(arg1:string):Promise<{[key: string]: any}>;
+export function SwitchBackendProfile(arg1:string):Promise<main.ProfileResponse>;
 
-export function RefuseFriend(arg1:string):Promise<{[key: string]: any}>;
+export function SyncNotifications(arg1:client.SyncNotificationsRequest):Promise<client.Result>;
 
-export function SignIn(arg1:string):Promise<{[key: string]: any}>;
+export function TestConnection(arg1:string):Promise<client.ProbeResponse>;
 
-export function SyncNotifications(arg1:string):Promise<{[key: string]: any}>;
+export function UnstageAttachment(arg1:string):Promise<void>;
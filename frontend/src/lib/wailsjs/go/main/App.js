@@ -6,6 +6,10 @@ export function AcceptFriend(arg1) {
   return window['go']['main']['App']['AcceptFriend'](arg1);
 }
 
+export function AddAccount() {
+  return window['go']['main']['App']['AddAccount']();
+}
+
 export function AddFriend(arg1) {
   return window['go']['main']['App']['AddFriend'](arg1);
 }
@@ -14,12 +18,24 @@ export function AuthVerify() {
   return window['go']['main']['App']['AuthVerify']();
 }
 
+export function CachedMessages(arg1) {
+  return window['go']['main']['App']['CachedMessages'](arg1);
+}
+
+export function CancelDownload(arg1) {
+  return window['go']['main']['App']['CancelDownload'](arg1);
+}
+
+export function CancelUpload(arg1) {
+  return window['go']['main']['App']['CancelUpload'](arg1);
+}
+
 export function ChangeAvatar(arg1) {
   return window['go']['main']['App']['ChangeAvatar'](arg1);
 }
 
-export function ChangeBanner(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
-  return window['go']['main']['App']['ChangeBanner'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
+export function ChangeBanner(arg1) {
+  return window['go']['main']['App']['ChangeBanner'](arg1);
 }
 
 export function ChangeDPName(arg1) {
@@ -34,6 +50,10 @@ export function ChangeNameColor(arg1) {
   return window['go']['main']['App']['ChangeNameColor'](arg1);
 }
 
+export function ChangeStatus(arg1) {
+  return window['go']['main']['App']['ChangeStatus'](arg1);
+}
+
 export function ChangeUsername(arg1) {
   return window['go']['main']['App']['ChangeUsername'](arg1);
 }
@@ -50,8 +70,8 @@ export function CreateInvitation(arg1) {
   return window['go']['main']['App']['CreateInvitation'](arg1);
 }
 
-export function CreateMessage(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8) {
-  return window['go']['main']['App']['CreateMessage'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8);
+export function CreateMessage(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['CreateMessage'](arg1, arg2, arg3, arg4);
 }
 
 export function CreateServer(arg1) {
@@ -78,14 +98,38 @@ export function DeleteServer(arg1) {
   return window['go']['main']['App']['DeleteServer'](arg1);
 }
 
+export function DiscardMessage(arg1) {
+  return window['go']['main']['App']['DiscardMessage'](arg1);
+}
+
+export function Downloads() {
+  return window['go']['main']['App']['Downloads']();
+}
+
 export function EditMessage(arg1) {
   return window['go']['main']['App']['EditMessage'](arg1);
 }
 
+export function FocusConversation(arg1) {
+  return window['go']['main']['App']['FocusConversation'](arg1);
+}
+
+export function ForgetSessions() {
+  return window['go']['main']['App']['ForgetSessions']();
+}
+
+export function GatewayState() {
+  return window['go']['main']['App']['GatewayState']();
+}
+
 export function GenerateRoomToken(arg1, arg2) {
   return window['go']['main']['App']['GenerateRoomToken'](arg1, arg2);
 }
 
+export function GetBackendProfiles() {
+  return window['go']['main']['App']['GetBackendProfiles']();
+}
+
 export function GetFriends(arg1) {
   return window['go']['main']['App']['GetFriends'](arg1);
 }
@@ -98,6 +142,14 @@ export function GetNotifications(arg1) {
   return window['go']['main']['App']['GetNotifications'](arg1);
 }
 
+export function GetOlderMessages(arg1) {
+  return window['go']['main']['App']['GetOlderMessages'](arg1);
+}
+
+export function GetPreferences() {
+  return window['go']['main']['App']['GetPreferences']();
+}
+
 export function GetProfile(arg1) {
   return window['go']['main']['App']['GetProfile'](arg1);
 }
@@ -126,10 +178,26 @@ export function JoinServer(arg1) {
   return window['go']['main']['App']['JoinServer'](arg1);
 }
 
+export function ListAccounts() {
+  return window['go']['main']['App']['ListAccounts']();
+}
+
 export function LogoutHudori() {
   return window['go']['main']['App']['LogoutHudori']();
 }
 
+export function OpenAttachments() {
+  return window['go']['main']['App']['OpenAttachments']();
+}
+
+export function OpenPendingLink() {
+  return window['go']['main']['App']['OpenPendingLink']();
+}
+
+export function PendingMessages() {
+  return window['go']['main']['App']['PendingMessages']();
+}
+
 export function QuitServer(arg1) {
   return window['go']['main']['App']['QuitServer'](arg1);
 }
@@ -138,10 +206,66 @@ export function RefuseFriend(arg1) {
   return window['go']['main']['App']['RefuseFriend'](arg1);
 }
 
+export function RemoveAccount(arg1) {
+  return window['go']['main']['App']['RemoveAccount'](arg1);
+}
+
+export function RetryDownload(arg1) {
+  return window['go']['main']['App']['RetryDownload'](arg1);
+}
+
+export function RetryMessage(arg1) {
+  return window['go']['main']['App']['RetryMessage'](arg1);
+}
+
+export function SaveFile(arg1, arg2) {
+  return window['go']['main']['App']['SaveFile'](arg1, arg2);
+}
+
+export function SearchMessages(arg1) {
+  return window['go']['main']['App']['SearchMessages'](arg1);
+}
+
+export function SendParticipantUpdate(arg1) {
+  return window['go']['main']['App']['SendParticipantUpdate'](arg1);
+}
+
+export function SessionPassphrase() {
+  return window['go']['main']['App']['SessionPassphrase']();
+}
+
+export function SetPreferences(arg1) {
+  return window['go']['main']['App']['SetPreferences'](arg1);
+}
+
+export function SetSessionPassphrase(arg1) {
+  return window['go']['main']['App']['SetSessionPassphrase'](arg1);
+}
+
+export function SetUnread(arg1, arg2) {
+  return window['go']['main']['App']['SetUnread'](arg1, arg2);
+}
+
 export function SignIn(arg1) {
   return window['go']['main']['App']['SignIn'](arg1);
 }
 
+export function SwitchAccount(arg1) {
+  return window['go']['main']['App']['SwitchAccount'](arg1);
+}
+
+export function SwitchBackendProfile(arg1) {
+  return window['go']['main']['App']['SwitchBackendProfile'](arg1);
+}
+
 export function SyncNotifications(arg1) {
   return window['go']['main']['App']['SyncNotifications'](arg1);
 }
+
+export function TestConnection(arg1) {
+  return window['go']['main']['App']['TestConnection'](arg1);
+}
+
+export function UnstageAttachment(arg1) {
+  return window['go']['main']['App']['UnstageAttachment'](arg1);
+}
@@ -1,16 +1,1756 @@
+export namespace account {
+	
+	export class Info {
+	    id: string;
+	    profile: string;
+	    user: client.User;
+	    active: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Info(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.profile = source["profile"];
+	        this.user = this.convertValues(source["user"], client.User);
+	        this.active = source["active"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace attachment {
+	
+	export class Staged {
+	    id: string;
+	    name: string;
+	    size: number;
+	    mime: string;
+	    thumbnail?: string;
+	    metadata?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Staged(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.size = source["size"];
+	        this.mime = source["mime"];
+	        this.thumbnail = source["thumbnail"];
+	        this.metadata = source["metadata"];
+	    }
+	}
+	export class Batch {
+	    staged: Staged[];
+	    errors?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Batch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.staged = this.convertValues(source["staged"], Staged);
+	        this.errors = source["errors"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace client {
+	
+	export class AddFriendRequest {
+	    initiator_id: string;
+	    initiator_username: string;
+	    receiver_username: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AddFriendRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.initiator_id = source["initiator_id"];
+	        this.initiator_username = source["initiator_username"];
+	        this.receiver_username = source["receiver_username"];
+	    }
+	}
+	export class AvatarChangeRequest {
+	    cropX: number;
+	    cropY: number;
+	    cropWidth: number;
+	    cropHeight: number;
+	    fileData: number[];
+	    fileName: string;
+	    oldAvatar: string;
+	    serverId?: string;
+	    friends?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AvatarChangeRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.cropX = source["cropX"];
+	        this.cropY = source["cropY"];
+	        this.cropWidth = source["cropWidth"];
+	        this.cropHeight = source["cropHeight"];
+	        this.fileData = source["fileData"];
+	        this.fileName = source["fileName"];
+	        this.oldAvatar = source["oldAvatar"];
+	        this.serverId = source["serverId"];
+	        this.friends = source["friends"];
+	    }
+	}
+	export class AvatarResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    avatar?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AvatarResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.avatar = source["avatar"];
+	    }
+	}
+	export class BannerChangeRequest {
+	    cropX: number;
+	    cropY: number;
+	    cropWidth: number;
+	    cropHeight: number;
+	    fileData: number[];
+	    fileName: string;
+	    oldBanner: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BannerChangeRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.cropX = source["cropX"];
+	        this.cropY = source["cropY"];
+	        this.cropWidth = source["cropWidth"];
+	        this.cropHeight = source["cropHeight"];
+	        this.fileData = source["fileData"];
+	        this.fileName = source["fileName"];
+	        this.oldBanner = source["oldBanner"];
+	    }
+	}
+	export class BannerResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    banner?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BannerResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.banner = source["banner"];
+	    }
+	}
+	export class User {
+	    id: string;
+	    email?: string;
+	    username: string;
+	    display_name: string;
+	    username_color?: string;
+	    avatar: string;
+	    banner: string;
+	    status: string;
+	    about_me: string;
+	    created_at: string;
+	    deafen: boolean;
+	    muted: boolean;
+	    talking: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new User(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.email = source["email"];
+	        this.username = source["username"];
+	        this.display_name = source["display_name"];
+	        this.username_color = source["username_color"];
+	        this.avatar = source["avatar"];
+	        this.banner = source["banner"];
+	        this.status = source["status"];
+	        this.about_me = source["about_me"];
+	        this.created_at = source["created_at"];
+	        this.deafen = source["deafen"];
+	        this.muted = source["muted"];
+	        this.talking = source["talking"];
+	    }
+	}
+	export class Channel {
+	    id: string;
+	    name: string;
+	    type: string;
+	    private: boolean;
+	    created_at: string;
+	    participants: User[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Channel(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.type = source["type"];
+	        this.private = source["private"];
+	        this.created_at = source["created_at"];
+	        this.participants = this.convertValues(source["participants"], User);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Category {
+	    name: string;
+	    channels: Channel[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Category(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.channels = this.convertValues(source["channels"], Channel);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CategoryRequest {
+	    server_id: string;
+	    category_name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CategoryRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.server_id = source["server_id"];
+	        this.category_name = source["category_name"];
+	    }
+	}
+	
+	export class CreateChannelRequest {
+	    name: string;
+	    channel_type: string;
+	    category_name: string;
+	    server_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CreateChannelRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.channel_type = source["channel_type"];
+	        this.category_name = source["category_name"];
+	        this.server_id = source["server_id"];
+	    }
+	}
+	export class CreateServerRequest {
+	    user_id: string;
+	    name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CreateServerRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.name = source["name"];
+	    }
+	}
+	export class DeleteChannelRequest {
+	    channel_id: string;
+	    category_name: string;
+	    server_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeleteChannelRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.channel_id = source["channel_id"];
+	        this.category_name = source["category_name"];
+	        this.server_id = source["server_id"];
+	    }
+	}
+	export class DeleteFriendRequest {
+	    user_id: string;
+	    friend_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeleteFriendRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.friend_id = source["friend_id"];
+	    }
+	}
+	export class DeleteMessageRequest {
+	    channel_id: string;
+	    message_id: string;
+	    private_message: boolean;
+	    author_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeleteMessageRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.channel_id = source["channel_id"];
+	        this.message_id = source["message_id"];
+	        this.private_message = source["private_message"];
+	        this.author_id = source["author_id"];
+	    }
+	}
+	export class DisplayNameRequest {
+	    user_id: string;
+	    display_name: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DisplayNameRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.display_name = source["display_name"];
+	    }
+	}
+	export class EditMessageRequest {
+	    channel_id: string;
+	    message_id: string;
+	    private_message: boolean;
+	    author_id: string;
+	    content: string;
+	    mentions: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new EditMessageRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.channel_id = source["channel_id"];
+	        this.message_id = source["message_id"];
+	        this.private_message = source["private_message"];
+	        this.author_id = source["author_id"];
+	        this.content = source["content"];
+	        this.mentions = source["mentions"];
+	    }
+	}
+	export class EmailRequest {
+	    user_id: string;
+	    email: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new EmailRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.email = source["email"];
+	    }
+	}
+	export class File {
+	    name: string;
+	    data: number[];
+	    path?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new File(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.data = source["data"];
+	        this.path = source["path"];
+	    }
+	}
+	export class FriendRequestReply {
+	    id: string;
+	    request_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FriendRequestReply(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.request_id = source["request_id"];
+	    }
+	}
+	export class FriendResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    friend?: User;
+	
+	    static createFrom(source: any = {}) {
+	        return new FriendResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.friend = this.convertValues(source["friend"], User);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FriendsResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    friends: User[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FriendsResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.friends = this.convertValues(source["friends"], User);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class InvitationResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    id?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new InvitationResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.id = source["id"];
+	    }
+	}
+	export class JoinServerRequest {
+	    user: User;
+	    invite_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new JoinServerRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user = this.convertValues(source["user"], User);
+	        this.invite_id = source["invite_id"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Reply {
+	    id: string;
+	    author: User;
+	    content: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Reply(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.author = this.convertValues(source["author"], User);
+	        this.content = source["content"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Message {
+	    id: string;
+	    author: User;
+	    channel_id: string;
+	    content: string;
+	    edited: boolean;
+	    images: string[];
+	    mentions: string[];
+	    replies?: Reply;
+	    updated_at: string;
+	    created_at?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Message(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.author = this.convertValues(source["author"], User);
+	        this.channel_id = source["channel_id"];
+	        this.content = source["content"];
+	        this.edited = source["edited"];
+	        this.images = source["images"];
+	        this.mentions = source["mentions"];
+	        this.replies = this.convertValues(source["replies"], Reply);
+	        this.updated_at = source["updated_at"];
+	        this.created_at = source["created_at"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MessagesRequest {
+	    channel_id: string;
+	    user_id?: string;
+	    after?: string;
+	    before?: string;
+	    limit?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessagesRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.channel_id = source["channel_id"];
+	        this.user_id = source["user_id"];
+	        this.after = source["after"];
+	        this.before = source["before"];
+	        this.limit = source["limit"];
+	    }
+	}
+	export class MessagesResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    messages: Message[];
+	    has_more?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessagesResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.messages = this.convertValues(source["messages"], Message);
+	        this.has_more = source["has_more"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class NameColorRequest {
+	    user_id: string;
+	    username_color: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NameColorRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.username_color = source["username_color"];
+	    }
+	}
+	export class NewMessage {
+	    author: User;
+	    channel_id: string;
+	    content: string;
+	    mentions: string[];
+	    reply: string;
+	    private_message: boolean;
+	    server_id?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new NewMessage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.author = this.convertValues(source["author"], User);
+	        this.channel_id = source["channel_id"];
+	        this.content = source["content"];
+	        this.mentions = source["mentions"];
+	        this.reply = source["reply"];
+	        this.private_message = source["private_message"];
+	        this.server_id = source["server_id"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Notification {
+	    id: string;
+	    user_id: string;
+	    type: string;
+	    message?: string;
+	    created_at: string;
+	    initiator_id?: string;
+	    request_id?: string;
+	    channel_id?: string;
+	    counter?: number;
+	    mentions?: string[];
+	    server_id?: string;
+	    read?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Notification(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.user_id = source["user_id"];
+	        this.type = source["type"];
+	        this.message = source["message"];
+	        this.created_at = source["created_at"];
+	        this.initiator_id = source["initiator_id"];
+	        this.request_id = source["request_id"];
+	        this.channel_id = source["channel_id"];
+	        this.counter = source["counter"];
+	        this.mentions = source["mentions"];
+	        this.server_id = source["server_id"];
+	        this.read = source["read"];
+	    }
+	}
+	export class NotificationsResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    notifications: Notification[];
+	
+	    static createFrom(source: any = {}) {
+	        return new NotificationsResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.notifications = this.convertValues(source["notifications"], Notification);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProbeResult {
+	    proxy?: string;
+	    status?: number;
+	    latency_ms?: number;
+	    websocket_latency_ms?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbeResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxy = source["proxy"];
+	        this.status = source["status"];
+	        this.latency_ms = source["latency_ms"];
+	        this.websocket_latency_ms = source["websocket_latency_ms"];
+	    }
+	}
+	export class ProbeResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    probe?: ProbeResult;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProbeResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.probe = this.convertValues(source["probe"], ProbeResult);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class Result {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Result(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	    }
+	}
+	export class RoomTokenResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    token?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RoomTokenResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.token = source["token"];
+	    }
+	}
+	export class Span {
+	    start: number;
+	    end: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Span(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.start = source["start"];
+	        this.end = source["end"];
+	    }
+	}
+	export class SearchHit {
+	    conversation: string;
+	    message: Message;
+	    text: string;
+	    highlights: Span[];
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchHit(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.conversation = source["conversation"];
+	        this.message = this.convertValues(source["message"], Message);
+	        this.text = source["text"];
+	        this.highlights = this.convertValues(source["highlights"], Span);
+	        this.score = source["score"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SearchRequest {
+	    query: string;
+	    limit?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.query = source["query"];
+	        this.limit = source["limit"];
+	    }
+	}
+	export class SearchResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    hits: SearchHit[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.hits = this.convertValues(source["hits"], SearchHit);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Server {
+	    id: string;
+	    name: string;
+	    icon: string;
+	    banner: string;
+	    categories: Category[];
+	    roles?: string[];
+	    members: User[];
+	    created_at: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Server(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.icon = source["icon"];
+	        this.banner = source["banner"];
+	        this.categories = this.convertValues(source["categories"], Category);
+	        this.roles = source["roles"];
+	        this.members = this.convertValues(source["members"], User);
+	        this.created_at = source["created_at"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ServerRequest {
+	    user_id: string;
+	    server_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ServerRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.server_id = source["server_id"];
+	    }
+	}
+	export class ServerResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    server?: Server;
+	
+	    static createFrom(source: any = {}) {
+	        return new ServerResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.server = this.convertValues(source["server"], Server);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ServersResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    servers: Server[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ServersResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.servers = this.convertValues(source["servers"], Server);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SigninRequest {
+	    email: string;
+	    password: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SigninRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.email = source["email"];
+	        this.password = source["password"];
+	    }
+	}
+	
+	export class StatusRequest {
+	    user_id: string;
+	    status: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StatusRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.status = source["status"];
+	    }
+	}
+	export class SyncNotificationsRequest {
+	    user_id: string;
+	    channels: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new SyncNotificationsRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.channels = source["channels"];
+	    }
+	}
+	export class TypingRequest {
+	    user_id: string;
+	    channel_id: string;
+	    display_name: string;
+	    status: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TypingRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.channel_id = source["channel_id"];
+	        this.display_name = source["display_name"];
+	        this.status = source["status"];
+	    }
+	}
+	
+	export class UserRequest {
+	    user_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new UserRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	    }
+	}
+	export class UserResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    user?: User;
+	
+	    static createFrom(source: any = {}) {
+	        return new UserResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.user = this.convertValues(source["user"], User);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class UsernameRequest {
+	    user_id: string;
+	    username: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new UsernameRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.user_id = source["user_id"];
+	        this.username = source["username"];
+	    }
+	}
+
+}
+
+export namespace config {
+	
+	export class Preferences {
+	    close_to_tray: boolean;
+	    muted: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Preferences(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.close_to_tray = source["close_to_tray"];
+	        this.muted = source["muted"];
+	    }
+	}
+	export class Proxy {
+	    url?: string;
+	    bypass?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Proxy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.bypass = source["bypass"];
+	    }
+	}
+	export class TLS {
+	    ca_file?: string;
+	    pins?: string[];
+	    tofu?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new TLS(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ca_file = source["ca_file"];
+	        this.pins = source["pins"];
+	        this.tofu = source["tofu"];
+	    }
+	}
+	export class Profile {
+	    name: string;
+	    api_url: string;
+	    ws_url: string;
+	    media_url: string;
+	    tls: TLS;
+	    proxy: Proxy;
+	
+	    static createFrom(source: any = {}) {
+	        return new Profile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.api_url = source["api_url"];
+	        this.ws_url = source["ws_url"];
+	        this.media_url = source["media_url"];
+	        this.tls = this.convertValues(source["tls"], TLS);
+	        this.proxy = this.convertValues(source["proxy"], Proxy);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+
+}
+
+export namespace gateway {
+	
+	export class Participant {
+	    serverId: string;
+	    channelId: string;
+	    user?: client.User;
+	    user_id?: string;
+	    muted: boolean;
+	    deafen: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Participant(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.serverId = source["serverId"];
+	        this.channelId = source["channelId"];
+	        this.user = this.convertValues(source["user"], client.User);
+	        this.user_id = source["user_id"];
+	        this.muted = source["muted"];
+	        this.deafen = source["deafen"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ParticipantUpdate {
+	    type: string;
+	    content: Participant;
+	
+	    static createFrom(source: any = {}) {
+	        return new ParticipantUpdate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.content = this.convertValues(source["content"], Participant);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class StateChange {
+	    state: string;
+	    attempt: number;
+	    retry_in?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StateChange(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.attempt = source["attempt"];
+	        this.retry_in = source["retry_in"];
+	    }
+	}
+
+}
+
 export namespace main {
 	
-	export class File {
+	export class LogoutResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    warning?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogoutResponse(source);
+	    }
 	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.warning = source["warning"];
+	    }
+	}
+	export class MessageResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    metadata?: string[];
 	
 	    static createFrom(source: any = {}) {
-	        return new File(source);
+	        return new MessageResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.metadata = source["metadata"];
+	    }
+	}
+	export class PassphraseResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    needed: boolean;
+	    unlock: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new PassphraseResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.needed = source["needed"];
+	        this.unlock = source["unlock"];
+	    }
+	}
+	export class ProfileResponse {
+	    status?: number;
+	    code?: string;
+	    name?: string;
+	    fields?: {[key: string]: string};
+	    message: string;
+	    profile?: config.Profile;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProfileResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.fields = source["fields"];
+	        this.message = source["message"];
+	        this.profile = this.convertValues(source["profile"], config.Profile);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace media {
+	
+	export class Download {
+	    id: string;
+	    url: string;
+	    path: string;
+	    size: number;
+	    received: number;
+	    state: string;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Download(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.url = source["url"];
+	        this.path = source["path"];
+	        this.size = source["size"];
+	        this.received = source["received"];
+	        this.state = source["state"];
+	        this.error = source["error"];
+	    }
+	}
+
+}
+
+export namespace outbox {
+	
+	export class Entry {
+	    id: string;
+	    message: client.NewMessage;
+	    files: string[];
+	    state: string;
+	    attempts: number;
+	    error?: string;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Entry(source);
+	    }
 	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.message = this.convertValues(source["message"], client.NewMessage);
+	        this.files = source["files"];
+	        this.state = source["state"];
+	        this.attempts = source["attempts"];
+	        this.error = source["error"];
+	        this.created_at = this.convertValues(source["created_at"], null);
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
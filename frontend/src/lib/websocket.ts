@@ -11,21 +11,128 @@ import {
 	vcRoom,
 	usersTyping,
 	user,
-	messProto
+	gatewayState,
+	outbox,
+	uploads,
+	downloads
 } from './stores';
-import { decompress } from 'brotli-dec-wasm/web';
 import type { Notification } from './types';
+import { EventsOn } from '$lib/wailsjs/runtime/runtime';
+import { resync } from './fetches';
 
-export async function treatMessage(message: ArrayBuffer) {
-	const proto = get(messProto);
+const gatewayEvents = [
+	'text_message',
+	'edit_message',
+	'delete_message',
+	'friend_request',
+	'friend_accept',
+	'create_channel',
+	'delete_channel',
+	'create_category',
+	'delete_category',
+	'friend_remove',
+	'delete_server',
+	'join_server',
+	'leave_server',
+	'new_avatar',
+	'new_server_icon',
+	'change_status',
+	'typing',
+	'new_notification'
+];
 
-	const decompressed = decompress(new Uint8Array(message));
-	const decoded = proto.decode(decompressed);
-	const wsMessage = decoded.toJSON();
+const participantEvents = ['new_participant', 'quit_participant', 'participant_status'];
 
-	switch (wsMessage.type) {
+// listenGateway subscribes to the realtime events decoded by the Go gateway,
+// to the progress of the outbox, its uploads and the downloads, to the expiry of the session,
+// and to the actions taken from desktop notifications and the tray, and returns a function that
+// removes the listeners.
+export function listenGateway() {
+	const offs = [
+		...gatewayEvents.map((type) =>
+			EventsOn(`gateway:${type}`, (payload) => treatMessage(type, payload))
+		),
+		...participantEvents.map((type) =>
+			EventsOn(`gateway:${type}`, (payload) => treatParticipant(type, payload))
+		),
+		EventsOn('gateway:state', (state) => gatewayState.set(state)),
+		EventsOn('gateway:resync', () => resync()),
+		EventsOn('outbox:update', (entry) => {
+			outbox.update((cache) => {
+				if (entry.state === 'sent' || entry.state === 'cancelled') {
+					delete cache[entry.id];
+				} else {
+					cache[entry.id] = entry;
+				}
+				return cache;
+			});
+			if (entry.state !== 'sending') {
+				uploads.update((cache) => {
+					delete cache[entry.id];
+					return cache;
+				});
+			}
+		}),
+		EventsOn('upload:progress', (progress) =>
+			uploads.update((cache) => {
+				cache[progress.id] = progress;
+				return cache;
+			})
+		),
+		EventsOn('download:update', (download) =>
+			downloads.update((cache) => {
+				cache[download.id] = download;
+				return cache;
+			})
+		),
+		EventsOn('navigate', (path) => goto(path)),
+		EventsOn('server:joined', (server) =>
+			servers.update((servers) => {
+				servers[server.id] = server;
+				return servers;
+			})
+		),
+		EventsOn('session-expired', (expiry) => {
+			if (expiry.signin) {
+				goto('/signin');
+			}
+		}),
+		EventsOn('user:status', (status) =>
+			user.update((user) => {
+				if (user) {
+					user.status = status;
+				}
+				return user;
+			})
+		),
+		EventsOn('notification:read', (conversation) =>
+			notifications.update((cache) => {
+				for (const notif of cache) {
+					if (notif.channel_id?.split(':')[1] === conversation) {
+						notif.read = true;
+					}
+				}
+				return cache;
+			})
+		),
+		EventsOn('notification:accepted', (requestId, friend) => {
+			notifications.update((cache) => cache.filter((notif) => notif.request_id !== requestId));
+			if (friend) {
+				friends.update((friends) => {
+					friends.push(friend);
+					return friends;
+				});
+			}
+		})
+	];
+
+	return () => offs.forEach((off) => off());
+}
+
+function treatMessage(type: string, payload: any) {
+	switch (type) {
 		case 'text_message':
-			const newMessage = wsMessage.mess;
+			const newMessage = payload;
 			const pathname = window.location.pathname;
 			const ownId = get(user);
 			const channelId =
@@ -53,13 +160,13 @@ export async function treatMessage(message: ArrayBuffer) {
 			break;
 		case 'edit_message':
 			messages.update((cache) => {
-				const mess = cache[wsMessage.mess.author?.id || wsMessage.mess.channel_id]?.messages?.find(
-					(message) => message.id === wsMessage.mess.id
+				const mess = cache[payload.author?.id || payload.channel_id]?.messages?.find(
+					(message) => message.id === payload.id
 				);
 				if (mess) {
-					mess.content = wsMessage.mess.content;
-					mess.mentions = wsMessage.mess.mentions;
-					mess.edited = wsMessage.mess.edited;
+					mess.content = payload.content;
+					mess.mentions = payload.mentions;
+					mess.edited = payload.edited;
 				}
 				return cache;
 			});
@@ -68,10 +175,10 @@ export async function treatMessage(message: ArrayBuffer) {
 		case 'delete_message':
 			messages.update((cache) => {
 				const messIdx = cache[
-					wsMessage.mess.author?.id || wsMessage.mess.channel_id
-				]?.messages?.findIndex((message) => message.id === wsMessage.mess.id);
+					payload.author?.id || payload.channel_id
+				]?.messages?.findIndex((message) => message.id === payload.id);
 				if (messIdx > -1) {
-					cache[wsMessage.mess.author?.id || wsMessage.mess.channel_id].messages.splice(messIdx, 1);
+					cache[payload.author?.id || payload.channel_id].messages.splice(messIdx, 1);
 				}
 				return cache;
 			});
@@ -79,62 +186,62 @@ export async function treatMessage(message: ArrayBuffer) {
 			break;
 		case 'friend_request':
 			notifications.update((notifications) => {
-				notifications.unshift(wsMessage.friend_request);
+				notifications.unshift(payload);
 				return notifications;
 			});
 
 			break;
 		case 'friend_accept':
 			friends.update((friends) => {
-				friends.push(wsMessage.friend_accept);
+				friends.push(payload);
 				return friends;
 			});
 
 			break;
 		case 'create_channel':
 			servers.update((cache) => {
-				const server = cache[wsMessage.channel.server_id];
+				const server = cache[payload.server_id];
 				const category = server?.categories.find(
-					(category) => category.name === wsMessage.channel.category_name
+					(category) => category.name === payload.category_name
 				);
-				category?.channels.push(wsMessage.channel.channel);
+				category?.channels.push(payload.channel);
 				return cache;
 			});
 			break;
 		case 'delete_channel':
 			servers.update((cache) => {
-				const server = cache[wsMessage.delchannel.server_id];
+				const server = cache[payload.server_id];
 				let category = server?.categories.find(
-					(category) => category.name === wsMessage.delchannel.category_name
+					(category) => category.name === payload.category_name
 				);
 				const chanIdx = category?.channels.findIndex(
-					(channel) => channel.id === wsMessage.delchannel.channel_id
+					(channel) => channel.id === payload.channel_id
 				)!;
 				category?.channels.splice(chanIdx, 1);
 				return cache;
 			});
 
-			if (window.location.pathname.includes(wsMessage.delchannel.channel_id.split(':')[1])) {
+			if (window.location.pathname.includes(payload.channel_id.split(':')[1])) {
 				const serversInfos = get(servers);
 				const chanId =
-					serversInfos[wsMessage.delchannel.server_id].categories[0].channels[0].id.split(':')[1];
+					serversInfos[payload.server_id].categories[0].channels[0].id.split(':')[1];
 				goto(
-					`/hudori/chat/community/${wsMessage.delchannel.server_id.split(':')[1]}/channels/${chanId}`
+					`/hudori/chat/community/${payload.server_id.split(':')[1]}/channels/${chanId}`
 				);
 			}
 			break;
 		case 'create_category':
 			servers.update((cache) => {
-				const server = cache[wsMessage.create_category.server_id];
-				server?.categories.push({ name: wsMessage.create_category.category_name, channels: [] });
+				const server = cache[payload.server_id];
+				server?.categories.push({ name: payload.category_name, channels: [] });
 				return cache;
 			});
 			break;
 		case 'delete_category':
 			servers.update((cache) => {
-				const server = cache[wsMessage.delete_category.server_id];
+				const server = cache[payload.server_id];
 				let catIdx = server?.categories.findIndex(
-					(category) => category.name === wsMessage.delete_category.category_name
+					(category) => category.name === payload.category_name
 				)!;
 				server?.categories.splice(catIdx, 1);
 				return cache;
@@ -142,37 +249,37 @@ export async function treatMessage(message: ArrayBuffer) {
 			break;
 		case 'friend_remove':
 			friends.update((friends) => {
-				const newArr = friends.filter((friend) => friend.id !== wsMessage.user_id);
+				const newArr = friends.filter((friend) => friend.id !== payload.user_id);
 				return newArr;
 			});
-			if (window.location.pathname.includes(wsMessage.user_id.split(':')[1])) {
+			if (window.location.pathname.includes(payload.user_id.split(':')[1])) {
 				goto('/hudori/chat/friends');
 			}
 			break;
 		case 'delete_server':
 			servers.update((servers) => {
-				delete servers[wsMessage.server_id];
+				delete servers[payload.server_id];
 				return servers;
 			});
-			if (window.location.pathname.includes(wsMessage.server_id.split(':')[1])) {
+			if (window.location.pathname.includes(payload.server_id.split(':')[1])) {
 				goto('/hudori/chat/friends');
 			}
 			break;
 		case 'join_server':
-			if (window.location.pathname.includes(wsMessage.join_server.server_id.split(':')[1])) {
+			if (window.location.pathname.includes(payload.server_id.split(':')[1])) {
 				servers.update((cache) => {
-					const server = cache[wsMessage.serverId];
-					server?.members.push(wsMessage.join_server.user);
+					const server = cache[payload.server_id];
+					server?.members.push(payload.user);
 					return cache;
 				});
 			}
 			break;
 		case 'leave_server':
-			if (window.location.pathname.includes(wsMessage.quit_server.server_id.split(':')[1])) {
+			if (window.location.pathname.includes(payload.server_id.split(':')[1])) {
 				servers.update((cache) => {
-					const server = cache[wsMessage.quit_server.server_id];
+					const server = cache[payload.server_id];
 					const memberIdx = server?.members.findIndex(
-						(server) => server.id === wsMessage.quit_server.user_id
+						(server) => server.id === payload.user_id
 					)!;
 					server?.members.splice(memberIdx, 1);
 					return cache;
@@ -182,93 +289,91 @@ export async function treatMessage(message: ArrayBuffer) {
 		case 'new_avatar':
 			friends.update((friends) => {
 				const friend = friends.find(
-					(friend) => friend.id === 'users:' + wsMessage.change_avatar.user_id
+					(friend) => friend.id === 'users:' + payload.user_id
 				);
 				if (friend) {
-					friend.avatar = wsMessage.change_avatar.avatar;
+					friend.avatar = payload.avatar;
 				}
 				return friends;
 			});
 			break;
 		case 'new_server_icon':
 			servers.update((server) => {
-				const serverExist = server[wsMessage.server_pic.id];
+				const serverExist = server[payload.id];
 				if (serverExist) {
-					serverExist.icon = wsMessage.server_pic.picture;
+					serverExist.icon = payload.picture;
 				}
 				return server;
 			});
 			break;
 		case 'change_status':
 			friends.update((friends) => {
-				const friend = friends.find((friend) => friend.id === wsMessage.change_status.user_id);
+				const friend = friends.find((friend) => friend.id === payload.user_id);
 				if (friend) {
-					friend.status = wsMessage.change_status.status;
+					friend.status = payload.status;
 				}
 				return friends;
 			});
 			break;
 		case 'typing':
 			const userInfos = get(user);
-			if (userInfos.id === wsMessage.typing?.user_id) return;
+			if (userInfos.id === payload.user_id) return;
 			usersTyping.update((usersTyping) => {
-				if (wsMessage.typing?.status === 'start') {
-					const exist = usersTyping.find((user) => user.user_id === wsMessage.typing?.user_id);
+				if (payload.status === 'start') {
+					const exist = usersTyping.find((user) => user.user_id === payload.user_id);
 					if (!exist) {
 						usersTyping.push({
-							user_id: wsMessage.typing?.user_id,
-							display_name: wsMessage.typing?.display_name,
-							channel_id: wsMessage.typing?.channel_id
+							user_id: payload.user_id,
+							display_name: payload.display_name,
+							channel_id: payload.channel_id
 						});
 					}
-				} else if (wsMessage.typing?.status === 'end') {
-					return usersTyping.filter((user) => user.user_id !== wsMessage.typing?.user_id);
+				} else if (payload.status === 'end') {
+					return usersTyping.filter((user) => user.user_id !== payload.user_id);
 				}
 				return usersTyping;
 			});
 			break;
 		case 'new_notification':
-			manageNewNotification(wsMessage.notification);
+			manageNewNotification(payload);
 			break;
 		default:
 			break;
 	}
 }
 
-export function treatMessageJSON(message: any) {
-	const wsMessage = JSON.parse(message);
-
+function treatParticipant(type: string, content: any) {
 	const room = get(vcRoom);
-	switch (wsMessage.type) {
+	switch (type) {
 		case 'new_participant':
 			addParticipant(
-				wsMessage.content.serverId,
-				wsMessage.content.channelId,
-				wsMessage.content.user
+				content.serverId,
+				content.channelId,
+				content.user
 			);
-			if (room?.name === wsMessage.content.channelId) {
+			if (room?.name === content.channelId) {
 				const audio = document.getElementById('audio_join_channel') as HTMLMediaElement;
 				audio.play();
 			}
 			break;
 		case 'quit_participant':
 			removeParticipant(
-				wsMessage.content.serverId,
-				wsMessage.content.channelId,
-				wsMessage.content.user_id
+				content.serverId,
+				content.channelId,
+				content.user_id
 			);
-			if (room?.name === wsMessage.content.channelId) {
+			if (room?.name === content.channelId) {
 				const audio = document.getElementById('audio_quit_channel') as HTMLMediaElement;
 				audio.play();
 			}
 			break;
 		case 'participant_status':
 			updateParticipantStatus(
-				wsMessage.content.serverId,
-				wsMessage.content.channelId,
-				wsMessage.content.user_id,
-				wsMessage.content.muted,
-				wsMessage.content.deafen
+				content.serverId,
+				content.channelId,
+				content.user_id,
+				content.muted,
+				content.deafen
 			);
 			break;
 	}
@@ -18,8 +18,8 @@ export const load: LayoutServerLoad = async () => {
 		user.set(result.user);
 
 		const [friendsResponse, serversResponse] = await Promise.all([
-			GetFriends(JSON.stringify({ user_id: result.user?.id.split(':')[1] })),
-			GetServers(JSON.stringify({ user_id: result.user?.id.split(':')[1] }))
+			GetFriends({ user_id: result.user?.id.split(':')[1] }),
+			GetServers({ user_id: result.user?.id.split(':')[1] })
 		]);
 
 		if (!friendsResponse || !serversResponse) {
@@ -0,0 +1,210 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+
+	"github.com/andybalholm/brotli"
+	"google.golang.org/protobuf/proto"
+)
+
+// Decode turns a binary frame, a brotli-compressed hudori.WSMessage, into
+// an event. Message types this client does not know are returned with a
+// nil payload.
+func Decode(frame []byte) (Event, error) {
+	data, err := io.ReadAll(brotli.NewReader(bytes.NewReader(frame)))
+	if err != nil {
+		return Event{}, fmt.Errorf("error decompressing frame: %w", err)
+	}
+
+	var msg pb.WSMessage
+	err = proto.Unmarshal(data, &msg)
+	if err != nil {
+		return Event{}, fmt.Errorf("error decoding frame: %w", err)
+	}
+
+	return event(&msg), nil
+}
+
+// DecodeJSON turns a text frame into an event.
+func DecodeJSON(frame []byte) (Event, error) {
+	var update ParticipantUpdate
+	err := json.Unmarshal(frame, &update)
+	if err != nil {
+		return Event{}, fmt.Errorf("error decoding frame: %w", err)
+	}
+
+	switch update.Type {
+	case "new_participant", "quit_participant", "participant_status":
+		return Event{Name: update.Type, Payload: update.Content}, nil
+	}
+
+	return Event{Name: update.Type}, nil
+}
+
+func event(msg *pb.WSMessage) Event {
+	e := Event{Name: msg.GetType()}
+
+	switch e.Name {
+	case "text_message", "edit_message", "delete_message":
+		if m := msg.GetMess(); m != nil {
+			e.Payload = message(m)
+		}
+	case "friend_request":
+		if r := msg.GetFriendRequest(); r != nil {
+			e.Payload = client.Notification{
+				ID:          r.GetId(),
+				UserID:      r.GetUserId(),
+				Type:        r.GetType(),
+				Message:     r.GetMessage(),
+				CreatedAt:   r.GetCreatedAt(),
+				InitiatorID: r.GetInitiatorId(),
+				RequestID:   r.GetRequestId(),
+			}
+		}
+	case "friend_accept":
+		if u := msg.GetFriendAccept(); u != nil {
+			e.Payload = user(u)
+		}
+	case "friend_remove":
+		e.Payload = FriendRemoved{UserID: msg.GetUserId()}
+	case "create_channel":
+		if c := msg.GetChannel(); c != nil {
+			e.Payload = ChannelCreated{
+				ServerID:     c.GetServerId(),
+				CategoryName: c.GetCategoryName(),
+				Channel:      channel(c.GetChannel()),
+			}
+		}
+	case "delete_channel":
+		if c := msg.GetDelchannel(); c != nil {
+			e.Payload = ChannelDeleted{
+				ServerID:     c.GetServerId(),
+				ChannelID:    c.GetChannelId(),
+				CategoryName: c.GetCategoryName(),
+			}
+		}
+	case "create_category":
+		if c := msg.GetCreateCategory(); c != nil {
+			e.Payload = CategoryChanged{ServerID: c.GetServerId(), CategoryName: c.GetCategoryName()}
+		}
+	case "delete_category":
+		if c := msg.GetDeleteCategory(); c != nil {
+			e.Payload = CategoryChanged{ServerID: c.GetServerId(), CategoryName: c.GetCategoryName()}
+		}
+	case "delete_server":
+		e.Payload = ServerDeleted{ServerID: msg.GetServerId()}
+	case "join_server":
+		if j := msg.GetJoinServer(); j != nil {
+			e.Payload = MemberJoined{ServerID: j.GetServerId(), User: user(j.GetUser())}
+		}
+	case "leave_server":
+		if q := msg.GetQuitServer(); q != nil {
+			e.Payload = MemberLeft{ServerID: q.GetServerId(), UserID: q.GetUserId()}
+		}
+	case "new_avatar":
+		if a := msg.GetChangeAvatar(); a != nil {
+			e.Payload = AvatarChanged{UserID: a.GetUserId(), Avatar: a.GetAvatar()}
+		}
+	case "new_server_icon":
+		if p := msg.GetServerPic(); p != nil {
+			e.Payload = ServerIconChanged{ID: p.GetId(), Picture: p.GetPicture()}
+		}
+	case "change_status":
+		if s := msg.GetChangeStatus(); s != nil {
+			e.Payload = StatusChanged{UserID: s.GetUserId(), Status: s.GetStatus()}
+		}
+	case "typing":
+		if t := msg.GetTyping(); t != nil {
+			e.Payload = Typing{
+				DisplayName: t.GetDisplayName(),
+				ChannelID:   t.GetChannelId(),
+				UserID:      t.GetUserId(),
+				Status:      t.GetStatus(),
+			}
+		}
+	case "participant_move":
+		if p := msg.GetParticipantMove(); p != nil {
+			e.Payload = ParticipantMoved{
+				User:      user(p.GetUser()),
+				UserID:    p.GetUserId(),
+				ServerID:  p.GetServerId(),
+				ChannelID: p.GetChannelId(),
+				Deafen:    p.GetDeafen(),
+				Muted:     p.GetMuted(),
+			}
+		}
+	case "new_notification":
+		if n := msg.GetNotification(); n != nil {
+			e.Payload = client.Notification{
+				ID:        n.GetId(),
+				UserID:    n.GetUserId(),
+				Type:      n.GetType(),
+				CreatedAt: n.GetCreatedAt(),
+				ChannelID: n.GetChannelId(),
+				Counter:   int(n.GetCounter()),
+				Mentions:  n.GetMentions(),
+				ServerID:  n.GetServerId(),
+				Read:      n.GetRead(),
+			}
+		}
+	}
+
+	return e
+}
+
+func user(u *pb.User) client.User {
+	return client.User{
+		ID:            u.GetId(),
+		Email:         u.GetEmail(),
+		Username:      u.GetUsername(),
+		DisplayName:   u.GetDisplayName(),
+		UsernameColor: u.GetUsernameColor(),
+		Avatar:        u.GetAvatar(),
+		Banner:        u.GetBanner(),
+		Status:        u.GetStatus(),
+		AboutMe:       u.GetAboutMe(),
+		CreatedAt:     u.GetCreatedAt(),
+	}
+}
+
+func message(m *pb.Message) client.Message {
+	msg := client.Message{
+		ID:        m.GetId(),
+		Author:    user(m.GetAuthor()),
+		ChannelID: m.GetChannelId(),
+		Content:   m.GetContent(),
+		Edited:    m.GetEdited(),
+		Images:    m.GetImages(),
+		Mentions:  m.GetMentions(),
+		UpdatedAt: m.GetUpdatedAt(),
+		CreatedAt: m.GetCreatedAt(),
+	}
+	if r := m.GetReplies(); r != nil {
+		msg.Replies = &client.Reply{
+			ID:      r.GetId(),
+			Author:  user(r.GetAuthor()),
+			Content: r.GetContent(),
+		}
+	}
+	return msg
+}
+
+func channel(c *pb.Channel) client.Channel {
+	ch := client.Channel{
+		ID:        c.GetId(),
+		Name:      c.GetName(),
+		Type:      c.GetType(),
+		Private:   c.GetPrivate(),
+		CreatedAt: c.GetCreatedAt(),
+	}
+	for _, p := range c.GetParticipants() {
+		ch.Participants = append(ch.Participants, user(p))
+	}
+	return ch
+}
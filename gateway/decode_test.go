@@ -0,0 +1,199 @@
+package gateway
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"hudori-desktop/client"
+	"hudori-desktop/gateway/pb"
+
+	"github.com/andybalholm/brotli"
+	"google.golang.org/protobuf/proto"
+)
+
+// frame encodes msg as the server sends it.
+func frame(t *testing.T, msg *pb.WSMessage) []byte {
+	t.Helper()
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compress(t, data)
+}
+
+func compress(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := brotli.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	author := &pb.User{Id: "users:bob", Username: "bob", DisplayName: "Bob"}
+
+	tests := []struct {
+		name  string
+		frame []byte
+		want  Event
+	}{
+		{
+			name: "message",
+			frame: frame(t, &pb.WSMessage{
+				Type: "text_message",
+				Content: &pb.WSMessage_Mess{Mess: &pb.Message{
+					Id:        "messages:1",
+					Author:    author,
+					ChannelId: "channels:general",
+					Content:   "<p>hi</p>",
+					Replies:   &pb.Reply{Id: "messages:0", Author: author, Content: "<p>hello</p>"},
+				}},
+			}),
+			want: Event{Name: "text_message", Payload: client.Message{
+				ID:        "messages:1",
+				Author:    client.User{ID: "users:bob", Username: "bob", DisplayName: "Bob"},
+				ChannelID: "channels:general",
+				Content:   "<p>hi</p>",
+				Replies: &client.Reply{
+					ID:      "messages:0",
+					Author:  client.User{ID: "users:bob", Username: "bob", DisplayName: "Bob"},
+					Content: "<p>hello</p>",
+				},
+			}},
+		},
+		{
+			name: "friend removed",
+			frame: frame(t, &pb.WSMessage{
+				Type:    "friend_remove",
+				Content: &pb.WSMessage_UserId{UserId: "users:bob"},
+			}),
+			want: Event{Name: "friend_remove", Payload: FriendRemoved{UserID: "users:bob"}},
+		},
+		{
+			name: "member joined",
+			frame: frame(t, &pb.WSMessage{
+				Type:    "join_server",
+				Content: &pb.WSMessage_JoinServer{JoinServer: &pb.JoinServer{ServerId: "servers:1", User: author}},
+			}),
+			want: Event{Name: "join_server", Payload: MemberJoined{
+				ServerID: "servers:1",
+				User:     client.User{ID: "users:bob", Username: "bob", DisplayName: "Bob"},
+			}},
+		},
+		{
+			name: "typing",
+			frame: frame(t, &pb.WSMessage{
+				Type: "typing",
+				Content: &pb.WSMessage_Typing{Typing: &pb.Typing{
+					DisplayName: "Bob", ChannelId: "channels:general", UserId: "users:bob", Status: "start",
+				}},
+			}),
+			want: Event{Name: "typing", Payload: Typing{
+				DisplayName: "Bob", ChannelID: "channels:general", UserID: "users:bob", Status: "start",
+			}},
+		},
+		{
+			name:  "unknown type",
+			frame: frame(t, &pb.WSMessage{Type: "something_new"}),
+			want:  Event{Name: "something_new"},
+		},
+		{
+			name:  "message without content",
+			frame: frame(t, &pb.WSMessage{Type: "text_message"}),
+			want:  Event{Name: "text_message"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.frame)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "not compressed", frame: []byte("\x0b\x03plain text, not brotli")},
+		{name: "not a message", frame: compress(t, []byte{0xff, 0xff, 0xff})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.frame); err == nil {
+				t.Error("Decode succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   string
+		want    Event
+		wantErr bool
+	}{
+		{
+			name:  "new participant",
+			frame: `{"type":"new_participant","content":{"serverId":"servers:1","channelId":"channels:voice","user":{"id":"users:bob"}}}`,
+			want: Event{Name: "new_participant", Payload: Participant{
+				ServerID:  "servers:1",
+				ChannelID: "channels:voice",
+				User:      &client.User{ID: "users:bob"},
+			}},
+		},
+		{
+			name:  "participant status",
+			frame: `{"type":"participant_status","content":{"serverId":"servers:1","channelId":"channels:voice","user_id":"users:bob","muted":true}}`,
+			want: Event{Name: "participant_status", Payload: Participant{
+				ServerID:  "servers:1",
+				ChannelID: "channels:voice",
+				UserID:    "users:bob",
+				Muted:     true,
+			}},
+		},
+		{
+			name:  "unknown type",
+			frame: `{"type":"something_new","content":{}}`,
+			want:  Event{Name: "something_new"},
+		},
+		{
+			name:    "invalid",
+			frame:   `{"type":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeJSON([]byte(tt.frame))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("DecodeJSON succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeJSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeJSON = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"time"
+
+	"hudori-desktop/client"
+)
+
+// Event is one realtime update, ready to be handed to the frontend.
+// Name is the message type sent by the server, e.g. "text_message".
+type Event struct {
+	Name    string
+	Payload interface{}
+}
+
+// The states of the connection, sent with the state event.
+const (
+	// StateConnecting is reported while a connection is being made.
+	StateConnecting = "connecting"
+	// StateOnline is reported while connected.
+	StateOnline = "online"
+	// StateDegraded is reported when the connection dropped and the
+	// gateway is about to retry.
+	StateDegraded = "degraded"
+	// StateOffline is reported when several attempts in a row failed, or
+	// once the gateway has stopped.
+	StateOffline = "offline"
+)
+
+// StateChange is sent with state. RetryIn is the delay in milliseconds
+// before the next attempt, if one is planned.
+type StateChange struct {
+	State   string `json:"state"`
+	Attempt int    `json:"attempt"`
+	RetryIn int64  `json:"retry_in,omitempty"`
+}
+
+// Resync is sent with resync once the connection is back. Events sent by
+// the server since the connection was lost were missed, so the frontend
+// has to fetch its state again.
+type Resync struct {
+	Since time.Time `json:"since"`
+}
+
+// The payloads below are sent with the events of the same name. Messages,
+// users and notifications use the types of the client package so the
+// frontend sees the same shapes as from the REST API.
+
+// ChannelCreated is sent with create_channel.
+type ChannelCreated struct {
+	ServerID     string         `json:"server_id"`
+	CategoryName string         `json:"category_name"`
+	Channel      client.Channel `json:"channel"`
+}
+
+// ChannelDeleted is sent with delete_channel.
+type ChannelDeleted struct {
+	ServerID     string `json:"server_id"`
+	ChannelID    string `json:"channel_id"`
+	CategoryName string `json:"category_name"`
+}
+
+// CategoryChanged is sent with create_category and delete_category.
+type CategoryChanged struct {
+	ServerID     string `json:"server_id"`
+	CategoryName string `json:"category_name"`
+}
+
+// FriendRemoved is sent with friend_remove.
+type FriendRemoved struct {
+	UserID string `json:"user_id"`
+}
+
+// ServerDeleted is sent with delete_server.
+type ServerDeleted struct {
+	ServerID string `json:"server_id"`
+}
+
+// MemberJoined is sent with join_server.
+type MemberJoined struct {
+	ServerID string      `json:"server_id"`
+	User     client.User `json:"user"`
+}
+
+// MemberLeft is sent with leave_server.
+type MemberLeft struct {
+	ServerID string `json:"server_id"`
+	UserID   string `json:"user_id"`
+}
+
+// AvatarChanged is sent with new_avatar.
+type AvatarChanged struct {
+	UserID string `json:"user_id"`
+	Avatar string `json:"avatar"`
+}
+
+// ServerIconChanged is sent with new_server_icon.
+type ServerIconChanged struct {
+	ID      string `json:"id"`
+	Picture string `json:"picture"`
+}
+
+// StatusChanged is sent with change_status.
+type StatusChanged struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// Typing is sent with typing. Status is "start" or "end".
+type Typing struct {
+	DisplayName string `json:"display_name"`
+	ChannelID   string `json:"channel_id"`
+	UserID      string `json:"user_id"`
+	Status      string `json:"status"`
+}
+
+// ParticipantMoved is sent with participant_move.
+type ParticipantMoved struct {
+	User      client.User `json:"user"`
+	UserID    string      `json:"user_id"`
+	ServerID  string      `json:"server_id"`
+	ChannelID string      `json:"channel_id"`
+	Deafen    bool        `json:"deafen"`
+	Muted     bool        `json:"muted"`
+}
+
+// Participant is the content of the voice channel updates, which the
+// server relays as JSON text frames: new_participant, quit_participant
+// and participant_status. The same shape is used to send them.
+type Participant struct {
+	ServerID  string       `json:"serverId"`
+	ChannelID string       `json:"channelId"`
+	User      *client.User `json:"user,omitempty"`
+	UserID    string       `json:"user_id,omitempty"`
+	Muted     bool         `json:"muted"`
+	Deafen    bool         `json:"deafen"`
+}
+
+// ParticipantUpdate is a voice channel update as it travels on the wire.
+type ParticipantUpdate struct {
+	Type    string      `json:"type"`
+	Content Participant `json:"content"`
+}
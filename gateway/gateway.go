@@ -0,0 +1,309 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"hudori-desktop/client"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	heartbeat     = "heartbeat"
+	heartbeatRate = 10 * time.Second
+	writeTimeout  = 10 * time.Second
+	probeTimeout  = 20 * time.Second
+
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+
+	// offlineAfter is the number of failed attempts after which the
+	// connection is reported offline rather than degraded.
+	offlineAfter = 3
+)
+
+// Gateway owns the realtime connection of one account. Frames are decoded
+// in Go and handed to the emit function as events.
+type Gateway struct {
+	url       string
+	jar       http.CookieJar
+	transport *http.Transport
+	emit      func(Event)
+	logf      func(format string, args ...interface{})
+	// retryDelay returns how long to wait before a reconnection attempt.
+	retryDelay func(attempt int) time.Duration
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	state  StateChange
+	cancel context.CancelFunc
+	closed bool
+}
+
+// URL returns the websocket endpoint of a user on the server at wsURL.
+// User ids come as "users:<id>"; only the part after the colon is used.
+func URL(wsURL, userID string) string {
+	if _, id, ok := strings.Cut(userID, ":"); ok {
+		userID = id
+	}
+	return wsURL + "/ws/" + userID
+}
+
+// New returns a gateway for url that sends the cookies in jar. It connects
+// with the TLS configuration and through the proxy of transport, or the
+// defaults when it is nil. Every event read from the connection, as well
+// as the state and resync events of the gateway itself, is passed to emit;
+// problems that do not stop the gateway are reported through logf.
+func New(url string, jar http.CookieJar, transport *http.Transport, emit func(Event), logf func(format string, args ...interface{})) *Gateway {
+	if transport == nil {
+		transport = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	return &Gateway{
+		url:        url,
+		jar:        jar,
+		transport:  transport,
+		emit:       emit,
+		logf:       logf,
+		retryDelay: func(attempt int) time.Duration { return client.Backoff(attempt, minBackoff, maxBackoff) },
+		state:      StateChange{State: StateOffline},
+	}
+}
+
+// Run keeps the gateway connected until ctx is done or Close is called.
+// When the connection drops it reconnects with jittered exponential
+// backoff, and once it is back it emits a resync event so the frontend
+// can fetch what it missed in the meantime.
+func (g *Gateway) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return
+	}
+	g.cancel = cancel
+	g.mu.Unlock()
+
+	var lost time.Time
+	failures := 0
+	for {
+		g.setState(StateChange{State: StateConnecting, Attempt: failures})
+
+		conn, err := g.dial(ctx)
+		if err == nil {
+			failures = 0
+			g.setState(StateChange{State: StateOnline})
+			if !lost.IsZero() {
+				g.emit(Event{Name: "resync", Payload: Resync{Since: lost}})
+			}
+
+			err = g.serve(ctx, conn)
+			lost = time.Now()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		failures++
+		delay := g.retryDelay(failures)
+		g.logf("realtime connection lost, retrying in %s: %v", delay.Round(time.Millisecond), err)
+
+		state := StateDegraded
+		if failures > offlineAfter {
+			state = StateOffline
+		}
+		g.setState(StateChange{State: state, Attempt: failures, RetryIn: delay.Milliseconds()})
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	g.setState(StateChange{State: StateOffline})
+}
+
+// State returns the current state of the connection.
+func (g *Gateway) State() StateChange {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.state
+}
+
+func (g *Gateway) setState(change StateChange) {
+	g.mu.Lock()
+	g.state = change
+	g.mu.Unlock()
+
+	g.emit(Event{Name: "state", Payload: change})
+}
+
+// dialer returns a dialer with the TLS configuration and proxy of
+// transport, sending the cookies in jar.
+func dialer(jar http.CookieJar, transport *http.Transport) *websocket.Dialer {
+	return &websocket.Dialer{
+		Proxy:            transport.Proxy,
+		HandshakeTimeout: 45 * time.Second,
+		Jar:              jar,
+		TLSClientConfig:  transport.TLSClientConfig,
+	}
+}
+
+// Probe opens a websocket to url the way the gateway does, with the TLS
+// configuration and through the proxy of transport, and reports how long
+// the server took to answer. Any answer to the handshake means that it was
+// reached, even a refusal of a connection without a session.
+func Probe(ctx context.Context, url string, transport *http.Transport) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, resp, err := dialer(nil, transport).DialContext(ctx, url, nil)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if err != nil && resp == nil {
+		return 0, fmt.Errorf("error connecting to %s: %w", url, err)
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	return time.Since(start), nil
+}
+
+func (g *Gateway) dial(ctx context.Context) (*websocket.Conn, error) {
+	conn, _, err := dialer(g.jar, g.transport).DialContext(ctx, g.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", g.url, err)
+	}
+
+	g.mu.Lock()
+	g.conn = conn
+	g.mu.Unlock()
+
+	return conn, nil
+}
+
+// serve reads from conn until it fails or ctx is done.
+func (g *Gateway) serve(ctx context.Context, conn *websocket.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go g.heartbeat(ctx, conn)
+
+	err := g.read(ctx, conn)
+
+	g.mu.Lock()
+	if g.conn == conn {
+		g.conn = nil
+	}
+	g.mu.Unlock()
+
+	return err
+}
+
+// Close ends the connection for good.
+func (g *Gateway) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+	if g.cancel != nil {
+		g.cancel()
+	}
+}
+
+// Send writes v to the server as a JSON text frame.
+func (g *Gateway) Send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling frame: %w", err)
+	}
+	return g.write(websocket.TextMessage, data)
+}
+
+func (g *Gateway) write(messageType int, data []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.conn == nil {
+		return errors.New("not connected")
+	}
+
+	g.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	err := g.conn.WriteMessage(messageType, data)
+	if err != nil {
+		return fmt.Errorf("error writing frame: %w", err)
+	}
+
+	return nil
+}
+
+// heartbeat keeps the connection alive. A heartbeat that cannot be sent
+// means the connection is gone, so it is closed to make read return.
+func (g *Gateway) heartbeat(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(heartbeatRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := g.write(websocket.TextMessage, []byte(heartbeat))
+			if err != nil {
+				g.logf("could not send heartbeat: %v", err)
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (g *Gateway) read(ctx context.Context, conn *websocket.Conn) error {
+	for ctx.Err() == nil {
+		messageType, frame, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("error reading frame: %w", err)
+		}
+
+		var e Event
+		switch messageType {
+		case websocket.BinaryMessage:
+			e, err = Decode(frame)
+		case websocket.TextMessage:
+			if string(frame) == heartbeat {
+				continue
+			}
+			e, err = DecodeJSON(frame)
+		default:
+			continue
+		}
+		if err != nil {
+			g.logf("dropping frame: %v", err)
+			continue
+		}
+		if e.Payload == nil {
+			continue
+		}
+
+		g.emit(e)
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// recorder collects the events a gateway emits.
+type recorder chan Event
+
+func (r recorder) emit(e Event) {
+	r <- e
+}
+
+// next returns the next event, skipping those that are not state changes
+// unless they are named in also.
+func (r recorder) next(t *testing.T, also ...string) Event {
+	t.Helper()
+	for {
+		select {
+		case e := <-r:
+			if e.Name == "state" {
+				return e
+			}
+			for _, name := range also {
+				if e.Name == name {
+					return e
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event emitted")
+		}
+	}
+}
+
+// expect checks that the next events are the given state changes, or the
+// events named that way when they are not states.
+func (r recorder) expect(t *testing.T, want ...interface{}) {
+	t.Helper()
+	for _, w := range want {
+		switch w := w.(type) {
+		case StateChange:
+			e := r.next(t)
+			got, _ := e.Payload.(StateChange)
+			got.RetryIn = 0
+			if got != w {
+				t.Fatalf("state = %+v, want %+v", got, w)
+			}
+		case string:
+			e := r.next(t, w)
+			if e.Name != w {
+				t.Fatalf("event = %s %+v, want %s", e.Name, e.Payload, w)
+			}
+		}
+	}
+}
+
+// serve runs a websocket server calling handle with the number of each
+// connection attempt, from 0. The connection is refused when handle
+// returns false, and closed once it returns otherwise.
+func serve(t *testing.T, handle func(attempt int, conn *websocket.Conn) bool) string {
+	t.Helper()
+	var attempts atomic.Int32
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := int(attempts.Add(1)) - 1
+		if !handle(attempt, nil) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(attempt, conn)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func start(t *testing.T, url string) (*Gateway, recorder) {
+	t.Helper()
+	events := make(recorder, 100)
+	g := New(url, nil, nil, events.emit, func(string, ...interface{}) {})
+	g.retryDelay = func(int) time.Duration { return time.Millisecond }
+
+	done := make(chan struct{})
+	go func() {
+		g.Run(context.Background())
+		close(done)
+	}()
+	t.Cleanup(func() {
+		g.Close()
+		<-done
+	})
+	return g, events
+}
+
+func TestRunReconnects(t *testing.T) {
+	hold := make(chan struct{})
+	t.Cleanup(func() { close(hold) })
+	url := serve(t, func(attempt int, conn *websocket.Conn) bool {
+		if conn != nil && attempt > 0 {
+			// The second connection stays up.
+			select {
+			case <-hold:
+			case <-time.After(10 * time.Second):
+			}
+		}
+		// The first one is closed at once.
+		return true
+	})
+
+	g, events := start(t, url)
+	events.expect(t,
+		StateChange{State: StateConnecting},
+		StateChange{State: StateOnline},
+		StateChange{State: StateDegraded, Attempt: 1},
+		StateChange{State: StateConnecting, Attempt: 1},
+		StateChange{State: StateOnline},
+		"resync",
+	)
+
+	if got := g.State(); got.State != StateOnline {
+		t.Errorf("State = %+v, want online", got)
+	}
+
+	g.Close()
+	events.expect(t, StateChange{State: StateOffline})
+}
+
+func TestRunGoesOffline(t *testing.T) {
+	url := serve(t, func(int, *websocket.Conn) bool { return false })
+
+	_, events := start(t, url)
+	events.expect(t, StateChange{State: StateConnecting})
+	for attempt := 1; attempt <= offlineAfter; attempt++ {
+		events.expect(t,
+			StateChange{State: StateDegraded, Attempt: attempt},
+			StateChange{State: StateConnecting, Attempt: attempt},
+		)
+	}
+	events.expect(t, StateChange{State: StateOffline, Attempt: offlineAfter + 1})
+}
+
+func TestRunNoResyncOnFirstConnection(t *testing.T) {
+	hold := make(chan struct{})
+	t.Cleanup(func() { close(hold) })
+	url := serve(t, func(_ int, conn *websocket.Conn) bool {
+		if conn != nil {
+			<-hold
+		}
+		return true
+	})
+
+	g, events := start(t, url)
+	events.expect(t,
+		StateChange{State: StateConnecting},
+		StateChange{State: StateOnline},
+	)
+
+	g.Close()
+	e := events.next(t, "resync")
+	if e.Name == "resync" {
+		t.Fatal("resync emitted on the first connection")
+	}
+}
+
+// connectProxy runs an HTTP proxy tunnelling connections with CONNECT and
+// counts them.
+func connectProxy(t *testing.T) (*url.URL, *atomic.Int32) {
+	t.Helper()
+	var tunnels atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tunnels.Add(1)
+
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	return u, &tunnels
+}
+
+func TestProbe(t *testing.T) {
+	refused := serve(t, func(int, *websocket.Conn) bool { return false })
+	accepted := serve(t, func(int, *websocket.Conn) bool { return true })
+	proxy, tunnels := connectProxy(t)
+	transport := &http.Transport{Proxy: http.ProxyURL(proxy)}
+
+	for _, target := range []string{refused, accepted} {
+		if _, err := Probe(context.Background(), target, transport); err != nil {
+			t.Errorf("Probe(%s): %v", target, err)
+		}
+	}
+	if got := tunnels.Load(); got != 2 {
+		t.Errorf("%d connections went through the proxy, want 2", got)
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err := Probe(context.Background(), "ws"+strings.TrimPrefix(closed.URL, "http"), &http.Transport{})
+	if err == nil {
+		t.Error("Probe of a closed server succeeded")
+	}
+}
@@ -0,0 +1,5 @@
+// Package pb holds the Go types of the realtime protocol, generated from
+// message.proto.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative message.proto
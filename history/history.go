@@ -0,0 +1,459 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"hudori-desktop/client"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Every conversation has a bucket holding nested buckets: the messages
+// keyed by their position in time, an index from message id to that
+// position, a few flags, and the terms of each message for the search
+// index (see index.go).
+var (
+	messagesBucket = []byte("messages")
+	idsBucket      = []byte("ids")
+	metaBucket     = []byte("meta")
+
+	completeKey = []byte("complete")
+	syncedKey   = []byte("synced")
+)
+
+// Store keeps the messages of one account on disk, per conversation.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the store at path, creating it if needed.
+func Open(path string) (*Store, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating history directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening message history: %w", err)
+	}
+
+	err = reindex(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error indexing message history: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// create returns the bucket of a conversation, with its nested buckets,
+// creating them if needed.
+func create(tx *bolt.Tx, conversation string) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists([]byte(conversation))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range [][]byte{messagesBucket, idsBucket, metaBucket} {
+		_, err = b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Key returns the conversation a message belongs to, as the frontend
+// names it: the channel for server channels, the other user for private
+// conversations. userID is the id of the signed-in user.
+func Key(msg client.Message, userID string) string {
+	channel := shortID(msg.ChannelID)
+	if channel == shortID(userID) && msg.Author.ID != "" {
+		return shortID(msg.Author.ID)
+	}
+	return channel
+}
+
+func shortID(id string) string {
+	if _, short, ok := strings.Cut(id, ":"); ok {
+		return short
+	}
+	return id
+}
+
+// timestamp returns when a message was sent, or the zero time if it does
+// not say.
+func timestamp(msg client.Message) time.Time {
+	for _, value := range []string{msg.CreatedAt, msg.UpdatedAt} {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// position orders messages by creation time, then by id.
+func position(msg client.Message) []byte {
+	var nanos int64
+	if t := timestamp(msg); !t.IsZero() {
+		nanos = t.UnixNano()
+	}
+
+	key := make([]byte, 8, 8+len(msg.ID))
+	binary.BigEndian.PutUint64(key, uint64(nanos))
+	return append(key, msg.ID...)
+}
+
+// Put adds messages to a conversation, replacing those already stored.
+// It reports how many were not stored before.
+func (s *Store) Put(conversation string, msgs ...client.Message) (int, error) {
+	added := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := create(tx, conversation)
+		if err != nil {
+			return err
+		}
+		added, _, err = put(tx, b, conversation, msgs)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error storing messages: %w", err)
+	}
+
+	return added, nil
+}
+
+// Sync adds messages fetched from the server to a conversation, like Put,
+// and moves the cursor returned by Synced to the newest of them. The
+// messages must be all those newer than the cursor, or the newest page of
+// the conversation when it has none yet; that first page replaces what
+// was stored before it, as messages received live may have missed some.
+func (s *Store) Sync(conversation string, msgs ...client.Message) (int, error) {
+	added := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := create(tx, conversation)
+		if err != nil {
+			return err
+		}
+		meta := b.Bucket(metaBucket)
+
+		var positions [][]byte
+		added, positions, err = put(tx, b, conversation, msgs)
+		if err != nil || len(positions) == 0 {
+			return err
+		}
+		oldest := slices.MinFunc(positions, bytes.Compare)
+		newest := slices.MaxFunc(positions, bytes.Compare)
+
+		synced := meta.Get(syncedKey)
+		if synced == nil {
+			err = prune(tx, b, conversation, oldest)
+			if err != nil {
+				return err
+			}
+		} else if pos := b.Bucket(idsBucket).Get(synced); pos != nil && bytes.Compare(pos, newest) > 0 {
+			return nil
+		}
+		return meta.Put(syncedKey, newest[8:])
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error storing messages: %w", err)
+	}
+
+	return added, nil
+}
+
+// Synced returns the id of the newest message of a conversation fetched
+// from the server: the messages after it have to be fetched, whatever was
+// received live meanwhile.
+func (s *Store) Synced(conversation string) (string, bool) {
+	var id string
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(conversation))
+		if b == nil {
+			return nil
+		}
+		id = string(b.Bucket(metaBucket).Get(syncedKey))
+		return nil
+	})
+	return id, id != ""
+}
+
+// prune removes the messages stored before the position pos. The history
+// is no longer complete if any was.
+func prune(tx *bolt.Tx, b *bolt.Bucket, conversation string, pos []byte) error {
+	messages, ids := b.Bucket(messagesBucket), b.Bucket(idsBucket)
+
+	var stale [][]byte
+	c := messages.Cursor()
+	for k, _ := c.First(); k != nil && bytes.Compare(k, pos) < 0; k, _ = c.Next() {
+		stale = append(stale, bytes.Clone(k))
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	for _, k := range stale {
+		id := string(k[8:])
+		err := messages.Delete(k)
+		if err != nil {
+			return err
+		}
+		err = unindex(tx, b, conversation, id)
+		if err != nil {
+			return err
+		}
+		err = ids.Delete([]byte(id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.Bucket(metaBucket).Delete(completeKey)
+}
+
+// put stores msgs in the conversation bucket b, and returns how many were
+// not stored before and the position of each.
+func put(tx *bolt.Tx, b *bolt.Bucket, conversation string, msgs []client.Message) (int, [][]byte, error) {
+	added := 0
+	var positions [][]byte
+	messages, ids := b.Bucket(messagesBucket), b.Bucket(idsBucket)
+
+	for _, msg := range msgs {
+		if msg.ID == "" {
+			continue
+		}
+
+		pos := ids.Get([]byte(msg.ID))
+		if pos == nil {
+			pos = position(msg)
+			added++
+		} else {
+			// Edits do not always carry the original dates, so the
+			// message keeps its place.
+			pos = bytes.Clone(pos)
+			old := messages.Get(pos)
+			if old != nil {
+				var stored client.Message
+				if json.Unmarshal(old, &stored) == nil {
+					msg = merge(stored, msg)
+				}
+			}
+		}
+
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return 0, nil, err
+		}
+		err = messages.Put(pos, data)
+		if err != nil {
+			return 0, nil, err
+		}
+		err = ids.Put([]byte(msg.ID), pos)
+		if err != nil {
+			return 0, nil, err
+		}
+		err = index(tx, b, conversation, msg)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// Private conversations are keyed by the other user, so their
+		// messages tell how to call them in searches.
+		if shortID(msg.Author.ID) == conversation && msg.Author.Username != "" {
+			err = b.Bucket(metaBucket).Put(nameKey, []byte(msg.Author.Username))
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+		positions = append(positions, pos)
+	}
+
+	return added, positions, nil
+}
+
+// merge applies an update to a stored message, keeping what the update
+// does not carry.
+func merge(stored, update client.Message) client.Message {
+	if update.Author.ID == "" {
+		update.Author = stored.Author
+	}
+	if update.CreatedAt == "" {
+		update.CreatedAt = stored.CreatedAt
+	}
+	if update.UpdatedAt == "" {
+		update.UpdatedAt = stored.UpdatedAt
+	}
+	if update.Images == nil {
+		update.Images = stored.Images
+	}
+	if update.Replies == nil {
+		update.Replies = stored.Replies
+	}
+	return update
+}
+
+// Delete removes a message from a conversation.
+func (s *Store) Delete(conversation, id string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(conversation))
+		if b == nil {
+			return nil
+		}
+		ids := b.Bucket(idsBucket)
+		pos := ids.Get([]byte(id))
+		if pos == nil {
+			return nil
+		}
+
+		err := b.Bucket(messagesBucket).Delete(pos)
+		if err != nil {
+			return err
+		}
+		err = unindex(tx, b, conversation, id)
+		if err != nil {
+			return err
+		}
+		return ids.Delete([]byte(id))
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting message: %w", err)
+	}
+
+	return nil
+}
+
+// Latest returns up to limit of the newest messages of a conversation,
+// oldest first, and whether older ones are stored.
+func (s *Store) Latest(conversation string, limit int) ([]client.Message, bool, error) {
+	return s.page(conversation, nil, limit)
+}
+
+// Before returns up to limit of the messages stored before the message
+// with id, oldest first, and whether even older ones are stored.
+func (s *Store) Before(conversation, id string, limit int) ([]client.Message, bool, error) {
+	var pos []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(conversation))
+		if b == nil {
+			return nil
+		}
+		pos = bytes.Clone(b.Bucket(idsBucket).Get([]byte(id)))
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading message history: %w", err)
+	}
+	if pos == nil {
+		return nil, false, fmt.Errorf("unknown message %q", id)
+	}
+
+	return s.page(conversation, pos, limit)
+}
+
+// page walks back from the message at pos, or from the newest one when
+// pos is nil.
+func (s *Store) page(conversation string, pos []byte, limit int) ([]client.Message, bool, error) {
+	var msgs []client.Message
+	more := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(conversation))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Bucket(messagesBucket).Cursor()
+		var k, v []byte
+		if pos == nil {
+			k, v = c.Last()
+		} else {
+			c.Seek(pos)
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && len(msgs) < limit; k, v = c.Prev() {
+			var msg client.Message
+			err := json.Unmarshal(v, &msg)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		more = k != nil
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading message history: %w", err)
+	}
+
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+
+	return msgs, more, nil
+}
+
+// Complete reports whether the whole history of a conversation, back to
+// its first message, is stored.
+func (s *Store) Complete(conversation string) bool {
+	complete := false
+	s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(conversation))
+		if b == nil {
+			return nil
+		}
+		complete = b.Bucket(metaBucket).Get(completeKey) != nil
+		return nil
+	})
+	return complete
+}
+
+// SetComplete records that the whole history of a conversation is stored.
+func (s *Store) SetComplete(conversation string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := create(tx, conversation)
+		if err != nil {
+			return err
+		}
+		return b.Bucket(metaBucket).Put(completeKey, []byte{1})
+	})
+	if err != nil {
+		return fmt.Errorf("error updating message history: %w", err)
+	}
+
+	return nil
+}
+
+// SetName records the name of a conversation, e.g. the name of a channel,
+// so that searches can be limited to it by name.
+func (s *Store) SetName(conversation, name string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := create(tx, conversation)
+		if err != nil {
+			return err
+		}
+		return b.Bucket(metaBucket).Put(nameKey, []byte(name))
+	})
+	if err != nil {
+		return fmt.Errorf("error updating message history: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hudori-desktop/client"
+)
+
+func open(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// messages returns messages n to m, a minute apart.
+func messages(n, m int) []client.Message {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var msgs []client.Message
+	for i := n; i <= m; i++ {
+		msgs = append(msgs, client.Message{
+			ID:        fmt.Sprintf("messages:%02d", i),
+			Content:   fmt.Sprintf("<p>message %d</p>", i),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute).Format(time.RFC3339),
+		})
+	}
+	return msgs
+}
+
+func ids(msgs []client.Message) []string {
+	var ids []string
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestSyncedIgnoresLiveMessages(t *testing.T) {
+	s := open(t)
+
+	if _, err := s.Sync("general", messages(1, 3)...); err != nil {
+		t.Fatal(err)
+	}
+	// Messages 4 and 5 were missed while offline, 6 came live after.
+	if _, err := s.Put("general", messages(6, 6)...); err != nil {
+		t.Fatal(err)
+	}
+
+	synced, _ := s.Synced("general")
+	if synced != "messages:03" {
+		t.Fatalf("Synced = %q, want messages:03", synced)
+	}
+
+	if _, err := s.Sync("general", messages(4, 6)...); err != nil {
+		t.Fatal(err)
+	}
+	synced, _ = s.Synced("general")
+	if synced != "messages:06" {
+		t.Errorf("Synced = %q, want messages:06", synced)
+	}
+
+	msgs, _, err := s.Latest("general", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(ids(msgs)), fmt.Sprint(ids(messages(1, 6))); got != want {
+		t.Errorf("Latest = %s, want %s", got, want)
+	}
+}
+
+func TestSyncNeverMovesBack(t *testing.T) {
+	s := open(t)
+
+	if _, err := s.Sync("general", messages(1, 5)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sync("general", messages(2, 3)...); err != nil {
+		t.Fatal(err)
+	}
+
+	synced, _ := s.Synced("general")
+	if synced != "messages:05" {
+		t.Errorf("Synced = %q, want messages:05", synced)
+	}
+}
+
+func TestFirstSyncDropsOlderLiveMessages(t *testing.T) {
+	s := open(t)
+
+	// Messages received live before the conversation was ever opened, with
+	// a gap between them.
+	if _, err := s.Put("general", messages(1, 2)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("general", messages(5, 5)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetComplete("general"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The newest page.
+	if _, err := s.Sync("general", messages(4, 6)...); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, more, err := s.Latest("general", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(ids(msgs)), fmt.Sprint(ids(messages(4, 6))); got != want {
+		t.Errorf("Latest = %s, want %s", got, want)
+	}
+	if more {
+		t.Error("Latest reports older messages")
+	}
+	if s.Complete("general") {
+		t.Error("history still complete after dropping messages")
+	}
+
+	hits, _, err := s.Search(Query{Text: "message"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 3 {
+		t.Errorf("Search found %d messages, want 3", len(hits))
+	}
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"path"
+	"strings"
+
+	"hudori-desktop/client"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The search index lives next to the conversations, in buckets whose
+// names cannot be conversation ids. Its keys are a term, a conversation
+// and a message id separated by zero bytes, and its values count how often
+// the term appears in each field of the message. The terms of every
+// message are also kept in its conversation, to remove them on update.
+var (
+	indexBucket  = []byte("\x00index")
+	configBucket = []byte("\x00config")
+	termsBucket  = []byte("terms")
+
+	versionKey = []byte("index_version")
+	nameKey    = []byte("name")
+)
+
+// indexVersion is bumped whenever the way messages are indexed changes, so
+// that stores written by an older version are indexed again.
+const indexVersion = 1
+
+// The fields a term can be found in.
+const (
+	fieldContent = iota
+	fieldAuthor
+	fieldFiles
+	fieldCount
+)
+
+// conversations calls fn with every conversation bucket of tx.
+func conversations(tx *bolt.Tx, fn func(conversation string, b *bolt.Bucket) error) error {
+	return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if len(name) > 0 && name[0] == 0 {
+			return nil
+		}
+		return fn(string(name), b)
+	})
+}
+
+// reindex builds the index again if it is missing or out of date.
+func reindex(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		config, err := tx.CreateBucketIfNotExists(configBucket)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(config.Get(versionKey), []byte{indexVersion}) {
+			return nil
+		}
+
+		if tx.Bucket(indexBucket) != nil {
+			err = tx.DeleteBucket(indexBucket)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = conversations(tx, func(conversation string, b *bolt.Bucket) error {
+			if b.Bucket(termsBucket) != nil {
+				err := b.DeleteBucket(termsBucket)
+				if err != nil {
+					return err
+				}
+			}
+			if b.Bucket(messagesBucket) == nil {
+				return nil
+			}
+
+			return b.Bucket(messagesBucket).ForEach(func(_, v []byte) error {
+				var msg client.Message
+				if json.Unmarshal(v, &msg) != nil {
+					return nil
+				}
+				return index(tx, b, conversation, msg)
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return config.Put(versionKey, []byte{indexVersion})
+	})
+}
+
+func postingKey(term, conversation, id string) []byte {
+	return []byte(term + "\x00" + conversation + "\x00" + id)
+}
+
+// index adds the terms of msg to the index, replacing those it had.
+func index(tx *bolt.Tx, b *bolt.Bucket, conversation string, msg client.Message) error {
+	err := unindex(tx, b, conversation, msg.ID)
+	if err != nil {
+		return err
+	}
+
+	idx, err := tx.CreateBucketIfNotExists(indexBucket)
+	if err != nil {
+		return err
+	}
+	known, err := b.CreateBucketIfNotExists(termsBucket)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]*[fieldCount]byte{}
+	add := func(field int, text string) {
+		for term, n := range terms(text) {
+			if counts[term] == nil {
+				counts[term] = &[fieldCount]byte{}
+			}
+			counts[term][field] = byte(min(int(counts[term][field])+n, 255))
+		}
+	}
+	add(fieldContent, Text(msg.Content))
+	add(fieldAuthor, msg.Author.Username+" "+msg.Author.DisplayName)
+	for _, file := range msg.Images {
+		add(fieldFiles, fileName(file))
+	}
+
+	list := make([]string, 0, len(counts))
+	for term, fields := range counts {
+		err = idx.Put(postingKey(term, conversation, msg.ID), fields[:])
+		if err != nil {
+			return err
+		}
+		list = append(list, term)
+	}
+
+	return known.Put([]byte(msg.ID), []byte(strings.Join(list, "\x00")))
+}
+
+// unindex removes the terms of a message from the index.
+func unindex(tx *bolt.Tx, b *bolt.Bucket, conversation, id string) error {
+	known := b.Bucket(termsBucket)
+	idx := tx.Bucket(indexBucket)
+	if known == nil || idx == nil {
+		return nil
+	}
+
+	list := known.Get([]byte(id))
+	if list == nil {
+		return nil
+	}
+	for _, term := range strings.Split(string(list), "\x00") {
+		err := idx.Delete(postingKey(term, conversation, id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return known.Delete([]byte(id))
+}
+
+// fileName returns the name of an attachment from its URL.
+func fileName(file string) string {
+	if u, err := url.Parse(file); err == nil {
+		file = u.Path
+	}
+	return path.Base(file)
+}
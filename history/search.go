@@ -0,0 +1,424 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"hudori-desktop/client"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultLimit = 25
+	maxLimit     = 200
+
+	// prefixWeight scales the score of a term that only starts with a
+	// word of the query, so that exact matches rank first.
+	prefixWeight = 0.5
+)
+
+// fieldWeights tells how much a match counts in each field.
+var fieldWeights = [fieldCount]float64{
+	fieldContent: 1,
+	fieldAuthor:  0.6,
+	fieldFiles:   0.8,
+}
+
+// Query is a parsed search. Messages match when they contain every word of
+// Text, as a word or the start of one, and pass every filter.
+type Query struct {
+	Text string
+	// From holds users, by username, display name or id.
+	From []string
+	// In holds conversations, by name or id.
+	In []string
+	// Has holds kinds of content: image, file, link or mention.
+	Has    []string
+	Before time.Time
+	After  time.Time
+	Limit  int
+}
+
+// filters are the kinds of content has: accepts.
+var filters = map[string]func(client.Message) bool{
+	"image": func(msg client.Message) bool {
+		for _, file := range msg.Images {
+			switch strings.ToLower(path.Ext(fileName(file))) {
+			case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif":
+				return true
+			}
+		}
+		return false
+	},
+	"file": func(msg client.Message) bool {
+		return len(msg.Images) > 0
+	},
+	"link": func(msg client.Message) bool {
+		return strings.Contains(msg.Content, "http://") || strings.Contains(msg.Content, "https://")
+	},
+	"mention": func(msg client.Message) bool {
+		return len(msg.Mentions) > 0
+	},
+}
+
+// ParseQuery reads a search as typed by the user: words, and filters of
+// the form from:user, in:channel, has:image, before:2024-05-01 and
+// after:2024-05-01. Values with spaces can be quoted, as in
+// from:"Jane Doe".
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	var text []string
+
+	for _, field := range splitQuery(s) {
+		op, value, ok := strings.Cut(field, ":")
+		value = strings.Trim(value, `"`)
+		if !ok || value == "" {
+			text = append(text, field)
+			continue
+		}
+
+		switch strings.ToLower(op) {
+		case "from":
+			q.From = append(q.From, strings.TrimPrefix(value, "@"))
+		case "in":
+			q.In = append(q.In, strings.TrimLeft(value, "#@"))
+		case "has":
+			value = strings.ToLower(value)
+			if filters[value] == nil {
+				return Query{}, fmt.Errorf("unknown filter has:%s", value)
+			}
+			q.Has = append(q.Has, value)
+		case "before":
+			t, err := parseDate(value)
+			if err != nil {
+				return Query{}, err
+			}
+			q.Before = t
+		case "after":
+			t, err := parseDate(value)
+			if err != nil {
+				return Query{}, err
+			}
+			// A day is after another once it is over.
+			if len(value) == len(time.DateOnly) {
+				t = t.AddDate(0, 0, 1)
+			}
+			q.After = t
+		default:
+			text = append(text, field)
+		}
+	}
+
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// splitQuery splits s on spaces that are not quoted.
+func splitQuery(s string) []string {
+	var fields []string
+	var b strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case r == ' ' && !quoted:
+			if b.Len() > 0 {
+				fields = append(fields, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		fields = append(fields, b.String())
+	}
+
+	return fields
+}
+
+func parseDate(value string) (time.Time, error) {
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+}
+
+// candidate is a message matching the words of a query so far.
+type candidate struct {
+	conversation string
+	id           string
+	score        float64
+	matched      int
+}
+
+// Search returns the messages matching q, best first, and how many
+// matched in total. Without words, messages passing the filters are
+// returned newest first.
+func (s *Store) Search(q Query) ([]client.SearchHit, int, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	limit = min(limit, maxLimit)
+
+	var tokens []string
+	seen := map[string]bool{}
+	for _, w := range words(q.Text) {
+		if !seen[w.term] {
+			seen[w.term] = true
+			tokens = append(tokens, w.term)
+		}
+	}
+	if len(tokens) == 0 && len(q.From) == 0 && len(q.In) == 0 && len(q.Has) == 0 && q.Before.IsZero() && q.After.IsZero() {
+		return nil, 0, nil
+	}
+
+	var hits []client.SearchHit
+	err := s.db.View(func(tx *bolt.Tx) error {
+		allowed := map[string]bool{}
+		total := 0
+		err := conversations(tx, func(conversation string, b *bolt.Bucket) error {
+			if ids := b.Bucket(idsBucket); ids != nil {
+				total += ids.Stats().KeyN
+			}
+			if inConversation(q.In, conversation, b) {
+				allowed[conversation] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		var found []*candidate
+		if len(tokens) == 0 {
+			found = all(tx, allowed)
+		} else {
+			found = match(tx, tokens, allowed, total)
+		}
+
+		for _, c := range found {
+			msg, ok := load(tx, c.conversation, c.id)
+			if !ok || !q.passes(msg) {
+				continue
+			}
+			hits = append(hits, client.SearchHit{
+				Conversation: c.conversation,
+				Message:      msg,
+				Score:        c.score,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("error searching messages: %w", err)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return timestamp(hits[i].Message).After(timestamp(hits[j].Message))
+	})
+
+	total := len(hits)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Text = Text(hits[i].Message.Content)
+		hits[i].Highlights = highlights(hits[i].Text, tokens)
+	}
+
+	return hits, total, nil
+}
+
+// match scores the messages of the allowed conversations containing every
+// token, with a BM25-like weighting: rare terms count more than common
+// ones, and repeating a term has diminishing returns.
+func match(tx *bolt.Tx, tokens []string, allowed map[string]bool, total int) []*candidate {
+	idx := tx.Bucket(indexBucket)
+	if idx == nil {
+		return nil
+	}
+
+	found := map[string]*candidate{}
+	for i, token := range tokens {
+		docs := map[string]float64{}
+
+		c := idx.Cursor()
+		prefix := []byte(token)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			parts := strings.SplitN(string(k), "\x00", 3)
+			if len(parts) != 3 || !allowed[parts[1]] {
+				continue
+			}
+
+			weight := 0.0
+			for field, n := range v {
+				if field < fieldCount && n > 0 {
+					weight += fieldWeights[field] * float64(n) / (float64(n) + 1.2)
+				}
+			}
+			if parts[0] != token {
+				weight *= prefixWeight
+			}
+
+			// A token can start several terms of one message; the best
+			// of them counts.
+			doc := parts[1] + "\x00" + parts[2]
+			docs[doc] = max(docs[doc], weight)
+		}
+
+		df := float64(len(docs))
+		idf := math.Log(1 + (float64(total)-df+0.5)/(df+0.5))
+		for doc, weight := range docs {
+			cand := found[doc]
+			if cand == nil {
+				// Only messages matching every token so far can match.
+				if i > 0 {
+					continue
+				}
+				conversation, id, _ := strings.Cut(doc, "\x00")
+				cand = &candidate{conversation: conversation, id: id}
+				found[doc] = cand
+			}
+			if cand.matched == i {
+				cand.score += idf * weight
+				cand.matched++
+			}
+		}
+	}
+
+	var list []*candidate
+	for _, cand := range found {
+		if cand.matched == len(tokens) {
+			list = append(list, cand)
+		}
+	}
+	return list
+}
+
+// all lists every message of the allowed conversations.
+func all(tx *bolt.Tx, allowed map[string]bool) []*candidate {
+	var list []*candidate
+	for conversation := range allowed {
+		ids := tx.Bucket([]byte(conversation)).Bucket(idsBucket)
+		if ids == nil {
+			continue
+		}
+		ids.ForEach(func(k, _ []byte) error {
+			list = append(list, &candidate{conversation: conversation, id: string(k)})
+			return nil
+		})
+	}
+	return list
+}
+
+func load(tx *bolt.Tx, conversation, id string) (client.Message, bool) {
+	var msg client.Message
+
+	b := tx.Bucket([]byte(conversation))
+	if b == nil || b.Bucket(idsBucket) == nil {
+		return msg, false
+	}
+	pos := b.Bucket(idsBucket).Get([]byte(id))
+	if pos == nil {
+		return msg, false
+	}
+	data := b.Bucket(messagesBucket).Get(pos)
+	if data == nil || json.Unmarshal(data, &msg) != nil {
+		return msg, false
+	}
+
+	return msg, true
+}
+
+// inConversation reports whether a conversation is one of in, or whether
+// in is empty.
+func inConversation(in []string, conversation string, b *bolt.Bucket) bool {
+	if len(in) == 0 {
+		return true
+	}
+
+	var name string
+	if meta := b.Bucket(metaBucket); meta != nil {
+		name = fold(string(meta.Get(nameKey)))
+	}
+	for _, value := range in {
+		if shortID(value) == conversation || (name != "" && fold(value) == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// passes reports whether msg passes the filters of q, but in:, which is
+// applied to whole conversations.
+func (q Query) passes(msg client.Message) bool {
+	if len(q.From) > 0 {
+		ok := false
+		for _, from := range q.From {
+			folded := fold(from)
+			if folded == fold(msg.Author.Username) || folded == fold(msg.Author.DisplayName) ||
+				shortID(from) == shortID(msg.Author.ID) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	for _, has := range q.Has {
+		if !filters[has](msg) {
+			return false
+		}
+	}
+
+	if !q.Before.IsZero() || !q.After.IsZero() {
+		t := timestamp(msg)
+		if t.IsZero() {
+			return false
+		}
+		if !q.Before.IsZero() && !t.Before(q.Before) {
+			return false
+		}
+		if !q.After.IsZero() && t.Before(q.After) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// highlights returns the words of text that start with one of tokens.
+func highlights(text string, tokens []string) []client.Span {
+	spans := []client.Span{}
+	for _, w := range words(text) {
+		for _, token := range tokens {
+			if strings.HasPrefix(w.term, token) {
+				spans = append(spans, client.Span{Start: w.start, End: w.end})
+				break
+			}
+		}
+	}
+	return spans
+}
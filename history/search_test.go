@@ -0,0 +1,311 @@
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"hudori-desktop/client"
+)
+
+func TestParseQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		t, _ := time.ParseInLocation(time.DateOnly, s, time.Local)
+		return t
+	}
+
+	tests := []struct {
+		query string
+		want  Query
+	}{
+		{query: "hello world", want: Query{Text: "hello world"}},
+		{query: "  spaced   out  ", want: Query{Text: "spaced out"}},
+		{query: "from:alice hi", want: Query{Text: "hi", From: []string{"alice"}}},
+		{query: "from:@alice from:users:bob", want: Query{From: []string{"alice", "users:bob"}}},
+		{query: `from:"Jane Doe" hi`, want: Query{Text: "hi", From: []string{"Jane Doe"}}},
+		{query: "in:#general in:@bob in:channels:x", want: Query{In: []string{"general", "bob", "channels:x"}}},
+		{query: "has:IMAGE has:link", want: Query{Has: []string{"image", "link"}}},
+		{query: "FROM:alice", want: Query{From: []string{"alice"}}},
+		{query: "before:2024-05-01", want: Query{Before: day("2024-05-01")}},
+		// After a day means from the next one on.
+		{query: "after:2024-05-01", want: Query{After: day("2024-05-02")}},
+		{query: "after:2024-05-01T10:00:00Z", want: Query{After: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}},
+		// Words that only look like filters are searched for.
+		{query: "from: https://hudori.test", want: Query{Text: "from: https://hudori.test"}},
+		{query: "note:this", want: Query{Text: "note:this"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseQuery = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{"has:video", "before:yesterday", "after:2024-13-01"} {
+		t.Run(query, func(t *testing.T) {
+			if _, err := ParseQuery(query); err == nil {
+				t.Error("ParseQuery succeeded, want an error")
+			}
+		})
+	}
+}
+
+// search parses query and returns the ids of the messages it finds.
+func search(t *testing.T, s *Store, query string) []string {
+	t.Helper()
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, _, err := s.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, hit := range hits {
+		ids = append(ids, hit.Message.ID)
+	}
+	return ids
+}
+
+func TestSearchFilters(t *testing.T) {
+	s := open(t)
+
+	alice := client.User{ID: "users:alice", Username: "alice", DisplayName: "Alice"}
+	elodie := client.User{ID: "users:elodie", Username: "elo", DisplayName: "Élodie Martin"}
+	general := []client.Message{
+		{ID: "messages:1", Author: alice, Content: "<p>lunch plans</p>", CreatedAt: "2024-05-01T12:00:00Z"},
+		{ID: "messages:2", Author: elodie, Content: "<p>lunch at https://hudori.test/menu</p>", CreatedAt: "2024-05-03T12:00:00Z"},
+		{ID: "messages:3", Author: alice, Content: "<p>photo of lunch</p>", Images: []string{"https://media.hudori.test/a/lunch.PNG"}, CreatedAt: "2024-05-05T12:00:00Z"},
+	}
+	random := []client.Message{
+		{ID: "messages:4", Author: elodie, Content: "<p>lunch menu</p>", Images: []string{"https://media.hudori.test/b/menu.pdf?v=2"}, Mentions: []string{"users:alice"}, CreatedAt: "2024-05-07T12:00:00Z"},
+	}
+	if _, err := s.Put("general", general...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("random", random...); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetName("general", "General"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "from:alice lunch", want: []string{"messages:3", "messages:1"}},
+		{query: "from:Alice", want: []string{"messages:3", "messages:1"}},
+		{query: `from:"elodie martin"`, want: []string{"messages:4", "messages:2"}},
+		{query: "from:users:elodie", want: []string{"messages:4", "messages:2"}},
+		{query: "from:alice from:elo", want: []string{"messages:4", "messages:3", "messages:2", "messages:1"}},
+		{query: "from:nobody", want: []string{}},
+		{query: "in:general", want: []string{"messages:3", "messages:2", "messages:1"}},
+		{query: "in:#general", want: []string{"messages:3", "messages:2", "messages:1"}},
+		{query: "in:channels:random", want: []string{"messages:4"}},
+		{query: "in:random in:general from:elodie", want: []string{"messages:4", "messages:2"}},
+		{query: "has:image", want: []string{"messages:3"}},
+		{query: "has:file", want: []string{"messages:4", "messages:3"}},
+		{query: "has:link", want: []string{"messages:2"}},
+		{query: "has:mention", want: []string{"messages:4"}},
+		{query: "has:image has:mention", want: []string{}},
+		{query: "before:2024-05-02", want: []string{"messages:1"}},
+		{query: "after:2024-05-05", want: []string{"messages:4"}},
+		{query: "after:2024-05-02 before:2024-05-06", want: []string{"messages:3", "messages:2"}},
+		// The names of attachments are searched too.
+		{query: "pdf", want: []string{"messages:4"}},
+		{query: "menu in:general", want: []string{"messages:2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := search(t, s, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	s := open(t)
+
+	bob := client.User{ID: "users:bob", Username: "bob"}
+	ada := client.User{ID: "users:ada", Username: "ada"}
+	msgs := []client.Message{
+		{ID: "messages:prefix", Author: bob, Content: "<p>searching</p>"},
+		{ID: "messages:exact", Author: bob, Content: "<p>search</p>"},
+		{ID: "messages:author", Author: ada, Content: "<p>hello</p>"},
+		{ID: "messages:content", Author: bob, Content: "<p>ada said hello</p>"},
+		{ID: "messages:once", Author: bob, Content: "<p>echo</p>"},
+		{ID: "messages:thrice", Author: bob, Content: "<p>echo echo echo</p>"},
+		{ID: "messages:both", Author: bob, Content: "<p>red apple</p>"},
+		{ID: "messages:red", Author: bob, Content: "<p>red car</p>"},
+	}
+	if _, err := s.Put("general", msgs...); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// A whole word counts more than the start of one.
+		{query: "search", want: []string{"messages:exact", "messages:prefix"}},
+		// The content counts more than the author.
+		{query: "ada", want: []string{"messages:content", "messages:author"}},
+		{query: "echo", want: []string{"messages:thrice", "messages:once"}},
+		// Every word must match.
+		{query: "red apple", want: []string{"messages:both"}},
+		{query: "apple red", want: []string{"messages:both"}},
+		{query: "red banana", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := search(t, s, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchRarerTermsCountMore(t *testing.T) {
+	s := open(t)
+
+	// "common" is in every message, "rare" in one.
+	msgs := []client.Message{
+		{ID: "messages:rare", Content: "<p>common rare</p>"},
+		{ID: "messages:common", Content: "<p>common common</p>"},
+	}
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, client.Message{ID: fmt.Sprintf("messages:filler%d", i), Content: "<p>common</p>"})
+	}
+	if _, err := s.Put("general", msgs...); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _, err := s.Search(Query{Text: "common rare"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Message.ID != "messages:rare" {
+		t.Fatalf("Search = %v, want only messages:rare", hits)
+	}
+
+	common, _, err := s.Search(Query{Text: "common"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits[0].Score <= common[0].Score {
+		t.Errorf("score with the rare word %v, want more than %v without it", hits[0].Score, common[0].Score)
+	}
+}
+
+func TestSearchNewestFirstOnTies(t *testing.T) {
+	s := open(t)
+	if _, err := s.Put("general", messages(1, 5)...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every message scores the same.
+	got := search(t, s, "message")
+	want := ids(messages(1, 5))
+	for i, j := 0, len(want)-1; i < j; i, j = i+1, j-1 {
+		want[i], want[j] = want[j], want[i]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search = %v, want %v", got, want)
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	s := open(t)
+	if _, err := s.Put("general", messages(1, 30)...); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: defaultLimit},
+		{limit: 3, want: 3},
+		{limit: maxLimit + 1, want: 30},
+	}
+	for _, tt := range tests {
+		hits, total, err := s.Search(Query{Text: "message", Limit: tt.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != tt.want || total != 30 {
+			t.Errorf("Search with limit %d = %d hits of %d, want %d of 30", tt.limit, len(hits), total, tt.want)
+		}
+	}
+
+	hits, total, err := s.Search(Query{})
+	if err != nil || hits != nil || total != 0 {
+		t.Errorf("empty Search = %v, %d, %v, want nothing", hits, total, err)
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	s := open(t)
+	msg := client.Message{ID: "messages:1", Content: "<p>🎉 Fête de la <strong>crème</strong></p><p>Crème brûlée</p>"}
+	if _, err := s.Put("general", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, _, err := s.Search(Query{Text: "CREME fete"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("Search found %d messages, want 1", len(hits))
+	}
+
+	hit := hits[0]
+	if hit.Text != "🎉 Fête de la crème\nCrème brûlée" {
+		t.Errorf("Text = %q", hit.Text)
+	}
+	// The emoji is two UTF-16 code units.
+	want := []client.Span{{Start: 3, End: 7}, {Start: 14, End: 19}, {Start: 20, End: 25}}
+	if !reflect.DeepEqual(hit.Highlights, want) {
+		t.Errorf("Highlights = %v, want %v", hit.Highlights, want)
+	}
+}
+
+func TestSearchAfterUpdate(t *testing.T) {
+	s := open(t)
+	msg := client.Message{ID: "messages:1", Content: "<p>before edit</p>"}
+	if _, err := s.Put("general", msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.Content = "<p>after edit</p>"
+	if _, err := s.Put("general", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := search(t, s, "before"); len(got) != 0 {
+		t.Errorf("old content still found: %v", got)
+	}
+	if got := search(t, s, "after"); len(got) != 1 {
+		t.Errorf("new content found %d times, want once", len(got))
+	}
+
+	if err := s.Delete("general", msg.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(t, s, "edit"); len(got) != 0 {
+		t.Errorf("deleted message still found: %v", got)
+	}
+}
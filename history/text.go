@@ -0,0 +1,147 @@
+package history
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// node is a node of a TipTap document.
+type node struct {
+	Type    string                 `json:"type"`
+	Text    string                 `json:"text"`
+	Attrs   map[string]interface{} `json:"attrs"`
+	Content []node                 `json:"content"`
+}
+
+// Text returns the plain text of a message content, which the editor
+// saves either as HTML or as a TipTap JSON document.
+func Text(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "{") {
+		var doc node
+		if json.Unmarshal([]byte(trimmed), &doc) == nil && doc.Type != "" {
+			var b strings.Builder
+			doc.write(&b)
+			return strings.TrimSpace(b.String())
+		}
+	}
+
+	return htmlText(content)
+}
+
+func (n node) write(b *strings.Builder) {
+	switch n.Type {
+	case "text":
+		b.WriteString(n.Text)
+	case "mention":
+		if label, ok := n.Attrs["label"].(string); ok {
+			b.WriteString("@" + label)
+		} else if id, ok := n.Attrs["id"].(string); ok {
+			b.WriteString("@" + id)
+		}
+	case "hardBreak":
+		b.WriteString("\n")
+	}
+
+	for _, child := range n.Content {
+		child.write(b)
+	}
+
+	switch n.Type {
+	case "paragraph", "heading", "blockquote", "codeBlock", "listItem":
+		b.WriteString("\n")
+	}
+}
+
+// blocks are the elements that end a line of text.
+var blocks = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "pre": true,
+	"blockquote": true, "h1": true, "h2": true, "h3": true,
+}
+
+func htmlText(content string) string {
+	var b strings.Builder
+
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case html.TextToken:
+			b.Write(z.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if blocks[string(name)] && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+		}
+	}
+}
+
+// fold lowers s and strips its diacritics, so that "Éte" matches "ete".
+func fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
+
+// word is a term of a text, with where it starts and ends in the text, in
+// UTF-16 code units as the frontend counts them.
+type word struct {
+	term       string
+	start, end int
+}
+
+// words splits text into folded terms.
+func words(text string) []word {
+	var found []word
+
+	start, offset := -1, 0
+	var current []rune
+	flush := func() {
+		if start >= 0 {
+			found = append(found, word{term: fold(string(current)), start: start, end: offset})
+		}
+		start, current = -1, current[:0]
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if start < 0 {
+				start = offset
+			}
+			current = append(current, r)
+		} else {
+			flush()
+		}
+		offset += utf16Len(r)
+	}
+	flush()
+
+	return found
+}
+
+// terms returns the distinct terms of text, with how often they appear.
+func terms(text string) map[string]int {
+	counts := map[string]int{}
+	for _, w := range words(text) {
+		counts[w.term]++
+	}
+	return counts
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,105 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "html", content: "<p>hello <strong>world</strong></p><p>again</p>", want: "hello world\nagain"},
+		{name: "html entities", content: "<p>fish &amp; chips</p>", want: "fish & chips"},
+		{name: "html line break", content: "<p>one<br>two</p>", want: "one\ntwo"},
+		{name: "plain", content: "just text", want: "just text"},
+		{
+			name:    "tiptap",
+			content: `{"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"hi "},{"type":"mention","attrs":{"id":"users:bob","label":"bob"}}]},{"type":"paragraph","content":[{"type":"text","text":"bye"}]}]}`,
+			want:    "hi @bob\nbye",
+		},
+		{
+			name:    "tiptap mention without label",
+			content: `{"type":"doc","content":[{"type":"mention","attrs":{"id":"users:bob"}}]}`,
+			want:    "@users:bob",
+		},
+		{name: "not a document", content: `{"text":"hi"}`, want: `{"text":"hi"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.content); got != tt.want {
+				t.Errorf("Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFold(t *testing.T) {
+	for in, want := range map[string]string{
+		"Éte":        "ete",
+		"CRÈME":      "creme",
+		"e\u0301te":  "ete",
+		"naïve":      "naive",
+		"straße":     "straße",
+		"日本語":        "日本語",
+		"Ünïcödé 42": "unicode 42",
+	} {
+		if got := fold(in); got != want {
+			t.Errorf("fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Hello, world!", want: "[{hello 0 5} {world 7 12}]"},
+		// The offsets count UTF-16 code units: é is one, the cake two.
+		{text: "Crème brûlée 🍰 à la crème", want: "[{creme 0 5} {brulee 6 12} {a 16 17} {la 18 20} {creme 21 26}]"},
+		{text: "🎉🎉 fête", want: "[{fete 5 9}]"},
+		// A combining accent stays in its word.
+		{text: "e\u0301te x", want: "[{ete 0 4} {x 5 6}]"},
+		{text: "日本語 text", want: "[{日本語 0 3} {text 4 8}]"},
+		{text: "", want: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got []string
+			for _, w := range words(tt.text) {
+				got = append(got, fmt.Sprintf("{%s %d %d}", w.term, w.start, w.end))
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("words = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	text := "Crème brûlée 🍰 à la crème"
+
+	tests := []struct {
+		tokens []string
+		want   string
+	}{
+		{tokens: []string{"creme"}, want: "[{0 5} {21 26}]"},
+		{tokens: []string{"bru"}, want: "[{6 12}]"},
+		{tokens: []string{"la", "creme"}, want: "[{0 5} {18 20} {21 26}]"},
+		{tokens: []string{"cake"}, want: "[]"},
+		{tokens: nil, want: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.tokens), func(t *testing.T) {
+			if got := fmt.Sprint(highlights(text, tt.tokens)); got != tt.want {
+				t.Errorf("highlights = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
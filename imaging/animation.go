@@ -0,0 +1,110 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// maxFramePixels bounds the work of processing an animation, all frames
+// together.
+const maxFramePixels = 500_000_000
+
+// processAnimation crops and scales every frame of an animated GIF. Frames
+// of a GIF may only cover part of the picture and build on the previous
+// ones, so each is drawn on a canvas first, and the canvas is what is
+// cropped.
+func processAnimation(anim *gif.GIF, crop image.Rectangle, target Target) (Result, error) {
+	canvasRect := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	if canvasRect.Empty() {
+		canvasRect = anim.Image[0].Bounds()
+		for _, frame := range anim.Image[1:] {
+			canvasRect = canvasRect.Union(frame.Bounds())
+		}
+	}
+	if canvasRect.Dx()*canvasRect.Dy()*len(anim.Image) > maxFramePixels {
+		return Result{}, fmt.Errorf("animation has too many frames")
+	}
+
+	crop = cropRect(crop, canvasRect, target)
+	width, height := FitSize(crop.Dx(), crop.Dy(), target)
+
+	out := &gif.GIF{
+		LoopCount: anim.LoopCount,
+		Config:    image.Config{Width: width, Height: height},
+	}
+
+	canvas := image.NewRGBA(canvasRect)
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, frame := range anim.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvasRect)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		// Bilinear is plenty for frames this small, and much faster than
+		// Catmull-Rom over a long animation.
+		draw.Draw(scaled, scaled.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), canvas, crop, xdraw.Src, nil)
+
+		paletted := image.NewPaletted(scaled.Bounds(), framePalette(frame.Palette, scaled))
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), scaled, image.Point{})
+		out.Image = append(out.Image, paletted)
+
+		delay := 0
+		if i < len(anim.Delay) {
+			delay = anim.Delay[i]
+		}
+		out.Delay = append(out.Delay, delay)
+		// Every frame is whole, so each one clears the last.
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, out)
+	if err != nil {
+		return Result{}, fmt.Errorf("error encoding picture: %w", err)
+	}
+
+	return Result{Data: buf.Bytes(), Format: GIF, Width: width, Height: height}, nil
+}
+
+// framePalette returns the palette of a frame, with a transparent color
+// when img has transparent pixels and the palette has none.
+func framePalette(p color.Palette, img *image.RGBA) color.Palette {
+	for _, c := range p {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return p
+		}
+	}
+	if img.Opaque() {
+		return p
+	}
+
+	palette := make(color.Palette, 0, len(p)+1)
+	palette = append(palette, p...)
+	if len(palette) == 256 {
+		palette = palette[:255]
+	}
+	return append(palette, color.Transparent)
+}
@@ -0,0 +1,213 @@
+// Package imaging prepares pictures before they are uploaded: it crops
+// them, scales them down to the size the server keeps, and encodes them
+// again. Only pixels are encoded, so EXIF, XMP and any other metadata of
+// the original file are left behind.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels keeps huge pictures from being decoded.
+const maxPixels = 50_000_000
+
+// jpegQuality is the quality of the JPEGs the package encodes.
+const jpegQuality = 85
+
+// Format is the format a picture is encoded to.
+type Format string
+
+const (
+	// Auto picks JPEG for opaque pictures and WebP for the others, and
+	// keeps animated GIFs as GIFs when the target allows animation.
+	Auto Format = ""
+	WebP Format = "webp"
+	PNG  Format = "png"
+	JPEG Format = "jpeg"
+	GIF  Format = "gif"
+)
+
+// MIME returns the content type of the format.
+func (f Format) MIME() string {
+	return "image/" + string(f)
+}
+
+// Ext returns the file extension of the format, with the dot.
+func (f Format) Ext() string {
+	if f == JPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// Target is what a picture is prepared for.
+type Target struct {
+	// Width and Height bound the size of the result. Pictures are never
+	// scaled up.
+	Width  int
+	Height int
+	// Animated keeps every frame of animated GIFs.
+	Animated bool
+	// Cover crops the picture around its center to the proportions of
+	// Width by Height, so that the result fills them.
+	Cover bool
+}
+
+// The sizes the server keeps avatars and banners at.
+var (
+	Avatar = Target{Width: 256, Height: 256, Animated: true}
+	Banner = Target{Width: 960, Height: 600}
+)
+
+// ErrUnsupported is returned for files that are not pictures the package
+// decodes: JPEG, PNG, GIF and WebP.
+var ErrUnsupported = errors.New("unsupported picture format")
+
+// Result is a processed picture.
+type Result struct {
+	Data   []byte
+	Format Format
+	Width  int
+	Height int
+}
+
+// Name returns name with the extension of the result.
+func (r Result) Name(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + r.Format.Ext()
+}
+
+// Process crops data to crop, in the coordinates of the picture as it is
+// displayed, scales it down to fit target and encodes it to format. An
+// empty crop keeps the whole picture.
+func Process(data []byte, crop image.Rectangle, target Target, format Format) (Result, error) {
+	config, kind, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return Result{}, ErrUnsupported
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("error reading picture: %w", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return Result{}, fmt.Errorf("picture is larger than %d megapixels", maxPixels/1_000_000)
+	}
+
+	var src image.Image
+	if kind == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Result{}, fmt.Errorf("error decoding picture: %w", err)
+		}
+		if len(anim.Image) > 1 && target.Animated && (format == Auto || format == GIF) {
+			return processAnimation(anim, crop, target)
+		}
+		// The first frame may not cover the whole picture.
+		canvas := image.NewNRGBA(image.Rect(0, 0, config.Width, config.Height))
+		draw.Draw(canvas, anim.Image[0].Bounds(), anim.Image[0], anim.Image[0].Bounds().Min, draw.Over)
+		src = canvas
+	} else {
+		src, _, err = image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Result{}, fmt.Errorf("error decoding picture: %w", err)
+		}
+		if kind == "jpeg" {
+			src = orient(src, orientation(data))
+		}
+	}
+
+	crop = cropRect(crop, src.Bounds(), target)
+	width, height := FitSize(crop.Dx(), crop.Dy(), target)
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, xdraw.Src, nil)
+
+	if format == Auto {
+		format = JPEG
+		if !dst.Opaque() {
+			format = WebP
+		}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case JPEG:
+		err = jpeg.Encode(&buf, flatten(dst), &jpeg.Options{Quality: jpegQuality})
+	case PNG:
+		err = png.Encode(&buf, dst)
+	case WebP:
+		err = EncodeWebP(&buf, dst)
+	case GIF:
+		err = gif.Encode(&buf, dst, nil)
+	default:
+		return Result{}, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("error encoding picture: %w", err)
+	}
+
+	return Result{Data: buf.Bytes(), Format: format, Width: width, Height: height}, nil
+}
+
+// cropRect returns crop, moved to the origin of bounds and kept within
+// it. Crops that miss the picture keep all of it.
+func cropRect(crop image.Rectangle, bounds image.Rectangle, target Target) image.Rectangle {
+	crop = crop.Add(bounds.Min).Intersect(bounds)
+	if crop.Empty() {
+		crop = bounds
+	}
+	if !target.Cover {
+		return crop
+	}
+
+	w, h := crop.Dx(), crop.Dy()
+	if w*target.Height > h*target.Width {
+		cw := max(1, h*target.Width/target.Height)
+		x := crop.Min.X + (w-cw)/2
+		return image.Rect(x, crop.Min.Y, x+cw, crop.Max.Y)
+	}
+	ch := max(1, w*target.Height/target.Width)
+	y := crop.Min.Y + (h-ch)/2
+	return image.Rect(crop.Min.X, y, crop.Max.X, y+ch)
+}
+
+// FitSize scales width by height down, keeping its proportions, so that it
+// fits in the width by height of target.
+func FitSize(width, height int, target Target) (int, int) {
+	if width > target.Width || height > target.Height {
+		if width*target.Height > height*target.Width {
+			width, height = target.Width, max(1, height*target.Width/width)
+		} else {
+			width, height = max(1, width*target.Height/height), target.Height
+		}
+	}
+	return width, height
+}
+
+// flatten paints img over white, as JPEG has no transparency.
+func flatten(img image.Image) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// toNRGBA returns img as an *image.NRGBA at the origin.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,242 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func TestCropRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+
+	tests := []struct {
+		name   string
+		crop   image.Rectangle
+		bounds image.Rectangle
+		target Target
+		want   image.Rectangle
+	}{
+		{
+			name:   "empty keeps everything",
+			bounds: bounds,
+			want:   bounds,
+		},
+		{
+			name:   "inside",
+			crop:   image.Rect(10, 20, 110, 120),
+			bounds: bounds,
+			want:   image.Rect(10, 20, 110, 120),
+		},
+		{
+			name:   "clipped to the picture",
+			crop:   image.Rect(300, 150, 500, 300),
+			bounds: bounds,
+			want:   image.Rect(300, 150, 400, 200),
+		},
+		{
+			name:   "missing the picture",
+			crop:   image.Rect(500, 500, 600, 600),
+			bounds: bounds,
+			want:   bounds,
+		},
+		{
+			name:   "moved to the origin of the picture",
+			crop:   image.Rect(0, 0, 50, 50),
+			bounds: image.Rect(100, 100, 300, 300),
+			want:   image.Rect(100, 100, 150, 150),
+		},
+		{
+			name:   "cover of a wider picture",
+			bounds: bounds,
+			target: Target{Width: 100, Height: 100, Cover: true},
+			want:   image.Rect(100, 0, 300, 200),
+		},
+		{
+			name:   "cover of a taller picture",
+			bounds: image.Rect(0, 0, 200, 400),
+			target: Target{Width: 200, Height: 100, Cover: true},
+			want:   image.Rect(0, 150, 200, 250),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cropRect(tt.crop, tt.bounds, tt.target); got != tt.want {
+				t.Errorf("cropRect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		target        Target
+		wantW, wantH  int
+	}{
+		{width: 100, height: 50, target: Target{Width: 256, Height: 256}, wantW: 100, wantH: 50},
+		{width: 1000, height: 500, target: Target{Width: 256, Height: 256}, wantW: 256, wantH: 128},
+		{width: 500, height: 1000, target: Target{Width: 256, Height: 256}, wantW: 128, wantH: 256},
+		{width: 960, height: 600, target: Banner, wantW: 960, wantH: 600},
+		{width: 10000, height: 1, target: Target{Width: 100, Height: 100}, wantW: 100, wantH: 1},
+	}
+
+	for _, tt := range tests {
+		w, h := FitSize(tt.width, tt.height, tt.target)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("FitSize(%d, %d, %+v) = %d, %d, want %d, %d", tt.width, tt.height, tt.target, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	opaque := encodePNG(t, picture(400, 200, func(x, y int) color.NRGBA {
+		return color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: 255}
+	}))
+	transparent := encodePNG(t, picture(400, 200, func(x, y int) color.NRGBA {
+		return color.NRGBA{R: uint8(x), G: uint8(y), B: 100, A: uint8(x)}
+	}))
+
+	tests := []struct {
+		name       string
+		data       []byte
+		crop       image.Rectangle
+		target     Target
+		format     Format
+		wantFormat Format
+		wantW      int
+		wantH      int
+	}{
+		{name: "opaque", data: opaque, target: Banner, wantFormat: JPEG, wantW: 400, wantH: 200},
+		{name: "transparent", data: transparent, target: Banner, wantFormat: WebP, wantW: 400, wantH: 200},
+		{name: "scaled", data: opaque, target: Target{Width: 100, Height: 100}, wantFormat: JPEG, wantW: 100, wantH: 50},
+		{name: "cover", data: opaque, target: Target{Width: 64, Height: 64, Cover: true}, wantFormat: JPEG, wantW: 64, wantH: 64},
+		{name: "cropped", data: opaque, crop: image.Rect(50, 50, 150, 100), target: Banner, format: PNG, wantFormat: PNG, wantW: 100, wantH: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Process(tt.data, tt.crop, tt.target, tt.format)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if result.Format != tt.wantFormat || result.Width != tt.wantW || result.Height != tt.wantH {
+				t.Fatalf("Process = %s %dx%d, want %s %dx%d", result.Format, result.Width, result.Height, tt.wantFormat, tt.wantW, tt.wantH)
+			}
+
+			config, kind, err := image.DecodeConfig(bytes.NewReader(result.Data))
+			if err != nil {
+				t.Fatalf("decoding result: %v", err)
+			}
+			if Format(kind) != tt.wantFormat || config.Width != tt.wantW || config.Height != tt.wantH {
+				t.Errorf("result is a %s of %dx%d", kind, config.Width, config.Height)
+			}
+		})
+	}
+}
+
+func TestProcessUnsupported(t *testing.T) {
+	_, err := Process([]byte("not a picture"), image.Rectangle{}, Avatar, Auto)
+	if err != ErrUnsupported {
+		t.Errorf("Process = %v, want ErrUnsupported", err)
+	}
+}
+
+// animation returns a 3 frame GIF: red, then a green square over the top
+// left corner, then blue.
+func animation(t *testing.T) []byte {
+	t.Helper()
+	palette := color.Palette{color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}, color.RGBA{B: 255, A: 255}}
+	frame := func(rect image.Rectangle, index uint8) *image.Paletted {
+		img := image.NewPaletted(rect, palette)
+		for i := range img.Pix {
+			img.Pix[i] = index
+		}
+		return img
+	}
+
+	anim := &gif.GIF{
+		Image:    []*image.Paletted{frame(image.Rect(0, 0, 40, 20), 0), frame(image.Rect(0, 0, 10, 10), 1), frame(image.Rect(0, 0, 40, 20), 2)},
+		Delay:    []int{10, 20, 30},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalNone},
+		Config:   image.Config{Width: 40, Height: 20, ColorModel: palette},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessAnimation(t *testing.T) {
+	result, err := Process(animation(t), image.Rectangle{}, Avatar, Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != GIF {
+		t.Fatalf("format = %s, want gif", result.Format)
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 {
+		t.Fatalf("%d frames, want 3", len(anim.Image))
+	}
+	if anim.Delay[1] != 20 {
+		t.Errorf("delays = %v, want those of the original", anim.Delay)
+	}
+
+	// The partial second frame is drawn over the first.
+	second := anim.Image[1]
+	if second.Bounds() != image.Rect(0, 0, 40, 20) {
+		t.Fatalf("second frame covers %v, want all of the picture", second.Bounds())
+	}
+	if r, g, _, _ := second.At(2, 2).RGBA(); g>>8 != 255 || r != 0 {
+		t.Errorf("corner of the second frame = %v, want green", second.At(2, 2))
+	}
+	if r, g, _, _ := second.At(30, 15).RGBA(); r>>8 != 255 || g != 0 {
+		t.Errorf("rest of the second frame = %v, want the red of the first", second.At(30, 15))
+	}
+}
+
+func TestProcessAnimationCropped(t *testing.T) {
+	result, err := Process(animation(t), image.Rect(20, 0, 40, 20), Target{Width: 10, Height: 10, Animated: true}, Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 || result.Width != 10 || result.Height != 10 {
+		t.Fatalf("%d frames of %dx%d, want 3 of 10x10", len(anim.Image), result.Width, result.Height)
+	}
+	// The green square is outside the crop.
+	if r, g, _, _ := anim.Image[1].At(2, 2).RGBA(); r>>8 != 255 || g != 0 {
+		t.Errorf("second frame = %v, want red", anim.Image[1].At(2, 2))
+	}
+}
+
+func TestProcessAnimationStill(t *testing.T) {
+	result, err := Process(animation(t), image.Rectangle{}, Banner, Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != JPEG {
+		t.Errorf("format = %s, want the first frame as a jpeg", result.Format)
+	}
+}
@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation returns a JPEG of img whose EXIF data has the given
+// orientation, written in the given byte order.
+func withOrientation(t *testing.T, img image.Image, orientation int, order binary.ByteOrder) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], orientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	// The segment goes right after the start of image.
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func TestOrientation(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 4))
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for want := 1; want <= 8; want++ {
+			t.Run(fmt.Sprintf("%s %d", order, want), func(t *testing.T) {
+				if got := orientation(withOrientation(t, img, want, order)); got != want {
+					t.Errorf("orientation = %d, want %d", got, want)
+				}
+			})
+		}
+	}
+
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"no exif":      plain.Bytes(),
+		"not a jpeg":   []byte("\x89PNG\r\n\x1a\n"),
+		"truncated":    withOrientation(t, img, 6, binary.BigEndian)[:12],
+		"out of range": withOrientation(t, img, 9, binary.BigEndian),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := orientation(data); got != 1 {
+				t.Errorf("orientation = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A B C
+	// D E F
+	labels := "ABCDEF"
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for i := range labels {
+		src.SetNRGBA(i%3, i/3, color.NRGBA{R: labels[i], A: 255})
+	}
+
+	// How each orientation is displayed, row by row.
+	want := map[int][]string{
+		1: {"ABC", "DEF"},
+		2: {"CBA", "FED"},
+		3: {"FED", "CBA"},
+		4: {"DEF", "ABC"},
+		5: {"AD", "BE", "CF"},
+		6: {"DA", "EB", "FC"},
+		7: {"FC", "EB", "DA"},
+		8: {"CF", "BE", "AD"},
+	}
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		t.Run(fmt.Sprint(orientation), func(t *testing.T) {
+			dst := toNRGBA(orient(src, orientation))
+			var rows []string
+			for y := 0; y < dst.Rect.Dy(); y++ {
+				var row []byte
+				for x := 0; x < dst.Rect.Dx(); x++ {
+					row = append(row, dst.NRGBAAt(x, y).R)
+				}
+				rows = append(rows, string(row))
+			}
+			if fmt.Sprint(rows) != fmt.Sprint(want[orientation]) {
+				t.Errorf("orient = %v, want %v", rows, want[orientation])
+			}
+		})
+	}
+}
+
+func TestProcessOrients(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 80, 40))
+	for orientation := 1; orientation <= 8; orientation++ {
+		t.Run(fmt.Sprint(orientation), func(t *testing.T) {
+			data := withOrientation(t, img, orientation, binary.BigEndian)
+			result, err := Process(data, image.Rectangle{}, Banner, Auto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, h := 80, 40
+			if orientation >= 5 {
+				w, h = 40, 80
+			}
+			if result.Width != w || result.Height != h {
+				t.Errorf("Process = %dx%d, want %dx%d", result.Width, result.Height, w, h)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"sort"
+)
+
+// This is a lossless WebP (VP8L) encoder, small rather than thorough: it
+// uses the subtract-green transform and LZ77 back-references, with a
+// single set of prefix codes and no color cache. The format is described
+// in https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification.
+
+const (
+	maxWebPSide = 1 << 14
+
+	numLiterals      = 256
+	numLengthCodes   = 24
+	numDistanceCodes = 40
+	maxCodeLength    = 15
+
+	minMatch    = 3
+	maxMatch    = 4096
+	window      = 1 << 16
+	maxChain    = 16
+	hashBits    = 16
+	distanceMap = 120
+)
+
+// codeLengthOrder is the order in which the lengths of the code length
+// code are written.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) write(value uint32, n uint) {
+	w.acc |= uint64(value) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
+
+// symbol is a literal pixel or a back-reference.
+type symbol struct {
+	argb     uint32
+	length   int
+	distance int
+}
+
+// prefix splits a length or distance into a prefix code and extra bits.
+func prefix(value int) (code int, extraBits uint, extra uint32) {
+	x := value - 1
+	if x < 4 {
+		return x, 0, 0
+	}
+	hb := 31
+	for x>>uint(hb) == 0 {
+		hb--
+	}
+	second := (x >> uint(hb-1)) & 1
+	extraBits = uint(hb - 1)
+	return 2*hb + second, extraBits, uint32(x & (1<<extraBits - 1))
+}
+
+// EncodeWebP writes img to w as a lossless WebP.
+func EncodeWebP(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > maxWebPSide || height > maxWebPSide {
+		return errors.New("webp: invalid image size")
+	}
+
+	pixels := make([]uint32, 0, width*height)
+	alpha := false
+	nrgba := toNRGBA(img)
+	for y := 0; y < height; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+width*4]
+		for x := 0; x < width; x++ {
+			r, g, b, a := row[x*4], row[x*4+1], row[x*4+2], row[x*4+3]
+			if a != 0xff {
+				alpha = true
+			}
+			// Subtract green transform.
+			r -= g
+			b -= g
+			pixels = append(pixels, uint32(a)<<24|uint32(r)<<16|uint32(g)<<8|uint32(b))
+		}
+	}
+
+	symbols := backReferences(pixels)
+
+	var histograms [5][]int
+	histograms[0] = make([]int, numLiterals+numLengthCodes)
+	histograms[1] = make([]int, numLiterals)
+	histograms[2] = make([]int, numLiterals)
+	histograms[3] = make([]int, numLiterals)
+	histograms[4] = make([]int, numDistanceCodes)
+	for _, s := range symbols {
+		if s.length == 0 {
+			histograms[0][s.argb>>8&0xff]++
+			histograms[1][s.argb>>16&0xff]++
+			histograms[2][s.argb&0xff]++
+			histograms[3][s.argb>>24]++
+			continue
+		}
+		code, _, _ := prefix(s.length)
+		histograms[0][numLiterals+code]++
+		code, _, _ = prefix(s.distance + distanceMap)
+		histograms[4][code]++
+	}
+
+	bw := &bitWriter{}
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+
+	// One transform, subtract green, then the end of the transforms.
+	bw.write(1, 1)
+	bw.write(2, 2)
+	bw.write(0, 1)
+
+	// No color cache, and a single group of prefix codes.
+	bw.write(0, 1)
+	bw.write(0, 1)
+
+	var codes [5][]huffmanCode
+	for i, histogram := range histograms {
+		codes[i] = writePrefixCode(bw, histogram)
+	}
+
+	for _, s := range symbols {
+		if s.length == 0 {
+			codes[0][s.argb>>8&0xff].write(bw)
+			codes[1][s.argb>>16&0xff].write(bw)
+			codes[2][s.argb&0xff].write(bw)
+			codes[3][s.argb>>24].write(bw)
+			continue
+		}
+		code, n, extra := prefix(s.length)
+		codes[0][numLiterals+code].write(bw)
+		bw.write(extra, n)
+		code, n, extra = prefix(s.distance + distanceMap)
+		codes[4][code].write(bw)
+		bw.write(extra, n)
+	}
+
+	data := bw.bytes()
+	chunk := len(data)
+	padded := chunk + chunk&1
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunk))
+
+	_, err := w.Write(header)
+	if err != nil {
+		return err
+	}
+	if chunk&1 == 1 {
+		data = append(data, 0)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// backReferences finds repeated runs of pixels with a hash chain.
+func backReferences(pixels []uint32) []symbol {
+	symbols := make([]symbol, 0, len(pixels)/2)
+
+	head := make([]int32, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, len(pixels))
+
+	hash := func(i int) uint32 {
+		h := pixels[i]*0x1e35a7bd ^ pixels[i+1]*0x9e3779b1 ^ pixels[i+2]*0x85ebca6b
+		return h >> (32 - hashBits)
+	}
+	insert := func(i int) {
+		if i+minMatch > len(pixels) {
+			return
+		}
+		h := hash(i)
+		prev[i] = head[h]
+		head[h] = int32(i)
+	}
+
+	for i := 0; i < len(pixels); {
+		best, bestAt := 0, 0
+		if i+minMatch <= len(pixels) {
+			limit := min(maxMatch, len(pixels)-i)
+			candidate := head[hash(i)]
+			for chain := 0; candidate >= 0 && chain < maxChain && i-int(candidate) <= window; chain++ {
+				j := int(candidate)
+				n := 0
+				for n < limit && pixels[j+n] == pixels[i+n] {
+					n++
+				}
+				if n > best {
+					best, bestAt = n, j
+					if n == limit {
+						break
+					}
+				}
+				candidate = prev[j]
+			}
+		}
+
+		if best >= minMatch {
+			symbols = append(symbols, symbol{length: best, distance: i - bestAt})
+			for k := 0; k < best; k++ {
+				insert(i + k)
+			}
+			i += best
+			continue
+		}
+
+		symbols = append(symbols, symbol{argb: pixels[i]})
+		insert(i)
+		i++
+	}
+
+	return symbols
+}
+
+type huffmanCode struct {
+	bits   uint32
+	length uint
+}
+
+func (c huffmanCode) write(w *bitWriter) {
+	w.write(c.bits, c.length)
+}
+
+// writePrefixCode writes the prefix code of histogram and returns it.
+func writePrefixCode(w *bitWriter, histogram []int) []huffmanCode {
+	var used []int
+	for s, n := range histogram {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+
+	// Codes of one or two symbols below 256 have a short form, in which
+	// a single symbol takes no bits at all.
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		w.write(1, 1)
+		w.write(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(used[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(used[0]), 8)
+		}
+		codes := make([]huffmanCode, len(histogram))
+		if len(used) == 2 {
+			w.write(uint32(used[1]), 8)
+			codes[used[0]] = huffmanCode{bits: 0, length: 1}
+			codes[used[1]] = huffmanCode{bits: 1, length: 1}
+		}
+		return codes
+	}
+
+	// A normal code needs two symbols at least.
+	if len(used) == 1 {
+		other := 0
+		if used[0] == 0 {
+			other = 1
+		}
+		histogram = append([]int(nil), histogram...)
+		histogram[other] = 1
+	}
+
+	lengths := codeLengths(histogram, maxCodeLength)
+	w.write(0, 1)
+	writeCodeLengths(w, lengths)
+	return canonical(lengths)
+}
+
+// writeCodeLengths writes the lengths of a normal prefix code, themselves
+// coded with the code length code.
+func writeCodeLengths(w *bitWriter, lengths []int) {
+	type token struct {
+		symbol    int
+		extra     uint32
+		extraBits uint
+	}
+	var tokens []token
+	for i := 0; i < len(lengths); {
+		n := 1
+		for i+n < len(lengths) && lengths[i+n] == lengths[i] {
+			n++
+		}
+		if lengths[i] == 0 {
+			for n >= 3 {
+				switch {
+				case n >= 11:
+					run := min(n, 138)
+					tokens = append(tokens, token{18, uint32(run - 11), 7})
+					i, n = i+run, n-run
+				default:
+					run := min(n, 10)
+					tokens = append(tokens, token{17, uint32(run - 3), 3})
+					i, n = i+run, n-run
+				}
+			}
+		} else {
+			// Repeats of a non-zero length refer to the previous length,
+			// so the first one is written as is.
+			tokens = append(tokens, token{symbol: lengths[i]})
+			i, n = i+1, n-1
+			for n >= 3 {
+				run := min(n, 6)
+				tokens = append(tokens, token{16, uint32(run - 3), 2})
+				i, n = i+run, n-run
+			}
+		}
+		for ; n > 0; n-- {
+			tokens = append(tokens, token{symbol: lengths[i]})
+			i++
+		}
+	}
+
+	histogram := make([]int, len(codeLengthOrder))
+	for _, t := range tokens {
+		histogram[t.symbol]++
+	}
+	if used := nonZero(histogram); used < 2 {
+		for s := range histogram {
+			if histogram[s] == 0 {
+				histogram[s] = 1
+				break
+			}
+		}
+	}
+	codeLengthLengths := codeLengths(histogram, 7)
+	codeLengthCodes := canonical(codeLengthLengths)
+
+	count := len(codeLengthOrder)
+	for count > 4 && codeLengthLengths[codeLengthOrder[count-1]] == 0 {
+		count--
+	}
+	w.write(uint32(count-4), 4)
+	for _, s := range codeLengthOrder[:count] {
+		w.write(uint32(codeLengthLengths[s]), 3)
+	}
+
+	// The lengths of every symbol of the alphabet follow.
+	w.write(0, 1)
+	for _, t := range tokens {
+		codeLengthCodes[t.symbol].write(w)
+		w.write(t.extra, t.extraBits)
+	}
+}
+
+func nonZero(histogram []int) int {
+	n := 0
+	for _, count := range histogram {
+		if count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// codeLengths returns Huffman code lengths for histogram, none longer than
+// limit. When the optimal code is too deep, counts are flattened and the
+// code built again.
+func codeLengths(histogram []int, limit int) []int {
+	counts := append([]int(nil), histogram...)
+	for {
+		lengths := huffman(counts)
+		deepest := 0
+		for _, l := range lengths {
+			deepest = max(deepest, l)
+		}
+		if deepest <= limit {
+			return lengths
+		}
+		for i, n := range counts {
+			if n > 0 {
+				counts[i] = n/2 + 1
+			}
+		}
+	}
+}
+
+func huffman(counts []int) []int {
+	type node struct {
+		count       int
+		symbol      int
+		left, right *node
+	}
+	var nodes []*node
+	for s, n := range counts {
+		if n > 0 {
+			nodes = append(nodes, &node{count: n, symbol: s})
+		}
+	}
+
+	lengths := make([]int, len(counts))
+	if len(nodes) == 1 {
+		lengths[nodes[0].symbol] = 1
+		return lengths
+	}
+
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].count < nodes[j].count })
+		merged := &node{count: nodes[0].count + nodes[1].count, symbol: -1, left: nodes[0], right: nodes[1]}
+		nodes = append([]*node{merged}, nodes[2:]...)
+	}
+
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n.symbol >= 0 {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(nodes[0], 0)
+
+	return lengths
+}
+
+// canonical assigns the canonical codes of lengths, bit-reversed as the
+// bits of a code are read first to last.
+func canonical(lengths []int) []huffmanCode {
+	var count [maxCodeLength + 1]int
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+
+	var next [maxCodeLength + 2]uint32
+	code := uint32(0)
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + uint32(count[l-1])) << 1
+		next[l] = code
+	}
+
+	codes := make([]huffmanCode, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+
+		reversed := uint32(0)
+		for i := 0; i < l; i++ {
+			reversed = reversed<<1 | (c>>uint(i))&1
+		}
+		codes[s] = huffmanCode{bits: reversed, length: uint(l)}
+	}
+	return codes
+}
@@ -0,0 +1,132 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// picture returns a width by height picture whose pixels come from at.
+func picture(width, height int, at func(x, y int) color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, at(x, y))
+		}
+	}
+	return img
+}
+
+func TestEncodeWebP(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{
+			name: "single pixel",
+			img:  picture(1, 1, func(x, y int) color.NRGBA { return color.NRGBA{R: 200, G: 10, B: 90, A: 255} }),
+		},
+		{
+			name: "opaque gradient",
+			img: picture(64, 48, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 4), G: uint8(y * 5), B: uint8(x + y), A: 255}
+			}),
+		},
+		{
+			name: "alpha",
+			img: picture(40, 30, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 6), G: 128, B: uint8(y * 8), A: uint8(x * y)}
+			}),
+		},
+		{
+			name: "odd size",
+			img: picture(17, 5, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x * 15), G: uint8(y * 50), B: 7, A: 255}
+			}),
+		},
+		{
+			name: "tall and thin",
+			img: picture(1, 33, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(y), G: uint8(y * 3), B: uint8(y * 7), A: uint8(255 - y)}
+			}),
+		},
+		{
+			// Long repeated runs, to go through back-references.
+			name: "repeated pattern",
+			img: picture(301, 203, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(x % 7 * 30), G: uint8(y % 3 * 80), B: uint8((x + y) % 5 * 50), A: 255}
+			}),
+		},
+		{
+			name: "noise",
+			img: picture(97, 61, func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(random.Intn(256)), G: uint8(random.Intn(256)), B: uint8(random.Intn(256)), A: uint8(random.Intn(256))}
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, tt.img); err != nil {
+				t.Fatalf("EncodeWebP: %v", err)
+			}
+
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			if decoded.Bounds() != tt.img.Bounds() {
+				t.Fatalf("decoded size %v, want %v", decoded.Bounds(), tt.img.Bounds())
+			}
+			got := toNRGBA(decoded)
+			for y := 0; y < tt.img.Rect.Dy(); y++ {
+				for x := 0; x < tt.img.Rect.Dx(); x++ {
+					if g, w := got.NRGBAAt(x, y), tt.img.NRGBAAt(x, y); g != w {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPSubImage(t *testing.T) {
+	img := picture(20, 20, func(x, y int) color.NRGBA {
+		return color.NRGBA{R: uint8(x * 10), G: uint8(y * 10), B: 50, A: 255}
+	})
+	sub := img.SubImage(image.Rect(5, 7, 12, 16))
+
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, sub); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := webp.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Bounds(); got != image.Rect(0, 0, 7, 9) {
+		t.Fatalf("decoded size %v, want 7x9", got)
+	}
+	if got, want := toNRGBA(decoded).NRGBAAt(0, 0), img.NRGBAAt(5, 7); got != want {
+		t.Errorf("first pixel = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeWebPInvalidSize(t *testing.T) {
+	for _, size := range []image.Rectangle{image.Rect(0, 0, 0, 10), image.Rect(0, 0, maxWebPSide+1, 1)} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, image.NewNRGBA(size)); err == nil {
+				t.Error("EncodeWebP succeeded, want an error")
+			}
+		})
+	}
+}
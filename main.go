@@ -2,6 +2,14 @@ package main
 
 import (
 	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"hudori-desktop/config"
+	"hudori-desktop/deeplink"
+	"hudori-desktop/fake"
+	"hudori-desktop/session"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/logger"
@@ -14,25 +22,63 @@ import (
 var assets embed.FS
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		println("Error:", err.Error())
+		return
+	}
+
+	dataDir := session.DefaultDir()
+	passphrase := os.Getenv(session.PassphraseEnv)
+	// A demo runs beside the app rather than take the links meant for it.
+	instanceID := "hudori-desktop"
+	if demo, fixtures := cfg.Demo(); demo {
+		instanceID = "hudori-desktop-demo"
+		backend, dir, err := startDemo(cfg, fixtures)
+		if err != nil {
+			println("Error:", err.Error())
+			return
+		}
+		defer backend.Close()
+		defer os.RemoveAll(dir)
+		dataDir, passphrase = dir, "demo"
+	}
+	sessions := session.NewStore(dataDir, passphrase)
+
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp(cfg, sessions, dataDir)
+	if link, ok := deeplink.Find(os.Args[1:]); ok {
+		app.queueLink(link)
+	}
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:  "hudori-desktop",
 		Width:  1024,
 		Height: 768,
 		AssetServer: &assetserver.Options{
-			Assets: assets,
+			Assets:     assets,
+			Middleware: app.serveMedia,
 		},
 		LogLevel:           logger.WARNING,
 		LogLevelProduction: logger.ERROR,
 		BackgroundColour:   &options.RGBA{R: 27, G: 38, B: 54, A: 1},
+		DragAndDrop: &options.DragAndDrop{
+			EnableFileDrop: true,
+		},
 		Linux: &linux.Options{
 			WebviewGpuPolicy:    linux.WebviewGpuPolicyAlways,
 			WindowIsTranslucent: true,
 		},
-		OnStartup: app.startup,
+		// The app launched for a hudori:// link while it runs hands the
+		// link over and quits.
+		SingleInstanceLock: &options.SingleInstanceLock{
+			UniqueId:               instanceID,
+			OnSecondInstanceLaunch: app.secondInstance,
+		},
+		OnStartup:     app.startup,
+		OnBeforeClose: app.beforeClose,
+		OnShutdown:    app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -41,3 +87,45 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// startDemo runs the fake backend seeded with fixtures, or the built-in
+// demo data, and points cfg at it. The sessions, cache and settings of the
+// demo live in a temporary directory, which is returned, so that it never
+// touches those of the real accounts.
+func startDemo(cfg *config.Config, fixtures []string) (*fake.Server, string, error) {
+	fixture, err := fake.Load(fixtures...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	backend, err := fake.New(fixture)
+	if err != nil {
+		return nil, "", fmt.Errorf("error seeding the demo backend: %w", err)
+	}
+
+	url, err := backend.Start("127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+
+	dir, err := os.MkdirTemp("", "hudori-demo-")
+	if err != nil {
+		backend.Close()
+		return nil, "", fmt.Errorf("error creating demo directory: %w", err)
+	}
+
+	err = cfg.UseDemo(url, filepath.Join(dir, "config.json"))
+	if err != nil {
+		backend.Close()
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+
+	if len(fixtures) == 0 {
+		println("Demo backend running at", url+"; sign in as alice@hudori.test with the password demo")
+	} else {
+		println("Demo backend running at", url)
+	}
+
+	return backend, dir, nil
+}
@@ -0,0 +1,303 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultCacheSize is how much disk space the media cache takes at most.
+const DefaultCacheSize = 1 << 30
+
+// ErrTooLarge is returned for files too large to be cached.
+var ErrTooLarge = errors.New("file too large for the media cache")
+
+// The index of the cache maps the URLs media were fetched from to the
+// hash of their content, and the hashes to what is known of each blob.
+var (
+	urlsBucket  = []byte("urls")
+	blobsBucket = []byte("blobs")
+)
+
+// Blob is a file of the cache.
+type Blob struct {
+	Hash string
+	Path string
+	Size int64
+	MIME string
+}
+
+type entry struct {
+	Size int64     `json:"size"`
+	MIME string    `json:"mime"`
+	Used time.Time `json:"used"`
+}
+
+// Cache keeps media on disk, named after the SHA-256 of their content so
+// that a file shared by several URLs, such as an avatar used as a server
+// icon, is only stored once. The least recently used files are evicted
+// when the cache grows over its limit.
+type Cache struct {
+	dir   string
+	limit int64
+	db    *bolt.DB
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	size    int64
+	// touched holds the blobs used since the index was last written, so
+	// reading from the cache does not write to disk every time.
+	touched map[string]bool
+}
+
+// OpenCache opens the cache in dir, creating it if needed.
+func OpenCache(dir string, limit int64) (*Cache, error) {
+	err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("error creating media cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "index.db"), 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening media cache: %w", err)
+	}
+
+	c := &Cache{
+		dir:     dir,
+		limit:   limit,
+		db:      db,
+		entries: map[string]*entry{},
+		touched: map[string]bool{},
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(urlsBucket)
+		if err != nil {
+			return err
+		}
+		blobs, err := tx.CreateBucketIfNotExists(blobsBucket)
+		if err != nil {
+			return err
+		}
+
+		return blobs.ForEach(func(k, v []byte) error {
+			var e entry
+			if json.Unmarshal(v, &e) != nil {
+				return nil
+			}
+			c.entries[string(k)] = &e
+			c.size += e.Size
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error reading media cache: %w", err)
+	}
+
+	// Leftovers of writes that were interrupted.
+	tmp, _ := os.ReadDir(filepath.Join(dir, "tmp"))
+	for _, file := range tmp {
+		os.Remove(filepath.Join(dir, "tmp", file.Name()))
+	}
+
+	return c, nil
+}
+
+// Close writes when the blobs were last used and closes the index.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.db.Update(c.writeTouched)
+	return c.db.Close()
+}
+
+func (c *Cache) path(hash string) string {
+	return filepath.Join(c.dir, hash[:2], hash)
+}
+
+// Lookup returns the blob fetched from url, if it is in the cache.
+func (c *Cache) Lookup(url string) (Blob, bool) {
+	var hash string
+	c.db.View(func(tx *bolt.Tx) error {
+		hash = string(tx.Bucket(urlsBucket).Get([]byte(url)))
+		return nil
+	})
+	if hash == "" {
+		return Blob{}, false
+	}
+	return c.Get(hash)
+}
+
+// Get returns the blob with the given hash, if it is in the cache.
+func (c *Cache) Get(hash string) (Blob, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[hash]
+	if !ok {
+		return Blob{}, false
+	}
+
+	path := c.path(hash)
+	if _, err := os.Stat(path); err != nil {
+		// Removed from under the cache.
+		c.size -= e.Size
+		delete(c.entries, hash)
+		c.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(blobsBucket).Delete([]byte(hash))
+		})
+		return Blob{}, false
+	}
+
+	e.Used = time.Now()
+	c.touched[hash] = true
+
+	return Blob{Hash: hash, Path: path, Size: e.Size, MIME: e.MIME}, true
+}
+
+// Put stores the content of r, fetched from url, and returns its blob.
+// Files larger than an eighth of the cache are not kept, as they would
+// push out too much else; ErrTooLarge is returned for them.
+func (c *Cache) Put(url string, r io.Reader, mime string) (Blob, error) {
+	tmp, err := os.CreateTemp(filepath.Join(c.dir, "tmp"), "blob-")
+	if err != nil {
+		return Blob{}, fmt.Errorf("error creating cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, c.limit/8+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Blob{}, fmt.Errorf("error writing cache file: %w", err)
+	}
+	if size > c.limit/8 {
+		return Blob{}, ErrTooLarge
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	path := c.path(sum)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, exists := c.entries[sum]
+	if !exists {
+		err = os.MkdirAll(filepath.Dir(path), 0o700)
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			return Blob{}, fmt.Errorf("error storing cache file: %w", err)
+		}
+
+		e = &entry{Size: size, MIME: mime}
+		c.entries[sum] = e
+		c.size += size
+	}
+	e.Used = time.Now()
+	c.touched[sum] = true
+
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(urlsBucket).Put([]byte(url), []byte(sum))
+		if err != nil {
+			return err
+		}
+		return c.writeTouched(tx)
+	})
+	if err != nil {
+		return Blob{}, fmt.Errorf("error indexing cache file: %w", err)
+	}
+
+	c.evict(sum)
+
+	return Blob{Hash: sum, Path: path, Size: size, MIME: e.MIME}, nil
+}
+
+// writeTouched writes the entries of the blobs used lately. c.mu must be
+// held.
+func (c *Cache) writeTouched(tx *bolt.Tx) error {
+	blobs := tx.Bucket(blobsBucket)
+	for hash := range c.touched {
+		e, ok := c.entries[hash]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		err = blobs.Put([]byte(hash), data)
+		if err != nil {
+			return err
+		}
+	}
+	clear(c.touched)
+	return nil
+}
+
+// evict removes the least recently used blobs, other than keep, until the
+// cache is back under its limit. c.mu must be held.
+func (c *Cache) evict(keep string) {
+	if c.size <= c.limit {
+		return
+	}
+
+	hashes := make([]string, 0, len(c.entries))
+	for hash := range c.entries {
+		if hash != keep {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return c.entries[hashes[i]].Used.Before(c.entries[hashes[j]].Used)
+	})
+
+	evicted := map[string]bool{}
+	for _, hash := range hashes {
+		if c.size <= c.limit {
+			break
+		}
+		os.Remove(c.path(hash))
+		c.size -= c.entries[hash].Size
+		delete(c.entries, hash)
+		delete(c.touched, hash)
+		evicted[hash] = true
+	}
+
+	c.db.Update(func(tx *bolt.Tx) error {
+		blobs := tx.Bucket(blobsBucket)
+		for hash := range evicted {
+			blobs.Delete([]byte(hash))
+		}
+
+		// The URLs of evicted blobs go too. Deleting while iterating
+		// skips keys, so they are collected first.
+		var stale [][]byte
+		urls := tx.Bucket(urlsBucket)
+		urls.ForEach(func(k, v []byte) error {
+			if evicted[string(v)] {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range stale {
+			urls.Delete(k)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,142 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openCache(t *testing.T, dir string, limit int64) *Cache {
+	t.Helper()
+	c, err := OpenCache(dir, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// content returns n bytes that differ for every seed.
+func content(seed, n int) []byte {
+	return bytes.Repeat([]byte{byte(seed)}, n)
+}
+
+func put(t *testing.T, c *Cache, url string, data []byte) Blob {
+	t.Helper()
+	blob, err := c.Put(url, bytes.NewReader(data), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+func TestCacheContentAddressing(t *testing.T) {
+	c := openCache(t, t.TempDir(), 1<<20)
+
+	data := []byte("an avatar")
+	first := put(t, c, "https://media.hudori.test/avatar.png", data)
+	second := put(t, c, "https://media.hudori.test/icon.png", data)
+
+	sum := sha256.Sum256(data)
+	if first.Hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("hash = %s, want the SHA-256 of the content", first.Hash)
+	}
+	if second != first {
+		t.Errorf("same content stored as %+v and %+v", first, second)
+	}
+	if c.size != int64(len(data)) {
+		t.Errorf("cache size = %d, want the content counted once", c.size)
+	}
+
+	stored, err := os.ReadFile(first.Path)
+	if err != nil || !bytes.Equal(stored, data) {
+		t.Errorf("stored %q, %v", stored, err)
+	}
+	for _, url := range []string{"https://media.hudori.test/avatar.png", "https://media.hudori.test/icon.png"} {
+		if blob, ok := c.Lookup(url); !ok || blob.Hash != first.Hash || blob.MIME != "image/png" {
+			t.Errorf("Lookup(%s) = %+v, %v", url, blob, ok)
+		}
+	}
+	if _, ok := c.Lookup("https://media.hudori.test/other.png"); ok {
+		t.Error("Lookup found a URL never stored")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	// Files of 100 bytes, 8 of which fit.
+	c := openCache(t, t.TempDir(), 800)
+
+	var blobs []Blob
+	for i := 0; i < 8; i++ {
+		blobs = append(blobs, put(t, c, fmt.Sprint("https://media.hudori.test/", i), content(i, 100)))
+	}
+	// The oldest file was used since, so the second goes first.
+	if _, ok := c.Get(blobs[0].Hash); !ok {
+		t.Fatal("first file missing before the cache is full")
+	}
+	blobs = append(blobs, put(t, c, "https://media.hudori.test/8", content(8, 100)))
+
+	if c.size != 800 || len(c.entries) != 8 {
+		t.Errorf("cache holds %d files of %d bytes, want 8 of 800", len(c.entries), c.size)
+	}
+	if _, ok := c.Lookup("https://media.hudori.test/1"); ok {
+		t.Error("least recently used file still cached")
+	}
+	if _, err := os.Stat(blobs[1].Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("evicted file still on disk: %v", err)
+	}
+	for _, i := range []int{0, 2, 8} {
+		if _, ok := c.Lookup(fmt.Sprint("https://media.hudori.test/", i)); !ok {
+			t.Errorf("file %d evicted", i)
+		}
+	}
+}
+
+func TestCacheTooLarge(t *testing.T) {
+	c := openCache(t, t.TempDir(), 800)
+
+	if _, err := c.Put("https://media.hudori.test/big", bytes.NewReader(content(1, 101)), ""); err != ErrTooLarge {
+		t.Fatalf("Put = %v, want ErrTooLarge", err)
+	}
+	if c.size != 0 || len(c.entries) != 0 {
+		t.Errorf("cache holds %d files of %d bytes after a refused file", len(c.entries), c.size)
+	}
+	tmp, _ := os.ReadDir(filepath.Join(c.dir, "tmp"))
+	if len(tmp) != 0 {
+		t.Errorf("%d temporary files left", len(tmp))
+	}
+
+	// An eighth of the cache is fine.
+	put(t, c, "https://media.hudori.test/fits", content(1, 100))
+}
+
+func TestCacheReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, err := OpenCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := put(t, c, "https://media.hudori.test/kept", []byte("kept"))
+	removed := put(t, c, "https://media.hudori.test/removed", []byte("removed"))
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(removed.Path)
+
+	c = openCache(t, dir, 1<<20)
+	if blob, ok := c.Lookup("https://media.hudori.test/kept"); !ok || blob != kept {
+		t.Errorf("Lookup after reopening = %+v, %v, want %+v", blob, ok, kept)
+	}
+	// A file removed from under the cache is forgotten.
+	if _, ok := c.Lookup("https://media.hudori.test/removed"); ok {
+		t.Error("Lookup found a removed file")
+	}
+	if c.size != kept.Size {
+		t.Errorf("cache size = %d, want %d", c.size, kept.Size)
+	}
+}
@@ -0,0 +1,496 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hudori-desktop/client"
+)
+
+const (
+	// maxDownloads is how many files are downloaded at once. The others
+	// wait for their turn.
+	maxDownloads = 3
+	// attempts is how many times a download is tried, resuming where the
+	// previous attempt stopped, before it is reported as failed.
+	attempts = 4
+)
+
+// partSuffix is added to the name of files while they are downloaded.
+const partSuffix = ".part"
+
+// Fetcher GETs a URL with the given headers. The body of the response is
+// closed by the caller.
+type Fetcher func(ctx context.Context, url string, header http.Header) (*http.Response, error)
+
+// Source returns how to fetch url: with fetch, on behalf of owner. owner
+// is empty for media anyone may see, which are cached once for every
+// account, and names the account whose credentials the fetch sends
+// otherwise, so that its media are not served to another. fetch is nil
+// for URLs media are not fetched from.
+type Source func(url string) (owner string, fetch Fetcher)
+
+// errForbidden is returned for URLs the source does not fetch.
+var errForbidden = errors.New("media are not fetched from this host")
+
+// cacheKey returns the key of the media cache url is stored under for
+// owner.
+func cacheKey(owner, url string) string {
+	if owner == "" {
+		return url
+	}
+	return owner + " " + url
+}
+
+// State is where a download stands.
+type State string
+
+const (
+	StateQueued      State = "queued"
+	StateDownloading State = "downloading"
+	StateDone        State = "done"
+	StateFailed      State = "failed"
+	StateCancelled   State = "cancelled"
+)
+
+// Download is a file being saved to disk.
+type Download struct {
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+	Path string `json:"path"`
+	// Size is 0 while it is not known.
+	Size     int64  `json:"size"`
+	Received int64  `json:"received"`
+	State    State  `json:"state"`
+	Error    string `json:"error,omitempty"`
+}
+
+type download struct {
+	Download
+	// key is the key of the file in the media cache, and fetch fetches it
+	// on behalf of the account that started the download.
+	key    string
+	fetch  Fetcher
+	cancel context.CancelFunc
+	// mime is the content type the server sent the file with.
+	mime string
+	// validator is the ETag or Last-Modified date of the file, so that a
+	// download is only resumed if the file did not change meanwhile.
+	validator string
+}
+
+// Manager downloads files in the background, at most maxDownloads at a
+// time, resuming them with Range requests after network errors. Files
+// small enough are also added to the media cache, and files already in it
+// are copied from there.
+type Manager struct {
+	source Source
+	cache  *Cache
+	emit   func(Download)
+	slots  chan struct{}
+
+	mu        sync.Mutex
+	downloads map[string]*download
+}
+
+// NewManager returns a manager fetching files as source tells and calling
+// emit whenever a download changes. cache may be nil.
+func NewManager(source Source, cache *Cache, emit func(Download)) *Manager {
+	return &Manager{
+		source:    source,
+		cache:     cache,
+		emit:      emit,
+		slots:     make(chan struct{}, maxDownloads),
+		downloads: map[string]*download{},
+	}
+}
+
+// Start downloads url to path.
+func (m *Manager) Start(url, path string) Download {
+	owner, fetch := m.source(url)
+	d := &download{
+		Download: Download{ID: client.NewID(), URL: url, Path: path, State: StateQueued},
+		key:      cacheKey(owner, url),
+		fetch:    fetch,
+	}
+
+	m.mu.Lock()
+	m.downloads[d.ID] = d
+	m.mu.Unlock()
+
+	m.start(d)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return d.Download
+}
+
+// Downloads returns the downloads of this run, oldest first.
+func (m *Manager) Downloads() []Download {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	downloads := make([]Download, 0, len(m.downloads))
+	for _, d := range m.downloads {
+		downloads = append(downloads, d.Download)
+	}
+	sort.Slice(downloads, func(i, j int) bool { return downloads[i].ID < downloads[j].ID })
+	return downloads
+}
+
+// Cancel stops a download and removes what was downloaded of it.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.downloads[id]
+	if !ok {
+		return fmt.Errorf("no download %q", id)
+	}
+	if d.State == StateQueued || d.State == StateDownloading {
+		d.cancel()
+	}
+	return nil
+}
+
+// Retry starts a failed download again, from where it stopped.
+func (m *Manager) Retry(id string) error {
+	m.mu.Lock()
+	d, ok := m.downloads[id]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no download %q", id)
+	}
+	if d.State != StateFailed && d.State != StateCancelled {
+		m.mu.Unlock()
+		return fmt.Errorf("download %q is %s", id, d.State)
+	}
+	d.State = StateQueued
+	d.Error = ""
+	m.mu.Unlock()
+
+	m.start(d)
+	return nil
+}
+
+func (m *Manager) start(d *download) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	d.cancel = cancel
+	m.mu.Unlock()
+
+	m.update(d, func() {})
+	go m.run(ctx, cancel, d)
+}
+
+// update changes d under the lock and reports it.
+func (m *Manager) update(d *download, change func()) {
+	m.mu.Lock()
+	change()
+	snapshot := d.Download
+	m.mu.Unlock()
+
+	if m.emit != nil {
+		m.emit(snapshot)
+	}
+}
+
+func (m *Manager) run(ctx context.Context, cancel context.CancelFunc, d *download) {
+	defer cancel()
+
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		m.update(d, func() { d.State = StateCancelled })
+		return
+	}
+
+	m.update(d, func() { d.State = StateDownloading })
+
+	err := errForbidden
+	if d.fetch != nil {
+		err = m.copyCached(d)
+		if errors.Is(err, errNotCached) {
+			err = m.download(ctx, d)
+		}
+	}
+
+	switch {
+	case err == nil:
+		m.update(d, func() {
+			d.State = StateDone
+			d.Size = max(d.Size, d.Received)
+			d.Received = d.Size
+		})
+	case ctx.Err() != nil:
+		os.Remove(d.Path + partSuffix)
+		m.update(d, func() {
+			d.State = StateCancelled
+			d.Received = 0
+		})
+	default:
+		// What was downloaded is kept for a retry.
+		m.update(d, func() {
+			d.State = StateFailed
+			d.Error = err.Error()
+		})
+	}
+}
+
+var errNotCached = errors.New("not cached")
+
+// copyCached copies the file from the media cache, if it is there.
+func (m *Manager) copyCached(d *download) error {
+	if m.cache == nil {
+		return errNotCached
+	}
+	blob, ok := m.cache.Lookup(d.key)
+	if !ok {
+		return errNotCached
+	}
+
+	src, err := os.Open(blob.Path)
+	if err != nil {
+		return errNotCached
+	}
+	defer src.Close()
+
+	m.update(d, func() { d.Size = blob.Size })
+	return writeFile(d.Path, src)
+}
+
+// download fetches the file, trying again after errors that may not
+// happen again, and moves it in place once it is complete.
+func (m *Manager) download(ctx context.Context, d *download) error {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(1<<(attempt-1)) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		err = m.fetchPart(ctx, d)
+		var status statusError
+		if err == nil || ctx.Err() != nil || (errors.As(err, &status) && !status.temporary()) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// The download is fine without the cache, so its errors are ignored.
+	part := d.Path + partSuffix
+	if m.cache != nil {
+		file, err := os.Open(part)
+		if err == nil {
+			m.mu.Lock()
+			mime := d.mime
+			m.mu.Unlock()
+			m.cache.Put(d.key, file, mime)
+			file.Close()
+		}
+	}
+
+	err = os.Rename(part, d.Path)
+	if err != nil {
+		return fmt.Errorf("error moving download in place: %w", err)
+	}
+	return nil
+}
+
+// statusError is returned when the server does not send the file.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e statusError) Error() string {
+	return "server answered " + e.status
+}
+
+// temporary tells whether asking again may work.
+func (e statusError) temporary() bool {
+	return e.code >= 500 || e.code == http.StatusTooManyRequests
+}
+
+// fetchPart downloads the file to its part file, asking only for what is
+// missing from it.
+func (m *Manager) fetchPart(ctx context.Context, d *download) error {
+	file, err := os.OpenFile(d.Path+partSuffix, os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	header := http.Header{}
+	m.mu.Lock()
+	validator := d.validator
+	m.mu.Unlock()
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if validator != "" {
+			header.Set("If-Range", validator)
+		}
+	}
+
+	resp, err := d.fetch(ctx, d.URL, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	size := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		start, total, ok := contentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			// Not the part asked for: start over.
+			return restart(file, errRestarted)
+		}
+		size = total
+
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// Either the file was complete already, or it changed.
+		_, total, ok := contentRange(resp.Header.Get("Content-Range"))
+		if ok && total == offset {
+			m.update(d, func() { d.Size, d.Received = total, total })
+			return nil
+		}
+		return restart(file, errRestarted)
+
+	case resp.StatusCode == http.StatusOK:
+		// The whole file, as the server ignored the range or the file
+		// changed since the previous attempt.
+		err = restart(file, nil)
+		if err != nil {
+			return err
+		}
+		offset = 0
+
+	default:
+		return statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		validator = etag
+	} else {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	m.update(d, func() {
+		d.mime = resp.Header.Get("Content-Type")
+		d.validator = validator
+		d.Received = offset
+		d.Size = max(size, 0)
+	})
+
+	w := &client.ProgressWriter{W: file, Report: func(n int64) {
+		m.update(d, func() { d.Received = offset + n })
+	}}
+	_, err = io.Copy(w, resp.Body)
+	w.Flush()
+	if err != nil {
+		return fmt.Errorf("error downloading file: %w", err)
+	}
+
+	if size > 0 && offset+w.N != size {
+		return fmt.Errorf("error downloading file: %w", io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// errRestarted is returned when a download has to start over, because the
+// file changed on the server since it was started.
+var errRestarted = errors.New("file changed on the server, starting over")
+
+// restart empties a part file so that the download starts from scratch,
+// and returns then.
+func restart(file *os.File, then error) error {
+	err := file.Truncate(0)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return fmt.Errorf("error resetting file: %w", err)
+	}
+	return then
+}
+
+// contentRange parses a Content-Range header, such as "bytes 0-99/1000" or
+// "bytes */1000". The total is -1 when the server does not know it.
+func contentRange(header string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	bounds, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		var err error
+		total, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+	}
+
+	if bounds == "*" {
+		return 0, total, true
+	}
+	first, _, found := strings.Cut(bounds, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// writeFile writes the content of r to path, through a part file so that
+// an incomplete file is never left under the final name.
+func writeFile(path string, r io.Reader) error {
+	part := path + partSuffix
+	file, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	err = os.Rename(part, path)
+	if err != nil {
+		return fmt.Errorf("error moving download in place: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fetch(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return http.DefaultClient.Do(req)
+}
+
+func newManager(cache *Cache) *Manager {
+	return NewManager(func(string) (string, Fetcher) { return "", fetch }, cache, nil)
+}
+
+// finish waits for a download to be over and returns it.
+func finish(t *testing.T, m *Manager, id string) Download {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, d := range m.Downloads() {
+			if d.ID == id && (d.State == StateDone || d.State == StateFailed || d.State == StateCancelled) {
+				return d
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("download %s still running", id)
+	return Download{}
+}
+
+// cutOff sends the first n bytes of data, announcing all of it, and
+// drops the connection.
+func cutOff(w http.ResponseWriter, data []byte, n int) {
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Write(data[:n])
+	w.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func TestDownloadResumes(t *testing.T) {
+	data := content(7, 1000)
+
+	var mu sync.Mutex
+	var ranges, validators []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		validators = append(validators, r.Header.Get("If-Range"))
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("Range") == "" {
+			cutOff(w, data, 400)
+		}
+		w.Header().Set("Content-Range", "bytes 400-999/1000")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[400:])
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	m := newManager(nil)
+	d := finish(t, m, m.Start(srv.URL, path).ID)
+	if d.State != StateDone || d.Size != 1000 || d.Received != 1000 {
+		t.Fatalf("download = %+v", d)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes, %v", len(got), err)
+	}
+	if fmt.Sprint(ranges) != "[ bytes=400-]" || validators[1] != `"v1"` {
+		t.Errorf("requests asked for ranges %q if %q", ranges, validators)
+	}
+	if _, err := os.Stat(path + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("part file left: %v", err)
+	}
+}
+
+func TestDownloadRestartsOnFullReply(t *testing.T) {
+	data := content(9, 1000)
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			cutOff(w, data, 400)
+		}
+		// The range is ignored.
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	m := newManager(nil)
+	d := finish(t, m, m.Start(srv.URL, path).ID)
+	if d.State != StateDone {
+		t.Fatalf("download = %+v", d)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("downloaded %d bytes, want the %d of the file only once", len(got), len(data))
+	}
+}
+
+func TestDownloadFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	m := newManager(nil)
+	d := finish(t, m, m.Start(srv.URL, path).ID)
+	if d.State != StateFailed || d.Error == "" {
+		t.Errorf("download = %+v, want it failed", d)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file of a failed download exists: %v", err)
+	}
+}
+
+func TestDownloadForbidden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	m := NewManager(func(string) (string, Fetcher) { return "", nil }, nil, nil)
+	d := finish(t, m, m.Start("https://example.com/file.bin", path).ID)
+	if d.State != StateFailed || d.Error != errForbidden.Error() {
+		t.Errorf("download = %+v, want it refused", d)
+	}
+}
+
+func TestDownloadLimit(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	active, most := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		most = max(most, active)
+		mu.Unlock()
+
+		<-release
+		w.Write([]byte("file"))
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := newManager(nil)
+	var ids []string
+	for i := 0; i < maxDownloads+2; i++ {
+		ids = append(ids, m.Start(fmt.Sprint(srv.URL, "/", i), filepath.Join(dir, fmt.Sprint(i))).ID)
+	}
+
+	// Wait for the first downloads to reach the server.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := active
+		mu.Unlock()
+		if n == maxDownloads {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d downloads started, want %d", n, maxDownloads)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	queued := 0
+	for _, d := range m.Downloads() {
+		if d.State == StateQueued {
+			queued++
+		}
+	}
+	if queued != 2 {
+		t.Errorf("%d downloads queued, want 2", queued)
+	}
+
+	close(release)
+	for _, id := range ids {
+		if d := finish(t, m, id); d.State != StateDone {
+			t.Errorf("download = %+v", d)
+		}
+	}
+	if most != maxDownloads {
+		t.Errorf("%d downloads at once, want at most %d", most, maxDownloads)
+	}
+}
+
+func TestDownloadFromCache(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("a document"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache := openCache(t, filepath.Join(dir, "cache"), 1<<20)
+	m := newManager(cache)
+
+	for _, name := range []string{"first.pdf", "second.pdf"} {
+		path := filepath.Join(dir, name)
+		if d := finish(t, m, m.Start(srv.URL, path).ID); d.State != StateDone {
+			t.Fatalf("download = %+v", d)
+		}
+		if got, _ := os.ReadFile(path); string(got) != "a document" {
+			t.Errorf("%s = %q", name, got)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("file fetched %d times, want once", requests)
+	}
+	if blob, ok := cache.Lookup(srv.URL); !ok || blob.MIME != "application/pdf" {
+		t.Errorf("cached blob = %+v, %v", blob, ok)
+	}
+}
+
+func TestContentRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		start, total int64
+		ok           bool
+	}{
+		{header: "bytes 0-99/1000", start: 0, total: 1000, ok: true},
+		{header: "bytes 400-999/1000", start: 400, total: 1000, ok: true},
+		{header: "bytes 400-999/*", start: 400, total: -1, ok: true},
+		{header: "bytes */1000", start: 0, total: 1000, ok: true},
+		{header: "items 0-9/10"},
+		{header: "bytes 0-99"},
+		{header: "bytes x-99/1000"},
+		{header: ""},
+	}
+
+	for _, tt := range tests {
+		start, total, ok := contentRange(tt.header)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("contentRange(%q) = %d, %d, %v, want %d, %d, %v", tt.header, start, total, ok, tt.start, tt.total, tt.ok)
+		}
+	}
+}
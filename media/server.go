@@ -0,0 +1,322 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"hudori-desktop/imaging"
+
+	"github.com/wailsapp/mimetype"
+)
+
+// Prefix is the path the webview loads media from. The rest of the path is
+// the URL of the file, base64url-encoded without padding, e.g.
+// /media/aHR0cHM6Ly9leGFtcGxlLmNvbS9hLnBuZw?w=64&h=64&fit=cover. URLs the
+// source does not fetch are refused, so that the webview cannot make the
+// app fetch any URL it likes.
+const Prefix = "/media/"
+
+// maxThumbnailSide bounds the w and h parameters.
+const maxThumbnailSide = 4096
+
+// Handler serves media to the webview from the cache, fetching them on a
+// miss with the credentials of the user, which never reach the webview.
+// The media fetched with credentials are cached for their account alone.
+// Pictures are resized when asked to with the w and h parameters, and fit
+// either contains them in that box, the default, or covers it.
+type Handler struct {
+	cache  *Cache
+	source Source
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// call is a fetch shared by the requests for the same file.
+type call struct {
+	done chan struct{}
+	blob Blob
+	err  error
+}
+
+// NewHandler returns a handler serving media from cache, which may be nil,
+// and fetching them as source tells.
+func NewHandler(cache *Cache, source Source) *Handler {
+	return &Handler{cache: cache, source: source, inflight: map[string]*call{}}
+}
+
+// Middleware serves the media paths and hands the other requests to next.
+func (h *Handler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, Prefix) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source, err := decodeURL(strings.TrimPrefix(r.URL.Path, Prefix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target, resize, err := thumbnailTarget(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	owner, fetch := h.source(source)
+	if fetch == nil {
+		http.Error(w, errForbidden.Error(), http.StatusForbidden)
+		return
+	}
+	key := cacheKey(owner, source)
+
+	blob, err := h.blob(r, key, source, fetch)
+	if errors.Is(err, ErrTooLarge) {
+		// Not worth caching, e.g. a video: it is streamed as it comes.
+		h.proxy(w, r, source, fetch)
+		return
+	}
+	var status statusError
+	if errors.As(err, &status) {
+		http.Error(w, status.Error(), status.code)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if resize {
+		thumbnail, err := h.thumbnail(key, blob, target)
+		if err == nil {
+			blob = thumbnail
+		}
+	}
+
+	serveBlob(w, r, blob)
+}
+
+// decodeURL returns the URL encoded in a media path.
+func decodeURL(key string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid media path: %w", err)
+	}
+	u, err := url.Parse(string(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("invalid media URL")
+	}
+	return u.String(), nil
+}
+
+// thumbnailTarget reads the w, h and fit parameters. resize is false when
+// neither w nor h is set.
+func thumbnailTarget(query url.Values) (target imaging.Target, resize bool, err error) {
+	side := func(key string) (int, error) {
+		value := query.Get(key)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxThumbnailSide {
+			return 0, fmt.Errorf("%s must be between 1 and %d", key, maxThumbnailSide)
+		}
+		return n, nil
+	}
+
+	target.Width, err = side("w")
+	if err != nil {
+		return target, false, err
+	}
+	target.Height, err = side("h")
+	if err != nil {
+		return target, false, err
+	}
+	if target.Width == 0 && target.Height == 0 {
+		return target, false, nil
+	}
+
+	switch query.Get("fit") {
+	case "", "contain":
+	case "cover":
+		target.Cover = target.Width > 0 && target.Height > 0
+	default:
+		return target, false, errors.New("fit must be contain or cover")
+	}
+
+	// A missing side does not bound the picture.
+	if target.Width == 0 {
+		target.Width = maxThumbnailSide
+	}
+	if target.Height == 0 {
+		target.Height = maxThumbnailSide
+	}
+	target.Animated = true
+
+	return target, true, nil
+}
+
+// blob returns the file at source, cached under key, from the cache,
+// fetching it on a miss. Requests for a file being fetched wait for that
+// fetch.
+func (h *Handler) blob(r *http.Request, key, source string, fetch Fetcher) (Blob, error) {
+	if h.cache == nil {
+		return Blob{}, ErrTooLarge
+	}
+	if blob, ok := h.cache.Lookup(key); ok {
+		return blob, nil
+	}
+
+	h.mu.Lock()
+	c, ok := h.inflight[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		h.inflight[key] = c
+	}
+	h.mu.Unlock()
+
+	if ok {
+		select {
+		case <-c.done:
+			return c.blob, c.err
+		case <-r.Context().Done():
+			return Blob{}, r.Context().Err()
+		}
+	}
+
+	// The fetch goes on if the request that started it goes away, as
+	// others may be waiting for it.
+	c.blob, c.err = h.fetchBlob(key, source, fetch)
+
+	h.mu.Lock()
+	delete(h.inflight, key)
+	h.mu.Unlock()
+	close(c.done)
+
+	return c.blob, c.err
+}
+
+// fetchTimeout bounds how long fetching a file for the cache takes.
+const fetchTimeout = 5 * time.Minute
+
+func (h *Handler) fetchBlob(key, source string, fetch Fetcher) (Blob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	resp, err := fetch(ctx, source, http.Header{})
+	if err != nil {
+		return Blob{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Blob{}, statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	if resp.ContentLength > h.cache.limit/8 {
+		return Blob{}, ErrTooLarge
+	}
+
+	return h.cache.Put(key, resp.Body, resp.Header.Get("Content-Type"))
+}
+
+// thumbnail returns blob, cached under key, resized to target, from the
+// cache when it was made before.
+func (h *Handler) thumbnail(key string, blob Blob, target imaging.Target) (Blob, error) {
+	key = fmt.Sprintf("%s#w=%d&h=%d&cover=%t", key, target.Width, target.Height, target.Cover)
+	if thumbnail, ok := h.cache.Lookup(key); ok {
+		return thumbnail, nil
+	}
+
+	mime, err := mimetype.DetectFile(blob.Path)
+	if err != nil {
+		return Blob{}, err
+	}
+	if !strings.HasPrefix(mime.String(), "image/") {
+		return Blob{}, imaging.ErrUnsupported
+	}
+
+	data, err := os.ReadFile(blob.Path)
+	if err != nil {
+		return Blob{}, err
+	}
+	result, err := imaging.Process(data, image.Rectangle{}, target, imaging.Auto)
+	if err != nil {
+		return Blob{}, err
+	}
+
+	return h.cache.Put(key, bytes.NewReader(result.Data), result.Format.MIME())
+}
+
+// proxy streams source to w without caching it, passing ranges along so
+// that videos can be sought.
+func (h *Handler) proxy(w http.ResponseWriter, r *http.Request, source string, fetch Fetcher) {
+	header := http.Header{}
+	for _, key := range []string{"Range", "If-Range"} {
+		if value := r.Header.Get(key); value != "" {
+			header.Set(key, value)
+		}
+	}
+
+	resp, err := fetch(r.Context(), source, header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, key := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if value := resp.Header.Get(key); value != "" {
+			w.Header().Set(key, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != "HEAD" {
+		io.Copy(w, resp.Body)
+	}
+}
+
+// serveBlob writes blob, answering range requests.
+func serveBlob(w http.ResponseWriter, r *http.Request, blob Blob) {
+	file, err := os.Open(blob.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	mime := blob.MIME
+	if mime == "" || strings.HasPrefix(mime, "application/octet-stream") {
+		if detected, err := mimetype.DetectReader(file); err == nil {
+			mime = detected.String()
+		}
+	}
+
+	w.Header().Set("Content-Type", mime)
+	// The same URL always holds the same file, as new avatars or banners
+	// get new URLs.
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", `"`+blob.Hash+`"`)
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
@@ -0,0 +1,285 @@
+package media
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mediaPath returns the path the webview loads url from.
+func mediaPath(url, query string) string {
+	path := Prefix + base64.RawURLEncoding.EncodeToString([]byte(url))
+	if query != "" {
+		path += "?" + query
+	}
+	return path
+}
+
+// origin serves files and counts the requests for each.
+type origin struct {
+	*httptest.Server
+	files    map[string][]byte
+	requests sync.Map
+	// hold, when set, holds the responses until it is closed.
+	hold chan struct{}
+}
+
+func newOrigin(t *testing.T, files map[string][]byte) *origin {
+	o := &origin{files: files}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := o.requests.LoadOrStore(r.URL.Path, new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		if o.hold != nil {
+			<-o.hold
+		}
+
+		data, ok := o.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+func (o *origin) count(path string) int {
+	n, ok := o.requests.Load(path)
+	if !ok {
+		return 0
+	}
+	return int(n.(*atomic.Int32).Load())
+}
+
+// newHandler returns a handler fetching from o alone, with a cache of
+// limit bytes, or no cache if limit is 0.
+func newHandler(t *testing.T, o *origin, limit int64) *Handler {
+	var cache *Cache
+	if limit > 0 {
+		cache = openCache(t, t.TempDir(), limit)
+	}
+	return NewHandler(cache, func(url string) (string, Fetcher) {
+		if !strings.HasPrefix(url, o.URL+"/") {
+			return "", nil
+		}
+		return "", fetch
+	})
+}
+
+func get(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerServesFromCache(t *testing.T) {
+	o := newOrigin(t, map[string][]byte{"/a.txt": []byte("some text")})
+	h := newHandler(t, o, 1<<20)
+
+	for i := 0; i < 2; i++ {
+		w := get(h, mediaPath(o.URL+"/a.txt", ""), nil)
+		if w.Code != http.StatusOK || w.Body.String() != "some text" {
+			t.Fatalf("request %d = %d %q", i, w.Code, w.Body)
+		}
+		if w.Header().Get("ETag") == "" || w.Header().Get("Cache-Control") == "" {
+			t.Errorf("response headers = %v", w.Header())
+		}
+	}
+	if n := o.count("/a.txt"); n != 1 {
+		t.Errorf("file fetched %d times, want once", n)
+	}
+
+	// Ranges are served from the cache too.
+	w := get(h, mediaPath(o.URL+"/a.txt", ""), http.Header{"Range": {"bytes=5-8"}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "text" {
+		t.Errorf("range request = %d %q", w.Code, w.Body)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	o := newOrigin(t, nil)
+	h := newHandler(t, o, 1<<20)
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "missing file", path: mediaPath(o.URL+"/missing.png", ""), want: http.StatusNotFound},
+		{name: "other host", path: mediaPath("https://example.com/a.png", ""), want: http.StatusForbidden},
+		{name: "not base64", path: Prefix + "not*base64", want: http.StatusBadRequest},
+		{name: "not http", path: mediaPath("file:///etc/passwd", ""), want: http.StatusBadRequest},
+		{name: "width too small", path: mediaPath(o.URL+"/a.png", "w=0"), want: http.StatusBadRequest},
+		{name: "height too large", path: mediaPath(o.URL+"/a.png", "h=5000"), want: http.StatusBadRequest},
+		{name: "unknown fit", path: mediaPath(o.URL+"/a.png", "w=10&fit=stretch"), want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := get(h, tt.path, nil); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+	if n := o.count("/a.png"); n != 0 {
+		t.Errorf("file fetched %d times for invalid requests", n)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, mediaPath(o.URL+"/a.png", ""), nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d", w.Code)
+	}
+}
+
+func TestHandlerSharesFetches(t *testing.T) {
+	o := newOrigin(t, map[string][]byte{"/a.txt": []byte("shared")})
+	o.hold = make(chan struct{})
+	h := newHandler(t, o, 1<<20)
+
+	const requests = 5
+	var wg sync.WaitGroup
+	bodies := make([]string, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = get(h, mediaPath(o.URL+"/a.txt", ""), nil).Body.String()
+		}(i)
+	}
+
+	// Let every request reach the handler before the file comes.
+	deadline := time.Now().Add(5 * time.Second)
+	for o.count("/a.txt") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(o.hold)
+	wg.Wait()
+
+	for i, body := range bodies {
+		if body != "shared" {
+			t.Errorf("response %d = %q", i, body)
+		}
+	}
+	if n := o.count("/a.txt"); n != 1 {
+		t.Errorf("file fetched %d times, want once", n)
+	}
+}
+
+func TestHandlerThumbnails(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 400, 200))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	o := newOrigin(t, map[string][]byte{
+		"/a.png":   buf.Bytes(),
+		"/not.png": []byte("not a picture"),
+	})
+	h := newHandler(t, o, 1<<20)
+
+	tests := []struct {
+		query         string
+		width, height int
+	}{
+		{query: "", width: 400, height: 200},
+		{query: "w=100", width: 100, height: 50},
+		{query: "h=100", width: 200, height: 100},
+		{query: "w=100&h=100", width: 100, height: 50},
+		{query: "w=64&h=64&fit=cover", width: 64, height: 64},
+		// A picture is never enlarged.
+		{query: "w=1000", width: 400, height: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := get(h, mediaPath(o.URL+"/a.png", tt.query), nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d", w.Code)
+			}
+			config, _, err := image.DecodeConfig(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Width != tt.width || config.Height != tt.height {
+				t.Errorf("picture of %dx%d, want %dx%d", config.Width, config.Height, tt.width, tt.height)
+			}
+		})
+	}
+	if n := o.count("/a.png"); n != 1 {
+		t.Errorf("picture fetched %d times, want once", n)
+	}
+
+	// Files that are not pictures are served as they are.
+	w := get(h, mediaPath(o.URL+"/not.png", "w=10"), nil)
+	if w.Code != http.StatusOK || w.Body.String() != "not a picture" {
+		t.Errorf("file = %d %q", w.Code, w.Body)
+	}
+}
+
+func TestHandlerProxiesLargeFiles(t *testing.T) {
+	video := make([]byte, 1000)
+	for i := range video {
+		video[i] = byte(i)
+	}
+	o := newOrigin(t, map[string][]byte{"/video.mp4": video})
+
+	for name, limit := range map[string]int64{"too large": 800, "no cache": 0} {
+		t.Run(name, func(t *testing.T) {
+			h := newHandler(t, o, limit)
+
+			w := get(h, mediaPath(o.URL+"/video.mp4", ""), nil)
+			if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), video) {
+				t.Fatalf("response = %d of %d bytes", w.Code, w.Body.Len())
+			}
+
+			// Ranges go to the origin, so that videos can be sought.
+			w = get(h, mediaPath(o.URL+"/video.mp4", ""), http.Header{"Range": {"bytes=100-199"}})
+			body, _ := io.ReadAll(w.Body)
+			if w.Code != http.StatusPartialContent || !bytes.Equal(body, video[100:200]) {
+				t.Errorf("range response = %d of %d bytes", w.Code, len(body))
+			}
+			if got := w.Header().Get("Content-Range"); got != "bytes 100-199/1000" {
+				t.Errorf("Content-Range = %q", got)
+			}
+
+			if h.cache != nil && len(h.cache.entries) != 0 {
+				t.Errorf("%d files cached", len(h.cache.entries))
+			}
+		})
+	}
+}
+
+func TestHandlerMiddleware(t *testing.T) {
+	o := newOrigin(t, map[string][]byte{"/a.txt": []byte("media")})
+	h := newHandler(t, o, 1<<20)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("asset"))
+	})
+
+	for path, want := range map[string]string{
+		mediaPath(o.URL+"/a.txt", ""): "media",
+		"/index.html":                 "asset",
+	} {
+		if got := get(h.Middleware(next), path, nil).Body.String(); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+}
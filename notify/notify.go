@@ -0,0 +1,291 @@
+package notify
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsService   = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+
+	appName = "hudori-desktop"
+
+	// DefaultAction is the action invoked when the notification itself is
+	// clicked.
+	DefaultAction = "default"
+	// ReplyAction is invoked with the text typed in the notification, on
+	// servers that support inline replies.
+	ReplyAction = "inline-reply"
+
+	// stacked is how many bodies a notification shows when several were
+	// grouped in it.
+	stacked = 3
+)
+
+// Action is a button of a notification.
+type Action struct {
+	Key   string
+	Label string
+}
+
+// Notification is what is shown to the user. Notifications with the same
+// Tag are grouped: a new one replaces the one shown, which then lists the
+// latest bodies and how many there were.
+type Notification struct {
+	Tag     string
+	Summary string
+	Body    string
+	// Image is the path of a picture shown with the notification, such as
+	// the avatar of the sender.
+	Image    string
+	Category string
+	Actions  []Action
+	// Reply offers to answer from the notification, where the server
+	// supports it.
+	Reply bool
+	// Data is handed back with the actions taken on the notification.
+	Data interface{}
+}
+
+// Invocation is an action the user took on a notification. Text is what
+// was typed with ReplyAction.
+type Invocation struct {
+	Tag    string
+	Action string
+	Text   string
+	Data   interface{}
+}
+
+// group is a notification on screen and what was grouped in it.
+type group struct {
+	id     uint32
+	count  int
+	bodies []string
+	data   interface{}
+}
+
+// Notifier shows desktop notifications through the freedesktop
+// Notifications D-Bus API and reports the actions taken on them.
+type Notifier struct {
+	conn    *dbus.Conn
+	server  dbus.BusObject
+	handle  func(Invocation)
+	signals chan *dbus.Signal
+
+	actions bool
+	reply   bool
+	markup  bool
+
+	mu     sync.Mutex
+	groups map[string]*group
+	tags   map[uint32]string
+}
+
+// Open connects to the notification server of the session. handle is
+// called with the actions taken on notifications, from a goroutine of the
+// notifier.
+func Open(handle func(Invocation)) (*Notifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the session bus: %w", err)
+	}
+
+	n := &Notifier{
+		conn:    conn,
+		server:  conn.Object(notificationsService, notificationsPath),
+		handle:  handle,
+		signals: make(chan *dbus.Signal, 16),
+		groups:  map[string]*group{},
+		tags:    map[uint32]string{},
+	}
+
+	var capabilities []string
+	err = n.server.Call(notificationsInterface+".GetCapabilities", 0).Store(&capabilities)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error reaching the notification server: %w", err)
+	}
+	for _, capability := range capabilities {
+		switch capability {
+		case "actions":
+			n.actions = true
+		case "inline-reply":
+			n.reply = true
+		case "body-markup":
+			n.markup = true
+		}
+	}
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(notificationsPath),
+		dbus.WithMatchInterface(notificationsInterface),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error watching notifications: %w", err)
+	}
+	conn.Signal(n.signals)
+	go n.listen()
+
+	return n, nil
+}
+
+// Close withdraws the notifications shown and disconnects.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	for _, g := range n.groups {
+		n.server.Call(notificationsInterface+".CloseNotification", 0, g.id)
+	}
+	clear(n.groups)
+	n.mu.Unlock()
+
+	n.conn.Close()
+}
+
+// CanReply reports whether notifications can be answered inline.
+func (n *Notifier) CanReply() bool {
+	return n.actions && n.reply
+}
+
+// Show shows a notification, replacing the one with the same tag.
+func (n *Notifier) Show(notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	g, ok := n.groups[notification.Tag]
+	if !ok {
+		g = &group{}
+	}
+	g.count++
+	g.data = notification.Data
+	g.bodies = append(g.bodies, notification.Body)
+	if len(g.bodies) > stacked {
+		g.bodies = g.bodies[len(g.bodies)-stacked:]
+	}
+
+	summary := notification.Summary
+	if g.count > 1 {
+		summary = fmt.Sprintf("%s (%d)", summary, g.count)
+	}
+	bodies := g.bodies
+	if n.markup {
+		bodies = make([]string, len(g.bodies))
+		for i, body := range g.bodies {
+			bodies[i] = html.EscapeString(body)
+		}
+	}
+
+	var actions []string
+	if n.actions {
+		actions = append(actions, DefaultAction, "Open")
+		if notification.Reply && n.reply {
+			actions = append(actions, ReplyAction, "Reply")
+		}
+		for _, action := range notification.Actions {
+			actions = append(actions, action.Key, action.Label)
+		}
+	}
+
+	hints := map[string]dbus.Variant{
+		"desktop-entry": dbus.MakeVariant(appName),
+		"urgency":       dbus.MakeVariant(byte(1)),
+	}
+	if notification.Category != "" {
+		hints["category"] = dbus.MakeVariant(notification.Category)
+	}
+	if notification.Image != "" {
+		hints["image-path"] = dbus.MakeVariant(notification.Image)
+	}
+
+	var id uint32
+	err := n.server.Call(notificationsInterface+".Notify", 0,
+		appName, g.id, "", summary, strings.Join(bodies, "\n"), actions, hints, int32(-1),
+	).Store(&id)
+	if err != nil {
+		return fmt.Errorf("error showing notification: %w", err)
+	}
+
+	if g.id != id {
+		delete(n.tags, g.id)
+	}
+	g.id = id
+	n.groups[notification.Tag] = g
+	n.tags[id] = notification.Tag
+
+	return nil
+}
+
+// Withdraw closes the notification with the given tag, if one is shown.
+func (n *Notifier) Withdraw(tag string) {
+	n.mu.Lock()
+	g, ok := n.groups[tag]
+	if ok {
+		delete(n.groups, tag)
+		delete(n.tags, g.id)
+	}
+	n.mu.Unlock()
+
+	if ok {
+		n.server.Call(notificationsInterface+".CloseNotification", 0, g.id)
+	}
+}
+
+// listen routes the signals of the notification server until the
+// connection is closed.
+func (n *Notifier) listen() {
+	for signal := range n.signals {
+		if signal.Path != notificationsPath || len(signal.Body) < 2 {
+			continue
+		}
+		id, _ := signal.Body[0].(uint32)
+
+		switch signal.Name {
+		case notificationsInterface + ".ActionInvoked":
+			action, _ := signal.Body[1].(string)
+			n.invoke(id, action, "")
+		case notificationsInterface + ".NotificationReplied":
+			text, _ := signal.Body[1].(string)
+			n.invoke(id, ReplyAction, text)
+		case notificationsInterface + ".NotificationClosed":
+			n.forget(id)
+		}
+	}
+}
+
+func (n *Notifier) invoke(id uint32, action, text string) {
+	n.mu.Lock()
+	tag, ok := n.tags[id]
+	var data interface{}
+	if g := n.groups[tag]; ok && g != nil {
+		data = g.data
+	} else {
+		ok = false
+	}
+	n.mu.Unlock()
+
+	if ok {
+		n.handle(Invocation{Tag: tag, Action: action, Text: text, Data: data})
+	}
+}
+
+// forget drops a notification that was closed, so that the next one with
+// its tag starts a new group.
+func (n *Notifier) forget(id uint32) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	tag, ok := n.tags[id]
+	if !ok {
+		return
+	}
+	delete(n.tags, id)
+	if g := n.groups[tag]; g != nil && g.id == id {
+		delete(n.groups, tag)
+	}
+}
@@ -0,0 +1,526 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"hudori-desktop/client"
+)
+
+// The states of an entry, sent with every update.
+const (
+	StateQueued  = "queued"
+	StateSending = "sending"
+	StateSent    = "sent"
+	StateFailed  = "failed"
+	// StateCancelled is sent once, when the user cancels a message.
+	StateCancelled = "cancelled"
+)
+
+const (
+	minBackoff = 2 * time.Second
+	maxBackoff = 5 * time.Minute
+
+	// maxAttempts is the number of failed attempts after which an entry
+	// is marked failed and waits for the user to retry it.
+	maxAttempts = 10
+)
+
+// Entry is a message waiting to be sent. Its attachments are kept on disk
+// next to it, streamed from there when it is sent, and only their names
+// are held in memory.
+type Entry struct {
+	ID        string            `json:"id"`
+	Message   client.NewMessage `json:"message"`
+	Files     []string          `json:"files"`
+	State     string            `json:"state"`
+	Attempts  int               `json:"attempts"`
+	Error     string            `json:"error,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	retryAt time.Time
+}
+
+// SendFunc sends one message, reporting the progress of its attachments to
+// progress. An error means the server could not be reached; a result with
+// an error status means it refused the message.
+type SendFunc func(ctx context.Context, msg client.NewMessage, files []client.File, progress func(client.UploadProgress)) (client.Result, error)
+
+// Outbox is a durable queue of messages for one account. Messages are sent
+// in the order they were queued, and those that cannot be sent yet are
+// retried with backoff, across restarts.
+type Outbox struct {
+	dir      string
+	send     SendFunc
+	emit     func(Entry)
+	progress func(client.UploadProgress)
+
+	mu      sync.Mutex
+	entries []*Entry
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	closed  bool
+
+	// sending is the entry being sent, and stop cancels its upload.
+	sending *Entry
+	stop    context.CancelFunc
+}
+
+// New returns the outbox stored in dir, with the entries a previous run
+// left behind. Every change of an entry is passed to emit, and the
+// progress of its upload, tagged with its id, to progress.
+func New(dir string, send SendFunc, emit func(Entry), progress func(client.UploadProgress)) (*Outbox, error) {
+	o := &Outbox{
+		dir:      dir,
+		send:     send,
+		emit:     emit,
+		progress: progress,
+		wake:     make(chan struct{}, 1),
+	}
+
+	err := o.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+func (o *Outbox) load() error {
+	paths, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("error listing outbox: %w", err)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading outbox: %w", err)
+		}
+
+		var e Entry
+		err = json.Unmarshal(data, &e)
+		if err != nil {
+			return fmt.Errorf("error parsing outbox entry %s: %w", path, err)
+		}
+
+		// An entry that was being sent when the app stopped may or may
+		// not have reached the server; sending it again is the lesser
+		// evil.
+		if e.State == StateSending {
+			e.State = StateQueued
+		}
+		o.entries = append(o.entries, &e)
+	}
+
+	sort.Slice(o.entries, func(i, j int) bool {
+		return o.entries[i].ID < o.entries[j].ID
+	})
+
+	return nil
+}
+
+// Enqueue stores a message and its attachments, then queues it.
+// Attachments read from a path are copied into the outbox, so that what is
+// sent is the file as it was queued, whatever becomes of it meanwhile.
+func (o *Outbox) Enqueue(msg client.NewMessage, files []client.File) (Entry, error) {
+	e := &Entry{
+		ID:        client.NewID(),
+		Message:   msg,
+		State:     StateQueued,
+		CreatedAt: time.Now(),
+	}
+
+	err := os.MkdirAll(o.dir, 0o700)
+	if err != nil {
+		return Entry{}, fmt.Errorf("error creating outbox directory: %w", err)
+	}
+
+	for i, file := range files {
+		err = o.store(o.attachment(e.ID, i), file)
+		if err != nil {
+			os.Remove(o.attachment(e.ID, i))
+			o.remove(e)
+			return Entry{}, fmt.Errorf("error storing attachment: %w", err)
+		}
+		e.Files = append(e.Files, file.Name)
+	}
+
+	err = o.write(e)
+	if err != nil {
+		o.remove(e)
+		return Entry{}, err
+	}
+
+	o.mu.Lock()
+	o.entries = append(o.entries, e)
+	snapshot := *e
+	o.mu.Unlock()
+
+	o.emit(snapshot)
+	o.Wake()
+
+	return snapshot, nil
+}
+
+// Entries returns the messages that are not sent yet.
+func (o *Outbox) Entries() []Entry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries := make([]Entry, 0, len(o.entries))
+	for _, e := range o.entries {
+		entries = append(entries, *e)
+	}
+
+	return entries
+}
+
+// Retry queues a failed message again.
+func (o *Outbox) Retry(id string) error {
+	o.mu.Lock()
+	e := o.find(id)
+	if e == nil || e.State != StateFailed {
+		o.mu.Unlock()
+		return fmt.Errorf("no failed message %q", id)
+	}
+	e.State = StateQueued
+	e.Attempts = 0
+	e.Error = ""
+	e.retryAt = time.Time{}
+	snapshot := *e
+	o.mu.Unlock()
+
+	err := o.write(&snapshot)
+	if err != nil {
+		return err
+	}
+
+	o.emit(snapshot)
+	o.Wake()
+
+	return nil
+}
+
+// Discard drops a message that is not being sent.
+func (o *Outbox) Discard(id string) error {
+	o.mu.Lock()
+	e := o.find(id)
+	if e == nil || e.State == StateSending {
+		o.mu.Unlock()
+		return fmt.Errorf("no pending message %q", id)
+	}
+	o.drop(e)
+	o.mu.Unlock()
+
+	o.remove(e)
+
+	return nil
+}
+
+// Cancel drops a message, stopping its upload if it is being sent.
+func (o *Outbox) Cancel(id string) error {
+	o.mu.Lock()
+	e := o.find(id)
+	if e == nil {
+		o.mu.Unlock()
+		return fmt.Errorf("no pending message %q", id)
+	}
+	o.drop(e)
+	sending := e == o.sending
+	if sending {
+		o.stop()
+	}
+	e.State = StateCancelled
+	snapshot := *e
+	o.mu.Unlock()
+
+	// The attachments of a message being sent are still open; they are
+	// removed once the upload has stopped.
+	if !sending {
+		o.remove(e)
+	}
+	o.emit(snapshot)
+
+	return nil
+}
+
+// Clear drops every message, e.g. when the account signs out.
+func (o *Outbox) Clear() error {
+	o.mu.Lock()
+	o.entries = nil
+	o.mu.Unlock()
+
+	err := os.RemoveAll(o.dir)
+	if err != nil {
+		return fmt.Errorf("error clearing outbox: %w", err)
+	}
+
+	return nil
+}
+
+// Wake makes the outbox try the next message right away instead of
+// waiting for its backoff, e.g. once the connection is back.
+func (o *Outbox) Wake() {
+	o.mu.Lock()
+	for _, e := range o.entries {
+		e.retryAt = time.Time{}
+	}
+	o.mu.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the queued messages until ctx is done or Close is called.
+func (o *Outbox) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return
+	}
+	o.cancel = cancel
+	o.mu.Unlock()
+
+	for {
+		e, wait := o.next()
+
+		var timer <-chan time.Time
+		if e != nil && wait > 0 {
+			timer = time.After(wait)
+		}
+		if e == nil || wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.wake:
+			case <-timer:
+			}
+			continue
+		}
+
+		o.deliver(ctx, e)
+	}
+}
+
+// Close stops sending. The queued messages stay on disk.
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.closed = true
+	if o.cancel != nil {
+		o.cancel()
+	}
+}
+
+// next returns the first queued message and how long to wait before it
+// can be sent. Later messages wait behind it so that the order is kept.
+func (o *Outbox) next() (*Entry, time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range o.entries {
+		if e.State != StateQueued {
+			continue
+		}
+		return e, time.Until(e.retryAt)
+	}
+
+	return nil, 0
+}
+
+func (o *Outbox) deliver(ctx context.Context, e *Entry) {
+	files, err := o.attachments(e)
+	if err != nil {
+		o.update(e, func(e *Entry) {
+			e.State = StateFailed
+			e.Error = err.Error()
+		})
+		return
+	}
+
+	o.update(e, func(e *Entry) {
+		e.State = StateSending
+	})
+
+	upload, stop := context.WithCancel(ctx)
+	o.mu.Lock()
+	o.sending, o.stop = e, stop
+	o.mu.Unlock()
+
+	resp, err := o.send(upload, e.Message, files, func(p client.UploadProgress) {
+		p.ID = e.ID
+		o.progress(p)
+	})
+	stop()
+
+	o.mu.Lock()
+	o.sending, o.stop = nil, nil
+	cancelled := o.find(e.ID) == nil
+	o.mu.Unlock()
+
+	if cancelled && (err != nil || resp.Status >= 300) {
+		o.remove(e)
+		return
+	}
+	if ctx.Err() != nil {
+		o.update(e, func(e *Entry) {
+			e.State = StateQueued
+		})
+		return
+	}
+
+	switch {
+	case err == nil && resp.Status < 300:
+		o.mu.Lock()
+		o.drop(e)
+		e.State = StateSent
+		snapshot := *e
+		o.mu.Unlock()
+
+		o.remove(e)
+		o.emit(snapshot)
+	case err == nil && !transient(resp.Status):
+		o.update(e, func(e *Entry) {
+			e.State = StateFailed
+			e.Error = resp.Message
+		})
+	default:
+		if err == nil {
+			err = errors.New(resp.Message)
+		}
+		o.update(e, func(e *Entry) {
+			e.Attempts++
+			e.Error = err.Error()
+			e.State = StateQueued
+			if e.Attempts >= maxAttempts {
+				e.State = StateFailed
+			}
+			e.retryAt = time.Now().Add(client.Backoff(e.Attempts, minBackoff, maxBackoff))
+		})
+	}
+}
+
+// transient reports whether a request refused with status may succeed if
+// it is sent again later.
+func transient(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+}
+
+// update changes an entry, saves it and tells the frontend.
+func (o *Outbox) update(e *Entry, change func(e *Entry)) {
+	o.mu.Lock()
+	change(e)
+	snapshot := *e
+	o.mu.Unlock()
+
+	err := o.write(&snapshot)
+	if err != nil {
+		snapshot.Error = err.Error()
+	}
+
+	o.emit(snapshot)
+}
+
+func (o *Outbox) find(id string) *Entry {
+	for _, e := range o.entries {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// drop removes e from the queue. o.mu must be held.
+func (o *Outbox) drop(e *Entry) {
+	for i, other := range o.entries {
+		if other == e {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *Outbox) write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error marshaling outbox entry: %w", err)
+	}
+
+	path := filepath.Join(o.dir, e.ID+".json")
+	err = os.WriteFile(path+".tmp", data, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return fmt.Errorf("error writing outbox entry: %w", err)
+	}
+
+	return nil
+}
+
+func (o *Outbox) remove(e *Entry) {
+	os.Remove(filepath.Join(o.dir, e.ID+".json"))
+	for i := range e.Files {
+		os.Remove(o.attachment(e.ID, i))
+	}
+}
+
+func (o *Outbox) attachment(id string, i int) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%s.%d", id, i))
+}
+
+func (o *Outbox) attachments(e *Entry) ([]client.File, error) {
+	var files []client.File
+	for i, name := range e.Files {
+		path := o.attachment(e.ID, i)
+		_, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading attachment %s: %w", name, err)
+		}
+		files = append(files, client.File{Name: name, Path: path})
+	}
+	return files, nil
+}
+
+// store writes the content of file to path. A file of the user is copied
+// rather than linked: a link would share its later edits, its permissions
+// and its owner.
+func (o *Outbox) store(path string, file client.File) error {
+	if file.Path == "" {
+		return os.WriteFile(path, file.Data, 0o600)
+	}
+
+	src, err := os.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package session
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretsService    = "org.freedesktop.secrets"
+	secretsPath       = dbus.ObjectPath("/org/freedesktop/secrets")
+	defaultCollection = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+	noPrompt          = dbus.ObjectPath("/")
+
+	promptTimeout = 2 * time.Minute
+)
+
+var errNoKey = errors.New("no key in the keyring")
+
+// secret is the Secret structure of the Secret Service API.
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// keyring stores the session encryption key in the desktop keyring
+// through the freedesktop Secret Service D-Bus API.
+type keyring struct {
+	conn    *dbus.Conn
+	service dbus.BusObject
+	session dbus.ObjectPath
+}
+
+var keyAttributes = map[string]string{
+	"application": "hudori-desktop",
+	"type":        "session-key",
+}
+
+func openKeyring() (*keyring, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the session bus: %w", err)
+	}
+
+	k := &keyring{
+		conn:    conn,
+		service: conn.Object(secretsService, secretsPath),
+	}
+
+	// The key never leaves the local bus, so the plain algorithm is enough.
+	var output dbus.Variant
+	err = k.service.Call("org.freedesktop.Secret.Service.OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &k.session)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening secret service session: %w", err)
+	}
+
+	return k, nil
+}
+
+func (k *keyring) Close() {
+	k.conn.Object(secretsService, k.session).Call("org.freedesktop.Secret.Session.Close", 0)
+	k.conn.Close()
+}
+
+// key returns the stored key, generating and storing a new one if create
+// is set and none exists yet.
+func (k *keyring) key(create bool) ([]byte, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := k.service.Call("org.freedesktop.Secret.Service.SearchItems", 0, keyAttributes).Store(&unlocked, &locked)
+	if err != nil {
+		return nil, fmt.Errorf("error searching the keyring: %w", err)
+	}
+
+	if len(unlocked) == 0 && len(locked) > 0 {
+		unlocked, err = k.unlock(locked)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(unlocked) > 0 {
+		var s secret
+		err = k.conn.Object(secretsService, unlocked[0]).Call("org.freedesktop.Secret.Item.GetSecret", 0, k.session).Store(&s)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key from the keyring: %w", err)
+		}
+		if len(s.Value) == keySize {
+			return s.Value, nil
+		}
+	}
+
+	if !create {
+		return nil, errNoKey
+	}
+
+	return k.create()
+}
+
+func (k *keyring) create() ([]byte, error) {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("error generating key: %w", err)
+	}
+
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant("Hudori session key"),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(keyAttributes),
+	}
+	s := secret{
+		Session:     k.session,
+		Value:       key,
+		ContentType: "application/octet-stream",
+	}
+
+	var item, prompt dbus.ObjectPath
+	collection := k.conn.Object(secretsService, defaultCollection)
+	err = collection.Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, s, true).Store(&item, &prompt)
+	if err != nil {
+		return nil, fmt.Errorf("error storing key in the keyring: %w", err)
+	}
+
+	if prompt != noPrompt {
+		_, err = k.prompt(prompt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func (k *keyring) unlock(items []dbus.ObjectPath) ([]dbus.ObjectPath, error) {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := k.service.Call("org.freedesktop.Secret.Service.Unlock", 0, items).Store(&unlocked, &prompt)
+	if err != nil {
+		return nil, fmt.Errorf("error unlocking the keyring: %w", err)
+	}
+
+	if prompt == noPrompt {
+		return unlocked, nil
+	}
+
+	result, err := k.prompt(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, _ := result.Value().([]dbus.ObjectPath)
+	return paths, nil
+}
+
+// prompt shows a keyring prompt to the user and waits for its outcome.
+func (k *keyring) prompt(path dbus.ObjectPath) (dbus.Variant, error) {
+	signals := make(chan *dbus.Signal, 1)
+	k.conn.Signal(signals)
+	defer k.conn.RemoveSignal(signals)
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface("org.freedesktop.Secret.Prompt"),
+		dbus.WithMatchMember("Completed"),
+	}
+	err := k.conn.AddMatchSignal(match...)
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("error watching keyring prompt: %w", err)
+	}
+	defer k.conn.RemoveMatchSignal(match...)
+
+	err = k.conn.Object(secretsService, path).Call("org.freedesktop.Secret.Prompt.Prompt", 0, "").Err
+	if err != nil {
+		return dbus.Variant{}, fmt.Errorf("error showing keyring prompt: %w", err)
+	}
+
+	timeout := time.After(promptTimeout)
+	for {
+		select {
+		case signal, ok := <-signals:
+			if !ok {
+				return dbus.Variant{}, errors.New("session bus closed")
+			}
+			if signal.Path != path || len(signal.Body) < 2 {
+				continue
+			}
+			dismissed, _ := signal.Body[0].(bool)
+			if dismissed {
+				return dbus.Variant{}, errors.New("keyring prompt dismissed")
+			}
+			result, _ := signal.Body[1].(dbus.Variant)
+			return result, nil
+		case <-timeout:
+			return dbus.Variant{}, errors.New("keyring prompt timed out")
+		}
+	}
+}
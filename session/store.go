@@ -0,0 +1,304 @@
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"hudori-desktop/client"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv names the environment variable holding the passphrase the
+// key is derived from when no keyring is available. Without it, the user
+// is asked for one, see SetPassphrase.
+const PassphraseEnv = "HUDORI_SESSION_PASSPHRASE"
+
+const (
+	keySize  = 32
+	saltSize = 16
+
+	methodKeyring    byte = 1
+	methodPassphrase byte = 2
+)
+
+var magic = []byte("HUDS\x01")
+
+// ErrNoSession is returned by Load when nothing has been saved.
+var ErrNoSession = errors.New("no saved session")
+
+// ErrPassphrase is returned when the sessions are, or are to be, protected
+// by a passphrase that was not given, or is wrong.
+var ErrPassphrase = errors.New("session passphrase missing or wrong")
+
+// Session is what is needed to resume a signed-in user after a restart.
+type Session struct {
+	Profile   string `json:"profile"`
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	// RefreshToken renews the session once it expires, if the backend
+	// gave one.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Cookies is the cookie jar of the session. Sessions saved before it
+	// was kept only have the session cookie, as SessionID.
+	Cookies []client.Cookie `json:"cookies,omitempty"`
+}
+
+// State is every signed-in account and which one was in use.
+type State struct {
+	Active   string    `json:"active"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Store keeps the State in an encrypted file. The key comes from the
+// desktop keyring or, when there is none, is derived from a passphrase.
+// The keyring is only asked once, as it may prompt the user to unlock it:
+// the key it gives is kept for the next saves, and so is its absence.
+type Store struct {
+	path string
+
+	mu         sync.Mutex
+	passphrase string
+	// method and key are the protection of the file once known, with the
+	// salt the key was derived with for a passphrase.
+	method byte
+	key    []byte
+	salt   []byte
+	// noKeyring is the error of a keyring that could not be used.
+	noKeyring error
+}
+
+// DefaultDir returns $XDG_DATA_HOME/hudori.
+func DefaultDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "hudori"
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "hudori")
+}
+
+func NewStore(dir, passphrase string) *Store {
+	return &Store{
+		path:       filepath.Join(dir, "session"),
+		passphrase: passphrase,
+	}
+}
+
+// SetPassphrase sets the passphrase protecting the sessions when there is
+// no keyring.
+func (s *Store) SetPassphrase(passphrase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.passphrase = passphrase
+	if s.method == methodPassphrase {
+		s.method, s.key, s.salt = 0, nil, nil
+	}
+}
+
+// saveKey sets the key to save the sessions with, asking the keyring
+// for it the first time. s.mu must be held.
+func (s *Store) saveKey() error {
+	if s.key != nil {
+		return nil
+	}
+
+	if s.noKeyring == nil {
+		key, err := keyringKey(true)
+		if err == nil {
+			s.method, s.key, s.salt = methodKeyring, key, make([]byte, saltSize)
+			return nil
+		}
+		s.noKeyring = err
+	}
+
+	if s.passphrase == "" {
+		return fmt.Errorf("no keyring to protect the session (%v): %w", s.noKeyring, ErrPassphrase)
+	}
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return fmt.Errorf("error generating salt: %w", err)
+	}
+	key, err := deriveKey(s.passphrase, salt)
+	if err != nil {
+		return err
+	}
+	s.method, s.key, s.salt = methodPassphrase, key, salt
+	return nil
+}
+
+func (s *Store) Save(state State) error {
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error marshaling session: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.saveKey()
+	if err != nil {
+		return err
+	}
+	key := s.key
+
+	header := append(append([]byte{}, magic...), s.method)
+	header = append(header, s.salt...)
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	data := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+gcm.Overhead())
+	data = append(data, header...)
+	data = append(data, nonce...)
+	data = gcm.Seal(data, nonce, plaintext, header)
+
+	err = os.MkdirAll(filepath.Dir(s.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("error creating data directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, data, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing session: %w", err)
+	}
+
+	err = os.Rename(tmp, s.path)
+	if err != nil {
+		return fmt.Errorf("error writing session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) Load() (State, error) {
+	var state State
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, ErrNoSession
+	}
+	if err != nil {
+		return state, fmt.Errorf("error reading session: %w", err)
+	}
+
+	headerSize := len(magic) + 1 + saltSize
+	if len(data) < headerSize || !bytes.Equal(data[:len(magic)], magic) {
+		return state, errors.New("session file is corrupted")
+	}
+	header := data[:headerSize]
+	method := header[len(magic)]
+	salt := header[len(magic)+1:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var key []byte
+	switch {
+	case s.method == method && (method == methodKeyring || bytes.Equal(s.salt, salt)):
+		key = s.key
+	case method == methodKeyring:
+		key, err = keyringKey(false)
+	case method == methodPassphrase:
+		if s.passphrase == "" {
+			return state, fmt.Errorf("session is protected by a passphrase: %w", ErrPassphrase)
+		}
+		key, err = deriveKey(s.passphrase, salt)
+	default:
+		err = fmt.Errorf("unknown session protection %d", method)
+	}
+	if err != nil {
+		return state, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return state, err
+	}
+
+	rest := data[headerSize:]
+	if len(rest) < gcm.NonceSize() {
+		return state, errors.New("session file is corrupted")
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil && method == methodPassphrase {
+		return state, fmt.Errorf("error decrypting session: %w", ErrPassphrase)
+	}
+	if err != nil {
+		return state, fmt.Errorf("error decrypting session: %w", err)
+	}
+	// The key is good for the next saves.
+	s.method, s.key, s.salt = method, key, append([]byte(nil), salt...)
+
+	err = json.Unmarshal(plaintext, &state)
+	if err != nil {
+		return state, fmt.Errorf("error parsing session: %w", err)
+	}
+
+	return state, nil
+}
+
+// Clear forgets every saved session.
+func (s *Store) Clear() error {
+	err := os.Remove(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing session: %w", err)
+	}
+	return nil
+}
+
+func keyringKey(create bool) ([]byte, error) {
+	k, err := openKeyring()
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	return k.key(create)
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	return gcm, nil
+}
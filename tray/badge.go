@@ -0,0 +1,77 @@
+package tray
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	badgeColor = color.NRGBA{R: 0xef, G: 0x44, B: 0x44, A: 0xff}
+	textColor  = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// badged returns icon with count drawn in a pill at its top right corner,
+// or icon itself when count is 0.
+func badged(icon *image.NRGBA, count int) *image.NRGBA {
+	if count <= 0 {
+		return icon
+	}
+
+	label := strconv.Itoa(count)
+	if count > 99 {
+		label = "99+"
+	}
+
+	// The label is drawn with the small bitmap font, then scaled up so
+	// that it stays legible at the size of the icon.
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, label).Ceil()
+	text := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	drawer := font.Drawer{
+		Dst:  text,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	drawer.DrawString(label)
+
+	size := icon.Bounds().Dx()
+	scale := max(1, size/32)
+	height := face.Height*scale + 4
+	pill := image.Rect(size-max(height, width*scale+height/2), 0, size, height)
+
+	out := image.NewNRGBA(icon.Bounds())
+	draw.Draw(out, out.Bounds(), icon, icon.Bounds().Min, draw.Src)
+
+	radius := height / 2
+	for y := pill.Min.Y; y < pill.Max.Y; y++ {
+		for x := pill.Min.X; x < pill.Max.X; x++ {
+			// Distance to the segment joining the centers of the two ends.
+			cx := min(max(x, pill.Min.X+radius), pill.Max.X-radius-1)
+			dx, dy := x-cx, y-(pill.Min.Y+radius)
+			if dx*dx+dy*dy <= radius*radius {
+				out.SetNRGBA(x, y, badgeColor)
+			}
+		}
+	}
+
+	origin := image.Pt(
+		pill.Min.X+(pill.Dx()-width*scale)/2,
+		pill.Min.Y+(pill.Dy()-face.Height*scale)/2,
+	)
+	for y := 0; y < face.Height*scale; y++ {
+		for x := 0; x < width*scale; x++ {
+			if text.AlphaAt(x/scale, y/scale).A > 0x7f {
+				out.SetNRGBA(origin.X+x, origin.Y+y, textColor)
+			}
+		}
+	}
+
+	return out
+}
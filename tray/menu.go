@@ -0,0 +1,273 @@
+package tray
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	menuInterface = "com.canonical.dbusmenu"
+	menuPath      = dbus.ObjectPath("/MenuBar")
+)
+
+// Item is an entry of the tray menu. An item without a label is a
+// separator. Items with children open a submenu.
+type Item struct {
+	Label    string
+	Disabled bool
+	// Check makes the item a check box, and Radio one of a group of
+	// choices; Checked tells whether it is selected.
+	Check    bool
+	Radio    bool
+	Checked  bool
+	Children []Item
+	// Action is called when the item is clicked, from a goroutine of the
+	// tray.
+	Action func()
+}
+
+// node is an item as the dbusmenu API sees it. The root of the menu has
+// the id 0.
+type node struct {
+	props    map[string]dbus.Variant
+	children []int32
+	action   func()
+}
+
+// layout is the structure returned by GetLayout: an item, its properties
+// and its children, themselves layouts.
+type layout struct {
+	ID       int32
+	Props    map[string]dbus.Variant
+	Children []dbus.Variant
+}
+
+type itemProps struct {
+	ID    int32
+	Props map[string]dbus.Variant
+}
+
+type event struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+// menu exports the tray menu with the dbusmenu API.
+type menu struct {
+	conn *dbus.Conn
+
+	mu       sync.Mutex
+	nodes    map[int32]*node
+	revision uint32
+}
+
+func exportMenu(conn *dbus.Conn) (*menu, error) {
+	m := &menu{
+		conn:  conn,
+		nodes: map[int32]*node{0: {props: map[string]dbus.Variant{}}},
+	}
+
+	props, err := prop.Export(conn, menuPath, prop.Map{
+		menuInterface: {
+			"Version":       {Value: uint32(3), Emit: prop.EmitFalse},
+			"TextDirection": {Value: "ltr", Emit: prop.EmitFalse},
+			"Status":        {Value: "normal", Emit: prop.EmitFalse},
+			"IconThemePath": {Value: []string{}, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error exporting tray menu: %w", err)
+	}
+
+	err = conn.Export(m, menuPath, menuInterface)
+	if err != nil {
+		return nil, fmt.Errorf("error exporting tray menu: %w", err)
+	}
+
+	node := &introspect.Node{
+		Name: string(menuPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       menuInterface,
+				Methods:    introspect.Methods(m),
+				Properties: props.Introspection(menuInterface),
+				Signals: []introspect.Signal{
+					{Name: "LayoutUpdated", Args: []introspect.Arg{
+						{Name: "revision", Type: "u"},
+						{Name: "parent", Type: "i"},
+					}},
+				},
+			},
+		},
+	}
+	err = conn.Export(introspect.NewIntrospectable(node), menuPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return nil, fmt.Errorf("error exporting tray menu: %w", err)
+	}
+
+	return m, nil
+}
+
+// set replaces the items of the menu and tells the host to read it again.
+func (m *menu) set(items []Item) {
+	m.mu.Lock()
+	m.nodes = map[int32]*node{}
+	next := int32(1)
+	var add func(items []Item) []int32
+	add = func(items []Item) []int32 {
+		ids := make([]int32, 0, len(items))
+		for _, item := range items {
+			id := next
+			next++
+			n := &node{props: itemProperties(item), action: item.Action}
+			m.nodes[id] = n
+			n.children = add(item.Children)
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	m.nodes[0] = &node{
+		props:    map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")},
+		children: add(items),
+	}
+	m.revision++
+	revision := m.revision
+	m.mu.Unlock()
+
+	m.conn.Emit(menuPath, menuInterface+".LayoutUpdated", revision, int32(0))
+}
+
+func itemProperties(item Item) map[string]dbus.Variant {
+	if item.Label == "" {
+		return map[string]dbus.Variant{"type": dbus.MakeVariant("separator")}
+	}
+
+	props := map[string]dbus.Variant{
+		"label":   dbus.MakeVariant(item.Label),
+		"enabled": dbus.MakeVariant(!item.Disabled),
+	}
+	if item.Check || item.Radio {
+		toggle := "checkmark"
+		if item.Radio {
+			toggle = "radio"
+		}
+		state := int32(0)
+		if item.Checked {
+			state = 1
+		}
+		props["toggle-type"] = dbus.MakeVariant(toggle)
+		props["toggle-state"] = dbus.MakeVariant(state)
+	}
+	if len(item.Children) > 0 {
+		props["children-display"] = dbus.MakeVariant("submenu")
+	}
+	return props
+}
+
+// layout returns the layout of the node id, with its descendants down to
+// depth levels, or all of them when depth is negative. m.mu must be held.
+func (m *menu) layout(id int32, depth int32, names []string) layout {
+	n := m.nodes[id]
+	l := layout{ID: id, Props: filter(n.props, names), Children: []dbus.Variant{}}
+	if depth == 0 {
+		return l
+	}
+	for _, child := range n.children {
+		l.Children = append(l.Children, dbus.MakeVariant(m.layout(child, depth-1, names)))
+	}
+	return l
+}
+
+// filter keeps the properties named, or all of them when names is empty.
+func filter(props map[string]dbus.Variant, names []string) map[string]dbus.Variant {
+	if len(names) == 0 {
+		return props
+	}
+	kept := map[string]dbus.Variant{}
+	for _, name := range names {
+		if value, ok := props[name]; ok {
+			kept[name] = value
+		}
+	}
+	return kept
+}
+
+// The methods below make up the dbusmenu interface.
+
+func (m *menu) GetLayout(parent int32, depth int32, names []string) (uint32, layout, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.nodes[parent]; !ok {
+		return 0, layout{}, dbus.MakeFailedError(fmt.Errorf("unknown menu item %d", parent))
+	}
+	return m.revision, m.layout(parent, depth, names), nil
+}
+
+func (m *menu) GetGroupProperties(ids []int32, names []string) ([]itemProps, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(ids) == 0 {
+		for id := range m.nodes {
+			ids = append(ids, id)
+		}
+	}
+	result := []itemProps{}
+	for _, id := range ids {
+		if n, ok := m.nodes[id]; ok {
+			result = append(result, itemProps{ID: id, Props: filter(n.props, names)})
+		}
+	}
+	return result, nil
+}
+
+func (m *menu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if n, ok := m.nodes[id]; ok {
+		if value, ok := n.props[name]; ok {
+			return value, nil
+		}
+	}
+	return dbus.Variant{}, dbus.MakeFailedError(fmt.Errorf("unknown property %q of menu item %d", name, id))
+}
+
+func (m *menu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	if eventID != "clicked" {
+		return nil
+	}
+
+	m.mu.Lock()
+	n, ok := m.nodes[id]
+	m.mu.Unlock()
+
+	if ok && n.action != nil {
+		go n.action()
+	}
+	return nil
+}
+
+func (m *menu) EventGroup(events []event) ([]int32, *dbus.Error) {
+	for _, e := range events {
+		m.Event(e.ID, e.EventID, e.Data, e.Timestamp)
+	}
+	return []int32{}, nil
+}
+
+func (m *menu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+func (m *menu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
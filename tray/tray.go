@@ -0,0 +1,291 @@
+package tray
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	itemInterface    = "org.kde.StatusNotifierItem"
+	itemPath         = dbus.ObjectPath("/StatusNotifierItem")
+	watcherService   = "org.kde.StatusNotifierWatcher"
+	watcherPath      = dbus.ObjectPath("/StatusNotifierWatcher")
+	watcherInterface = "org.kde.StatusNotifierWatcher"
+
+	appID = "hudori-desktop"
+)
+
+//go:embed icon.png
+var iconPNG []byte
+
+// pixmap is an icon as the StatusNotifierItem API sends it: ARGB32
+// pixels in network byte order.
+type pixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+// toolTip is the ToolTip property of a StatusNotifierItem.
+type toolTip struct {
+	IconName string
+	Pixmaps  []pixmap
+	Title    string
+	Text     string
+}
+
+// Tray is an icon in the system tray, shown through the
+// StatusNotifierItem D-Bus API, with a menu exported with the dbusmenu
+// API. A click on the icon calls activate.
+type Tray struct {
+	conn     *dbus.Conn
+	name     string
+	props    *prop.Properties
+	menu     *menu
+	activate func()
+	icon     *image.NRGBA
+	signals  chan *dbus.Signal
+
+	mu         sync.Mutex
+	registered bool
+}
+
+// Open shows the tray icon. The icon is only visible once a tray host
+// picks it up, which Visible reports; Open does not fail when no host
+// runs yet, as one may come up later.
+func Open(title string, activate func()) (*Tray, error) {
+	decoded, err := png.Decode(bytes.NewReader(iconPNG))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding tray icon: %w", err)
+	}
+	icon := image.NewNRGBA(decoded.Bounds())
+	for y := icon.Rect.Min.Y; y < icon.Rect.Max.Y; y++ {
+		for x := icon.Rect.Min.X; x < icon.Rect.Max.X; x++ {
+			icon.Set(x, y, decoded.At(x, y))
+		}
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the session bus: %w", err)
+	}
+
+	t := &Tray{
+		conn:     conn,
+		name:     fmt.Sprintf("%s-%d-1", itemInterface, os.Getpid()),
+		activate: activate,
+		icon:     icon,
+		signals:  make(chan *dbus.Signal, 4),
+	}
+
+	t.menu, err = exportMenu(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	err = t.export(title)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(t.name, dbus.NameFlagDoNotQueue)
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		err = fmt.Errorf("%s is taken", t.name)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error naming tray icon: %w", err)
+	}
+
+	// The icon has to be registered again whenever the watcher restarts,
+	// e.g. with the panel.
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, watcherService),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error watching the tray host: %w", err)
+	}
+	conn.Signal(t.signals)
+	go t.watch()
+
+	t.register()
+
+	return t, nil
+}
+
+func (t *Tray) export(title string) error {
+	icon := []pixmap{toPixmap(t.icon)}
+	props := prop.Map{
+		itemInterface: {
+			"Category":            {Value: "Communications", Emit: prop.EmitFalse},
+			"Id":                  {Value: appID, Emit: prop.EmitFalse},
+			"Title":               {Value: title, Emit: prop.EmitFalse},
+			"Status":              {Value: "Active", Emit: prop.EmitFalse},
+			"WindowId":            {Value: int32(0), Emit: prop.EmitFalse},
+			"IconName":            {Value: "", Emit: prop.EmitFalse},
+			"IconPixmap":          {Value: icon, Emit: prop.EmitFalse},
+			"OverlayIconName":     {Value: "", Emit: prop.EmitFalse},
+			"OverlayIconPixmap":   {Value: []pixmap{}, Emit: prop.EmitFalse},
+			"AttentionIconName":   {Value: "", Emit: prop.EmitFalse},
+			"AttentionIconPixmap": {Value: icon, Emit: prop.EmitFalse},
+			"AttentionMovieName":  {Value: "", Emit: prop.EmitFalse},
+			"ToolTip":             {Value: toolTip{Pixmaps: []pixmap{}, Title: title}, Emit: prop.EmitFalse},
+			"ItemIsMenu":          {Value: false, Emit: prop.EmitFalse},
+			"Menu":                {Value: menuPath, Emit: prop.EmitFalse},
+		},
+	}
+
+	var err error
+	t.props, err = prop.Export(t.conn, itemPath, props)
+	if err != nil {
+		return fmt.Errorf("error exporting tray icon: %w", err)
+	}
+
+	err = t.conn.Export(item{t}, itemPath, itemInterface)
+	if err != nil {
+		return fmt.Errorf("error exporting tray icon: %w", err)
+	}
+
+	node := &introspect.Node{
+		Name: string(itemPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       itemInterface,
+				Methods:    introspect.Methods(item{}),
+				Properties: t.props.Introspection(itemInterface),
+				Signals: []introspect.Signal{
+					{Name: "NewIcon"},
+					{Name: "NewAttentionIcon"},
+					{Name: "NewToolTip"},
+					{Name: "NewStatus", Args: []introspect.Arg{{Name: "status", Type: "s"}}},
+				},
+			},
+		},
+	}
+	err = t.conn.Export(introspect.NewIntrospectable(node), itemPath, "org.freedesktop.DBus.Introspectable")
+	if err != nil {
+		return fmt.Errorf("error exporting tray icon: %w", err)
+	}
+
+	return nil
+}
+
+// register asks the watcher to show the icon.
+func (t *Tray) register() {
+	watcher := t.conn.Object(watcherService, watcherPath)
+	err := watcher.Call(watcherInterface+".RegisterStatusNotifierItem", 0, t.name).Err
+
+	t.mu.Lock()
+	t.registered = err == nil
+	t.mu.Unlock()
+}
+
+// watch registers the icon again with every new watcher, until the
+// connection is closed.
+func (t *Tray) watch() {
+	for signal := range t.signals {
+		if signal.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(signal.Body) < 3 {
+			continue
+		}
+		owner, _ := signal.Body[2].(string)
+		if owner == "" {
+			t.mu.Lock()
+			t.registered = false
+			t.mu.Unlock()
+			continue
+		}
+		t.register()
+	}
+}
+
+// Visible reports whether a tray host shows the icon.
+func (t *Tray) Visible() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.registered
+}
+
+// Close removes the icon.
+func (t *Tray) Close() {
+	t.conn.Close()
+}
+
+// SetMenu replaces the menu of the icon.
+func (t *Tray) SetMenu(items []Item) {
+	t.menu.set(items)
+}
+
+// SetBadge shows count on the icon, and text as its tool tip. The icon
+// asks for attention when urgent is set, e.g. for mentions.
+func (t *Tray) SetBadge(count int, urgent bool, text string) {
+	icon := []pixmap{toPixmap(badged(t.icon, count))}
+	status := "Active"
+	if urgent {
+		status = "NeedsAttention"
+	}
+	title, _ := t.props.GetMust(itemInterface, "Title").(string)
+
+	t.props.SetMust(itemInterface, "IconPixmap", icon)
+	t.props.SetMust(itemInterface, "AttentionIconPixmap", icon)
+	t.props.SetMust(itemInterface, "ToolTip", toolTip{Pixmaps: []pixmap{}, Title: title, Text: text})
+	t.props.SetMust(itemInterface, "Status", status)
+
+	t.conn.Emit(itemPath, itemInterface+".NewIcon")
+	t.conn.Emit(itemPath, itemInterface+".NewAttentionIcon")
+	t.conn.Emit(itemPath, itemInterface+".NewToolTip")
+	t.conn.Emit(itemPath, itemInterface+".NewStatus", status)
+}
+
+// item holds the methods of the StatusNotifierItem interface.
+type item struct {
+	t *Tray
+}
+
+func (i item) Activate(x, y int32) *dbus.Error {
+	go i.t.activate()
+	return nil
+}
+
+func (i item) SecondaryActivate(x, y int32) *dbus.Error {
+	go i.t.activate()
+	return nil
+}
+
+// ContextMenu is only called by hosts that do not read the menu from
+// dbusmenu, which all the current ones do.
+func (i item) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+func (i item) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+// toPixmap converts img to the ARGB32 pixmap format.
+func toPixmap(img *image.NRGBA) pixmap {
+	bounds := img.Bounds()
+	data := make([]byte, 0, bounds.Dx()*bounds.Dy()*4)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			data = append(data, c.A, c.R, c.G, c.B)
+		}
+	}
+	return pixmap{Width: int32(bounds.Dx()), Height: int32(bounds.Dy()), Data: data}
+}
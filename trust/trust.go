@@ -0,0 +1,229 @@
+package trust
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"hudori-desktop/config"
+)
+
+// MismatchError is returned when a backend trusted on first use shows
+// another certificate than the one it had then.
+type MismatchError struct {
+	Profile string
+	// Host is the name of the server, empty when it was reached by its IP
+	// address.
+	Host string
+	// Known and Presented are the fingerprints of the certificate that was
+	// trusted and of the one shown now.
+	Known     string
+	Presented string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("the certificate of %s changed: it was %s, it is now %s", e.Server(), e.Known, e.Presented)
+}
+
+// Server names the server for the user.
+func (e *MismatchError) Server() string {
+	return server(e.Profile, e.Host)
+}
+
+func server(profile, host string) string {
+	if host == "" {
+		return "the " + profile + " backend"
+	}
+	return host
+}
+
+// Store records the certificates of the backends trusted on first use, in
+// a JSON file mapping each profile and host to the fingerprint of its
+// certificate. The servers reached by IP address, which the TLS handshake
+// does not name, share the empty host.
+type Store struct {
+	path string
+
+	mu         sync.Mutex
+	known      map[string]string
+	onMismatch func(*MismatchError)
+}
+
+// Open reads the certificates recorded at path. The store is usable even
+// when the file could not be read: it is then empty, and the file is
+// written anew once a certificate is recorded.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, known: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("error reading trusted certificates: %w", err)
+	}
+
+	err = json.Unmarshal(data, &s.known)
+	if err != nil {
+		s.known = map[string]string{}
+		return s, fmt.Errorf("error parsing trusted certificates %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// OnMismatch sets the function called when a certificate does not match
+// the one recorded, before the connection is refused.
+func (s *Store) OnMismatch(f func(*MismatchError)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onMismatch = f
+}
+
+// Config returns the TLS configuration of the connections to the backend
+// of profile. Certificates must chain to the system authorities or to
+// those of settings.CAFile, unless the backend is trusted on first use;
+// when pins are set, the chain must also hold a certificate they match.
+func (s *Store) Config(profile string, settings config.TLS) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if settings.CAFile != "" {
+		bundle, err := os.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificate in CA bundle %s", settings.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(settings.Pins) == 0 && !settings.TOFU {
+		return cfg, nil
+	}
+
+	// A certificate trusted on first use needs no authority, so the chain
+	// is not checked: the certificate must be the one recorded instead.
+	cfg.InsecureSkipVerify = settings.TOFU
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("%s has no certificate", server(profile, cs.ServerName))
+		}
+		if len(settings.Pins) > 0 && !pinned(cs, settings.Pins) {
+			return fmt.Errorf("no certificate of %s matches its pins", server(profile, cs.ServerName))
+		}
+		if settings.TOFU {
+			return s.check(profile, cs.ServerName, cs.PeerCertificates[0])
+		}
+		return nil
+	}
+
+	return cfg, nil
+}
+
+// pinned reports whether a certificate of the chain of cs matches one of
+// pins. Only the leaf is known to belong to the server when the chain was
+// not verified.
+func pinned(cs tls.ConnectionState, pins []string) bool {
+	certs := []*x509.Certificate{cs.PeerCertificates[0]}
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	for _, cert := range certs {
+		if slices.Contains(pins, Pin(cert)) {
+			return true
+		}
+	}
+	return false
+}
+
+// check compares cert with the certificate recorded for host, recording
+// it if there is none yet.
+func (s *Store) check(profile, host string, cert *x509.Certificate) error {
+	presented := Fingerprint(cert)
+	key := profile + "/" + host
+
+	s.mu.Lock()
+	known, ok := s.known[key]
+	if !ok {
+		s.known[key] = presented
+		// The certificate stays trusted for this run when it cannot be
+		// saved.
+		s.save()
+		s.mu.Unlock()
+		return nil
+	}
+	onMismatch := s.onMismatch
+	s.mu.Unlock()
+
+	if known == presented {
+		return nil
+	}
+
+	err := &MismatchError{Profile: profile, Host: host, Known: known, Presented: presented}
+	if onMismatch != nil {
+		onMismatch(err)
+	}
+	return err
+}
+
+// Trust records fingerprint as the certificate of host on profile, in
+// place of the one trusted before.
+func (s *Store) Trust(profile, host, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.known[profile+"/"+host] = fingerprint
+	return s.save()
+}
+
+// save writes the certificates to the file of the store. s.mu must be
+// held.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.known, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling trusted certificates: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("error creating trusted certificates directory: %w", err)
+	}
+
+	err = os.WriteFile(s.path, data, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing trusted certificates: %w", err)
+	}
+
+	return nil
+}
+
+// Fingerprint returns the SHA-256 of cert, in the colon-separated hex
+// that browsers and openssl show.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// Pin returns the base64 SHA-256 of the SubjectPublicKeyInfo of cert, as
+// config.TLS pins are written.
+func Pin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}